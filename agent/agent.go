@@ -16,6 +16,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 )
@@ -35,6 +36,12 @@ type Agent struct {
 	stats       utils.Stat
 	allowMap    map[string]bool
 
+	// 自定义命令临时指定的模型和切换前的模式，任务结束后恢复
+	cmdModel    string
+	cmdPrevMode string
+	// /review进行中的审查，任务结束后展示
+	review *utils.ReviewReport
+
 	sessionId string
 
 	InteruptNum int
@@ -88,6 +95,9 @@ func (a *Agent) Run(ctx context.Context, input *tools.AgentInput) *tools.AgentOu
 		panic(fmt.Sprintf("main model %s not found", config.GlobalConfig.MainModel))
 	}
 	a.stats.WindowSize = modelConf.ContextWindow
+	if result := a.runHooks(&utils.HookInput{Event: utils.HOOK_SESSION_START}); len(result.Feedback) > 0 {
+		output.OnSystemMsg(utils.InfoMessageStyle(result.JoinedFeedback()), berio.MsgTypeDump)
+	}
 	for {
 		if a.stop {
 			break
@@ -104,8 +114,19 @@ func (a *Agent) Run(ctx context.Context, input *tools.AgentInput) *tools.AgentOu
 		}
 		filtered, ok := a.processAtCommand(filtered)
 		if !ok {
+			a.resetCustomCmd()
 			continue
 		}
+		result := a.runHooks(&utils.HookInput{Event: utils.HOOK_USER_PROMPT_SUBMIT, Prompt: filtered})
+		if result.Block {
+			output.OnSystemMsg(locales.Sprintf("prompt blocked by hook: %v", result.Reason), berio.MsgTypeWarning)
+			a.attachments = nil
+			a.resetCustomCmd()
+			continue
+		}
+		if len(result.Feedback) > 0 {
+			filtered += "\n\n" + result.JoinedFeedback()
+		}
 		a.timeline.InitCheckpoint()
 		query := utils.Query{}
 		query.SetUserInput(filtered)
@@ -123,6 +144,8 @@ func (a *Agent) Run(ctx context.Context, input *tools.AgentInput) *tools.AgentOu
 		}
 		cli.PrintDebugText("query: \n%v", query.Build())
 		a.doTask(ctx)
+		a.showReview()
+		a.resetCustomCmd()
 	}
 	return &tools.AgentOutput{}
 }
@@ -137,6 +160,7 @@ func isChanClose(signalChan chan os.Signal) bool {
 }
 func (a *Agent) doTask(ctx context.Context) {
 	defer utils.HideMementoFile(a.sessionId)
+	defer cli.ClearSteer()
 	defer func() {
 		a.timeline.UpdateTokenUsage(a.stats.TokenUsageSession)
 		a.timeline.Store()
@@ -144,7 +168,9 @@ func (a *Agent) doTask(ctx context.Context) {
 	//clean tail tool calls
 	a.timeline.CleanTailToolCalls()
 	a.timeline.SetTaskEpoch()
-	mainModelConf := config.GlobalConfig.GetModelConfig(config.GlobalConfig.MainModel)
+	mainModelConf := config.GlobalConfig.GetModelConfig(a.modeModel())
+	a.stats.WindowSize = mainModelConf.ContextWindow
+	toolSchema := a.modeToolSchema()
 	output := a.output
 	toolCallAnswers := []*tools.AgentOutput{}
 	keepGoing := true
@@ -152,6 +178,9 @@ func (a *Agent) doTask(ctx context.Context) {
 	// 记录 memento 文件的初始哈希，用于后续检测是否有改动
 	mementoInitialHash := utils.GetMementoHash()
 	mementoReminded := false // 标记是否已经提醒过，避免重复提醒
+	filesModified := false   // 上次校验后是否修改过工作区文件
+	verifyRetry := 0         // 校验连续失败后让模型修复的次数，校验通过或放弃修复后清零
+	stopHookActive := false  // 是否已经因为Stop hook继续过
 	defer func() {
 		if !isChanClose(signalChan) {
 			close(signalChan)
@@ -167,6 +196,10 @@ func (a *Agent) doTask(ctx context.Context) {
 		if a.stop {
 			break
 		}
+		// 用户在任务进行中输入的引导消息，在下一次调用llm前注入
+		if a.addSteers() {
+			keepGoing = true
+		}
 		if len(toolCallAnswers) == 0 && !keepGoing {
 			break
 		}
@@ -186,7 +219,7 @@ func (a *Agent) doTask(ctx context.Context) {
 		content := bytes.NewBuffer(nil)
 		reasoningContent := bytes.NewBuffer(nil)
 
-		streamer, err := utils.NewLlmStreamer(ctxWithCancel, mainModelConf, chatItems, a.toolSchema)
+		streamer, err := utils.NewLlmStreamer(ctxWithCancel, mainModelConf, chatItems, toolSchema)
 		if err != nil {
 			output.OnSystemMsg(locales.Sprintf("error: %v", err), berio.MsgTypeWarning)
 			break
@@ -241,21 +274,34 @@ func (a *Agent) doTask(ctx context.Context) {
 			cli.PrintDebugText("tool calls: %v", tools)
 		}
 		if len(toolCallRequests) > 0 {
+			// 工具执行期间没有流式输出窗口，单独读取引导输入
+			cli.StartSteerCapture()
 			for _, call := range toolCallRequests {
 				if call.Function.Name == tools.TOOL_STOP_LOOP {
 					hasStopLoop = true
 				}
 				cli.PrintDebugText("calling tool: %v", call.Function.Name)
-				answer, err := a.doToolUse(ctxWithCancel, call)
+				answer, err := a.doToolUseWithSteer(ctxWithCancel, call)
 				if err != nil {
+					cli.StopSteerCapture()
 					a.output.OnSystemMsg(locales.Sprintf("error when tool call: %v", err), berio.MsgTypeWarning)
 					return
 				}
 				if answer == nil {
 					continue
 				}
+				if answer.ModifiedPath != "" && !utils.IsMementoFile(answer.ModifiedPath) {
+					filesModified = true
+				}
 				toolCallAnswers = append(toolCallAnswers, answer)
 			}
+			cli.StopSteerCapture()
+		}
+		if len(toolCallAnswers) > 0 {
+			for _, answer := range toolCallAnswers {
+				a.timeline.AddToolCallResult(answer.ToolCall.ID, answer.ToolCall.Function.Name, answer.Content, answer.ImgPath, answer.Rendered)
+				cli.PrintDebugText("%s\n%s\n%s\n", answer.ToolCall.ID, answer.ToolCall.Function.Name, answer.Content)
+			}
 		}
 		if hasStopLoop || len(toolCallAnswers) <= 0 {
 			// 在 agent 模式下，检测 memento 文件是否有改动
@@ -265,18 +311,97 @@ func (a *Agent) doTask(ctx context.Context) {
 				keepGoing = true
 				continue
 			}
+			// 在 agent 模式下，修改过文件则运行校验命令，失败时把输出反馈给模型继续修复
+			if a.agentMode == prompt.MODE_AGENT && filesModified {
+				filesModified = false
+				if a.verify(ctxWithCancel, &verifyRetry) {
+					keepGoing = true
+					continue
+				}
+			}
+			// Stop hook可以要求模型继续工作
+			result := a.runHooks(&utils.HookInput{Event: utils.HOOK_STOP, StopHookActive: stopHookActive})
+			if result.Continue {
+				stopHookActive = true
+				reason := result.Reason
+				if reason == "" {
+					reason = result.JoinedFeedback()
+				}
+				output.OnSystemMsg(utils.InfoMessageStyle(locales.Sprintf("stop hook asks to continue: %v", reason)), berio.MsgTypeDump)
+				query := utils.Query{}
+				query.SetUserInput(reason)
+				query.SetMode(a.agentMode)
+				a.timeline.AddUserInput(&query)
+				keepGoing = true
+				continue
+			}
 		}
 		if hasStopLoop {
-			break
-		}
-		if len(toolCallAnswers) > 0 {
-			for _, answer := range toolCallAnswers {
-				a.timeline.AddToolCallResult(answer.ToolCall.ID, answer.ToolCall.Function.Name, answer.Content, answer.ImgPath, answer.Rendered)
-				cli.PrintDebugText("%s\n%s\n%s\n", answer.ToolCall.ID, answer.ToolCall.Function.Name, answer.Content)
+			// 最后一轮中输入的引导消息交给模型继续处理
+			if a.addSteers() {
+				keepGoing = true
+				continue
 			}
+			break
 		}
 	}
 }
+
+// addSteers 把队列中的引导消息加入上下文，返回是否有新的引导消息
+func (a *Agent) addSteers() bool {
+	msgs := cli.PopSteers()
+	for _, msg := range msgs {
+		a.timeline.AddSteer(msg)
+		a.output.OnSystemMsg(utils.SteerStyle(msg), berio.MsgTypeDump)
+	}
+	return len(msgs) > 0
+}
+
+// doToolUseWithSteer 主agent中的shell命令在伪终端中运行，用户直接和命令交互，期间暂停读取引导输入
+func (a *Agent) doToolUseWithSteer(ctx context.Context, call *llm.ToolCall) (*tools.AgentOutput, error) {
+	if call.Function.Name == tools.TOOL_SHELL_CMD {
+		defer cli.PauseSteerCapture()()
+	}
+	return a.doToolUse(ctx, call)
+}
+
+// verify 运行配置的校验命令，校验失败且未超过重试次数时追加一轮反馈并返回true，用户中断时直接返回false
+func (a *Agent) verify(ctx context.Context, retry *int) bool {
+	conf := config.GlobalConfig.Verify
+	if conf == nil || len(conf.Commands) == 0 {
+		return false
+	}
+	verifier := utils.Verifier{
+		Commands: conf.Commands,
+		Timeout:  time.Duration(conf.Timeout) * time.Second,
+	}
+	failure := verifier.Run(ctx, func(command string) {
+		a.output.OnSystemMsg(utils.ToolUseStyle(locales.Sprintf("verifying: %s", command)), berio.MsgTypeDump)
+	})
+	if ctx.Err() != nil {
+		a.output.OnSystemMsg(locales.Sprintf("verification interrupted"), berio.MsgTypeWarning)
+		return false
+	}
+	if failure == nil {
+		*retry = 0
+		a.output.OnSystemMsg(utils.InfoMessageStyle(locales.Sprintf("verification passed")), berio.MsgTypeDump)
+		return false
+	}
+	a.output.OnSystemMsg(locales.Sprintf("verification failed: `%s` %v\n%s", failure.Command, failure.Err, utils.TailLines(failure.Output, 20)), berio.MsgTypeWarning)
+	if *retry >= conf.MaxRetry {
+		a.output.OnSystemMsg(locales.Sprintf("verification still failing after %d retries, stop fixing", conf.MaxRetry), berio.MsgTypeWarning)
+		// 放弃修复后，Stop hook或者引导输入让模型继续时，新的一批改动重新计算重试次数
+		*retry = 0
+		return false
+	}
+	*retry++
+	query := utils.Query{}
+	query.SetUserInput("")
+	query.SetMode(a.agentMode)
+	query.SetVerifyFailure(failure.String())
+	a.timeline.AddUserInput(&query)
+	return true
+}
 func (a *Agent) addMementoNotify() {
 	query := utils.Query{}
 	query.SetUserInput("")
@@ -294,8 +419,9 @@ func (a *Agent) getCliInput() berio.BerInput {
 		Attachments: attachments,
 		Stats:       a.stats,
 		Multiline:   a.multiline,
-		Model:       config.GlobalConfig.MainModel,
+		Model:       a.modeModel(),
 		SessionId:   a.timeline.SessionId,
+		TodoList:    a.timeline.GetTodoList(),
 	})
 }
 func (a *Agent) readFromUser() string {
@@ -338,7 +464,29 @@ func (a *Agent) saveCheckPoint() {
 	}
 }
 
+// doToolUse 调用工具，调用前后分别执行PreToolUse和PostToolUse hook
 func (a *Agent) doToolUse(ctx context.Context, call *llm.ToolCall) (*tools.AgentOutput, error) {
+	if _, ok := a.toolHandler[call.Function.Name]; !ok {
+		return nil, nil
+	}
+	if !a.toolInMode(call.Function.Name) {
+		a.output.OnSystemMsg(locales.Sprintf("tool [%s] is not allowed in %s mode", call.Function.Name, a.agentMode), berio.MsgTypeWarning)
+		return &tools.AgentOutput{
+			Content:  fmt.Sprintf("tool %s is not allowed in %s mode", call.Function.Name, a.agentMode),
+			ToolCall: call,
+		}, nil
+	}
+	return tools.CallToolWithHooks(ctx, a.output, a.sessionId, a.agentMode, call, a.callTool)
+}
+
+// runHooks 执行配置的hook，执行失败时提示用户但不影响流程
+func (a *Agent) runHooks(input *utils.HookInput) *utils.HookResult {
+	input.SessionId = a.sessionId
+	input.Mode = a.agentMode
+	return tools.RunHooks(context.Background(), a.output, input)
+}
+
+func (a *Agent) callTool(ctx context.Context, call *llm.ToolCall) (*tools.AgentOutput, error) {
 	if handler, ok := a.toolHandler[call.Function.Name]; ok {
 		desc := tools.ToolsMap[call.Function.Name]
 		err := tools.JsonSchemaExam(call)
@@ -385,6 +533,56 @@ func (a *Agent) doToolUse(ctx context.Context, call *llm.ToolCall) (*tools.Agent
 	return nil, nil
 }
 
+// modeModel 当前模式使用的模型，自定义命令指定了模型时优先使用，自定义模式没有指定模型或者指定的模型不存在时使用main_model
+func (a *Agent) modeModel() string {
+	if a.cmdModel != "" {
+		return a.cmdModel
+	}
+	if mode := prompt.GetCustomMode(a.agentMode); mode != nil && mode.Model != "" {
+		if config.GlobalConfig.GetModelConfig(mode.Model) != nil {
+			return mode.Model
+		}
+	}
+	return config.GlobalConfig.MainModel
+}
+
+// toolInMode 当前模式是否允许使用该工具，自定义模式没有指定工具时不限制
+// 配置文件中声明的工具指定了模式时，只能在这些模式中使用，report_finding只在审查时使用
+func (a *Agent) toolInMode(name string) bool {
+	return toolInModeOf(a.agentMode, name)
+}
+
+// toolInModeOf 工具在指定模式下是否可用，自定义模式和配置文件工具都可以限制范围
+func toolInModeOf(agentMode string, name string) bool {
+	if name == tools.TOOL_REPORT_FINDING && !tools.ReviewInProgress() {
+		return false
+	}
+	if modes := tools.CustomToolModes(name); len(modes) > 0 && !slices.Contains(modes, agentMode) {
+		return false
+	}
+	mode := prompt.GetCustomMode(agentMode)
+	if mode == nil || len(mode.Tools) == 0 {
+		return true
+	}
+	for _, tool := range mode.Tools {
+		if tool == name {
+			return true
+		}
+	}
+	return false
+}
+
+// modeToolSchema 当前模式下提供给模型的工具
+func (a *Agent) modeToolSchema() []*llm.ToolSchema {
+	var schemas []*llm.ToolSchema
+	for _, schema := range a.toolSchema {
+		if a.toolInMode(schema.Function.Name) {
+			schemas = append(schemas, schema)
+		}
+	}
+	return schemas
+}
+
 func (a *Agent) compact(ctx context.Context) {
 	chats := a.timeline.GetChatContext(false)
 	out := tools.Compact(ctx, &tools.AgentInput{
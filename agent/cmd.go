@@ -2,30 +2,123 @@ package agent
 
 import (
 	"bergo/berio"
+	"bergo/commands"
 	"bergo/config"
 	"bergo/llm"
 	"bergo/locales"
+	"bergo/mcp"
 	"bergo/prompt"
+	"bergo/tools"
 	"bergo/utils"
 	"bergo/utils/cli"
+	"context"
+	"fmt"
 	"strings"
 	"time"
 )
 
 func (a *Agent) initCmdHandler() {
 	a.cmdHandler = map[string]func(input string) (string, bool){
-		"/exit":      a.exitCmd,
-		"/help":      a.helpCmd,
-		"/view":      a.viewCmd,
-		"/planner":   a.plannerCmd,
-		"/agent":     a.agentCmd,
-		"/multiline": a.multilineCmd,
-		"/history":   a.timelineCmd,
-		"/revert":    a.revertCmd,
-		"/sessions":  a.loadSessionCmd,
-		"/clear":     a.newSessionCmd,
-		"/model":     a.switchModelCmd,
-		"/compact":   a.compactCmd,
+		"/exit":        a.exitCmd,
+		"/help":        a.helpCmd,
+		"/view":        a.viewCmd,
+		"/planner":     a.plannerCmd,
+		"/agent":       a.agentCmd,
+		"/multiline":   a.multilineCmd,
+		"/history":     a.timelineCmd,
+		"/revert":      a.revertCmd,
+		"/sessions":    a.loadSessionCmd,
+		"/clear":       a.newSessionCmd,
+		"/model":       a.switchModelCmd,
+		"/compact":     a.compactCmd,
+		"/mcp":         a.mcpCmd,
+		"/commit":      a.commitCmd,
+		"/review":      a.reviewCmd,
+		"/berag-cache": a.beragCacheCmd,
+		"/init":        a.initCmd,
+	}
+	// 自定义模式的切换命令
+	for _, mode := range prompt.GetCustomModes() {
+		if _, ok := a.cmdHandler[mode.Command]; ok {
+			a.output.OnSystemMsg(locales.Sprintf("command %v of mode %v conflicts with an existing command, ignored", mode.Command, mode.Name), berio.MsgTypeWarning)
+			continue
+		}
+		a.cmdHandler[mode.Command] = a.customModeCmd(mode.Name)
+		description := mode.Description
+		if description == "" {
+			description = locales.Sprintf("switch to %v mode", mode.Name)
+		}
+		cli.RegisterCmdSuggestion(mode.Command, description)
+	}
+	// markdown模板定义的自定义命令
+	for _, cmd := range commands.GetCommands() {
+		name := "/" + cmd.Name
+		if _, ok := a.cmdHandler[name]; ok {
+			a.output.OnSystemMsg(locales.Sprintf("custom command %v conflicts with an existing command, ignored", name), berio.MsgTypeWarning)
+			continue
+		}
+		a.cmdHandler[name] = a.customCmd(cmd)
+		description := cmd.Description
+		if description == "" {
+			description = locales.Sprintf("custom command from %v", cmd.Path)
+		}
+		cli.RegisterCmdSuggestion(name, description)
+	}
+}
+
+// customCmd 把模板展开为用户输入，front matter指定的模式和模型只在这次任务中生效
+func (a *Agent) customCmd(cmd *commands.Command) func(input string) (string, bool) {
+	return func(input string) (string, bool) {
+		arguments := strings.TrimPrefix(strings.TrimSpace(input), "/"+cmd.Name)
+		if cmd.Mode != "" {
+			if !isSwitchableMode(cmd.Mode) {
+				a.output.OnSystemMsg(locales.Sprintf("mode %v of command %v not found", cmd.Mode, cmd.Name), berio.MsgTypeWarning)
+				return "", true
+			}
+			a.cmdPrevMode = a.agentMode
+			a.agentMode = cmd.Mode
+		}
+		if cmd.Model != "" {
+			if config.GlobalConfig.GetModelConfig(cmd.Model) == nil {
+				a.output.OnSystemMsg(locales.Sprintf("model %v of command %v not found", cmd.Model, cmd.Name), berio.MsgTypeWarning)
+				a.resetCustomCmd()
+				return "", true
+			}
+			a.cmdModel = cmd.Model
+		}
+		return cmd.Expand(arguments), false
+	}
+}
+
+// resetCustomCmd 恢复自定义命令临时修改的模式和模型，并结束进行中的审查
+func (a *Agent) resetCustomCmd() {
+	if a.review != nil {
+		tools.FinishReview()
+		a.review = nil
+	}
+	if a.cmdPrevMode != "" {
+		a.agentMode = a.cmdPrevMode
+		a.cmdPrevMode = ""
+	}
+	a.cmdModel = ""
+}
+
+func isSwitchableMode(mode string) bool {
+	switch mode {
+	case prompt.MODE_VIEW, prompt.MODE_PLANNER, prompt.MODE_AGENT:
+		return true
+	}
+	return prompt.GetCustomMode(mode) != nil
+}
+
+func (a *Agent) customModeCmd(name string) func(input string) (string, bool) {
+	return func(input string) (string, bool) {
+		a.agentMode = name
+		a.output.OnSystemMsg(locales.Sprintf("Switch to %v mode", strings.ToUpper(name)), berio.MsgTypeText)
+		if mode := prompt.GetCustomMode(name); mode != nil && mode.Model != "" && config.GlobalConfig.GetModelConfig(mode.Model) == nil {
+			a.output.OnSystemMsg(locales.Sprintf("model %v of mode %v not found, use %v instead", mode.Model, name, config.GlobalConfig.MainModel), berio.MsgTypeWarning)
+		}
+		return "", true
 	}
 }
 
@@ -36,16 +129,39 @@ func (a *Agent) compactCmd(input string) (string, bool) {
 	return "", true
 }
 
+// beragCacheCmd 查看berag提取结果缓存的大小，/berag-cache clear 清空缓存
+func (a *Agent) beragCacheCmd(input string) (string, bool) {
+	args := strings.Fields(strings.TrimPrefix(input, "/berag-cache"))
+	cache := tools.GetBeragCache()
+	if cache == nil {
+		a.output.OnSystemMsg(locales.Sprintf("berag cache is disabled"), berio.MsgTypeText)
+		return "", true
+	}
+	switch {
+	case len(args) == 0:
+		entries, size := cache.Stats()
+		a.output.OnSystemMsg(locales.Sprintf("berag cache: %d entries, %.1f KB in %v", entries, float64(size)/1024, utils.BERAG_CACHE_FILE), berio.MsgTypeText)
+	case len(args) == 1 && args[0] == "clear":
+		if err := cache.Clear(); err != nil {
+			a.output.OnSystemMsg(locales.Sprintf("clear berag cache failed: %v", err), berio.MsgTypeWarning)
+			return "", true
+		}
+		a.output.OnSystemMsg(locales.Sprintf("berag cache cleared"), berio.MsgTypeText)
+	default:
+		a.output.OnSystemMsg(locales.Sprintf("usage: /berag-cache [clear]"), berio.MsgTypeWarning)
+	}
+	return "", true
+}
+
 func (a *Agent) handleCmd(input string) (output string, goToStart bool) {
 	if !strings.HasPrefix(input, "/") {
 		return input, false
 	}
-	for cmd, handler := range a.cmdHandler {
-		if strings.HasPrefix(input, cmd) {
-			return handler(input)
-		}
+	// 按第一个词精确匹配，避免 /agent 和 /agent-xxx 这类命令互相冲突
+	tmp := strings.Fields(input)
+	if handler, ok := a.cmdHandler[tmp[0]]; ok {
+		return handler(input)
 	}
-	tmp := strings.Split(input, " ")
 	a.output.OnSystemMsg(locales.Sprintf("unknown command: %v", tmp[0]), berio.MsgTypeWarning)
 	return "", true
 }
@@ -55,9 +171,17 @@ func (a *Agent) exitCmd(input string) (string, bool) {
 	return "", true
 }
 func (a *Agent) helpCmd(input string) (string, bool) {
-
-	a.output.OnSystemMsg(locales.Sprintf("help command not implemented"), berio.MsgTypeWarning)
-	a.output.Stop()
+	topic := strings.TrimSpace(strings.TrimPrefix(input, "/help"))
+	sections := a.helpSections()
+	current := 0
+	if topic != "" {
+		current = cli.FindHelpSection(sections, topic)
+		if current < 0 {
+			a.output.OnSystemMsg(locales.Sprintf("unknown help topic: %v, available topics: %v", topic, helpTopics(sections)), berio.MsgTypeWarning)
+			return "", true
+		}
+	}
+	cli.NewHelpView(sections, current).Show()
 	return "", true
 }
 func (a *Agent) viewCmd(input string) (string, bool) {
@@ -197,3 +321,67 @@ func (a *Agent) switchModelCmd(input string) (string, bool) {
 	a.output.OnSystemMsg(locales.Sprintf("switched to %v", input), berio.MsgTypeText)
 	return "", true
 }
+
+// mcpCmd 查看MCP服务器状态，/mcp enable|disable <name> 在会话中启用或禁用服务器
+func (a *Agent) mcpCmd(input string) (string, bool) {
+	args := strings.Fields(strings.TrimPrefix(input, "/mcp"))
+	manager := mcp.GetManager()
+	if len(args) == 0 {
+		if len(config.GlobalConfig.McpServers) == 0 {
+			a.output.OnSystemMsg(locales.Sprintf("no mcp server configured"), berio.MsgTypeText)
+			return "", true
+		}
+		var lines []string
+		for _, conf := range config.GlobalConfig.McpServers {
+			status := locales.Sprintf("not connected")
+			if conf.Disabled {
+				status = locales.Sprintf("disabled")
+			} else if client := manager.GetClient(conf.Name); client != nil {
+				status = locales.Sprintf("connected, %d tools", len(client.Tools))
+			}
+			lines = append(lines, fmt.Sprintf("%s: %s", conf.Name, status))
+		}
+		a.output.OnSystemMsg(utils.InfoMessageStyle(strings.Join(lines, "\n")), berio.MsgTypeDump)
+		return "", true
+	}
+	if len(args) != 2 || (args[0] != "enable" && args[0] != "disable") {
+		a.output.OnSystemMsg(locales.Sprintf("usage: /mcp [enable|disable <server>]"), berio.MsgTypeWarning)
+		return "", true
+	}
+	var conf *config.McpServerConfig
+	for _, c := range config.GlobalConfig.McpServers {
+		if c.Name == args[1] {
+			conf = c
+		}
+	}
+	if conf == nil {
+		a.output.OnSystemMsg(locales.Sprintf("mcp server %v not found", args[1]), berio.MsgTypeWarning)
+		return "", true
+	}
+	if args[0] == "disable" {
+		for _, name := range tools.UnregisterMcpTools(conf.Name) {
+			delete(a.toolHandler, name)
+		}
+		manager.Disconnect(conf.Name)
+		conf.Disabled = true
+		a.buildToolSchema()
+		a.output.OnSystemMsg(locales.Sprintf("mcp server %v disabled", conf.Name), berio.MsgTypeText)
+		return "", true
+	}
+	client, err := manager.Connect(context.Background(), conf)
+	if err != nil {
+		a.output.OnSystemMsg(locales.Sprintf("connect mcp server failed: %v", err), berio.MsgTypeWarning)
+		return "", true
+	}
+	conf.Disabled = false
+	names, errs := tools.RegisterMcpTools(client)
+	for _, err := range errs {
+		a.output.OnSystemMsg(err.Error(), berio.MsgTypeWarning)
+	}
+	for _, name := range names {
+		a.toolHandler[name] = tools.ToolFuncMap[name]
+	}
+	a.buildToolSchema()
+	a.output.OnSystemMsg(locales.Sprintf("mcp server %v enabled, %d tools registered", conf.Name, len(names)), berio.MsgTypeText)
+	return "", true
+}
@@ -0,0 +1,196 @@
+package agent
+
+import (
+	"bergo/berio"
+	"bergo/config"
+	"bergo/llm"
+	"bergo/locales"
+	"bergo/prompt"
+	"bergo/utils"
+	"bergo/utils/cli"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+)
+
+const (
+	// 发给模型的diff最大行数，超过时截断
+	COMMIT_MAX_DIFF_LINE = 3000
+	// 作为风格参考的历史提交数
+	COMMIT_HISTORY_SAMPLE = 20
+)
+
+// commitCmd 让主模型根据暂存区的改动写提交信息，用户可以接受、修改或按建议拆分成多个提交。
+// 暂存区为空时，可以暂存本次会话中Bergo改动过的文件
+func (a *Agent) commitCmd(input string) (string, bool) {
+	git := utils.NewGit(".")
+	if !git.IsRepo() {
+		a.output.OnSystemMsg(locales.Sprintf("the workspace is not in a git repository"), berio.MsgTypeWarning)
+		return "", true
+	}
+	receiver := a.getCliInput()
+	staged, err := git.StagedFiles()
+	if err != nil {
+		a.output.OnSystemMsg(locales.Sprintf("read staged files failed: %v", err), berio.MsgTypeWarning)
+		return "", true
+	}
+	if len(staged) == 0 {
+		files := a.sessionChangedFiles(git)
+		if len(files) == 0 {
+			a.output.OnSystemMsg(locales.Sprintf("nothing staged to commit, and Bergo has not changed any file in this session"), berio.MsgTypeWarning)
+			return "", true
+		}
+		res := receiver.Select(locales.Sprintf("Nothing is staged. Stage the files Bergo changed in this session?\n%s", strings.Join(files, "\n")), []string{locales.Sprintf("Yes"), locales.Sprintf("Skip")})
+		if res != locales.Sprintf("Yes") {
+			return "", true
+		}
+		if _, err := git.Run(append([]string{"add", "-A", "--"}, files...)...); err != nil {
+			a.output.OnSystemMsg(locales.Sprintf("stage files failed: %v", err), berio.MsgTypeWarning)
+			return "", true
+		}
+		if staged, err = git.StagedFiles(); err != nil || len(staged) == 0 {
+			a.output.OnSystemMsg(locales.Sprintf("nothing staged to commit"), berio.MsgTypeWarning)
+			return "", true
+		}
+	}
+
+	a.output.OnSystemMsg(locales.Sprintf("Bergo is writing the commit message"), berio.MsgTypeText)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cli.SetCancelFunc(cancel)
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, os.Interrupt)
+	defer func() {
+		signal.Reset(os.Interrupt)
+		close(signalChan)
+	}()
+	go func() {
+		if _, ok := <-signalChan; ok {
+			cancel()
+		}
+	}()
+	proposal, err := a.draftCommitMessage(ctx, git, staged)
+	if err != nil {
+		a.output.OnSystemMsg(locales.Sprintf("write commit message failed: %v", err), berio.MsgTypeWarning)
+		return "", true
+	}
+	for {
+		question := locales.Sprintf("Commit message:\n%s", proposal.Message)
+		options := []string{locales.Sprintf("Yes")}
+		splitOption := ""
+		if len(proposal.Split) > 0 && utils.ValidateCommitSplit(proposal.Split, staged) == nil {
+			var groups []string
+			for i, group := range proposal.Split {
+				groups = append(groups, fmt.Sprintf("%d. %s\n   %s", i+1, strings.TrimSpace(group.Message), strings.Join(group.Files, ", ")))
+			}
+			question += "\n\n" + locales.Sprintf("Suggested split:\n%s", strings.Join(groups, "\n"))
+			splitOption = locales.Sprintf("Split into %d commits", len(proposal.Split))
+			options = append(options, splitOption)
+		}
+		options = append(options, locales.Sprintf("Edit message"), locales.Sprintf("Skip"))
+
+		res := receiver.Select(question, options)
+		if splitOption != "" && res == splitOption {
+			heads, err := git.CommitSplit(proposal.Split)
+			for _, head := range heads {
+				a.output.OnSystemMsg(locales.Sprintf("committed: %v", head), berio.MsgTypeText)
+			}
+			if err != nil {
+				a.output.OnSystemMsg(locales.Sprintf("commit failed: %v", err), berio.MsgTypeWarning)
+			}
+			return "", true
+		}
+		switch res {
+		case locales.Sprintf("Yes"):
+			head, err := git.Commit(proposal.Message)
+			if err != nil {
+				a.output.OnSystemMsg(locales.Sprintf("commit failed: %v", err), berio.MsgTypeWarning)
+				return "", true
+			}
+			a.output.OnSystemMsg(locales.Sprintf("committed: %v", head), berio.MsgTypeText)
+			return "", true
+		case locales.Sprintf("Edit message"):
+			a.output.OnSystemMsg(utils.InfoMessageStyle(locales.Sprintf("Enter the commit message:")), berio.MsgTypeDump)
+			text, err := receiver.Read()
+			if err == nil && strings.TrimSpace(text) != "" {
+				// 修改后的信息对应整个暂存区，不再使用拆分建议
+				proposal = &utils.CommitProposal{Message: strings.TrimSpace(text)}
+			}
+		default:
+			return "", true
+		}
+	}
+}
+
+// sessionChangedFiles 比较第一个checkpoint和当前工作区，得到本次会话改动过的文件，
+// 只保留在真实仓库中有改动的文件
+func (a *Agent) sessionChangedFiles(git *utils.Git) []string {
+	hash := a.timeline.FirstCheckpointHash()
+	if hash == "" {
+		return nil
+	}
+	a.timeline.InitCheckpoint()
+	changed, err := a.timeline.Checkpoint.ChangedFiles(hash)
+	if err != nil {
+		a.output.OnSystemMsg(locales.Sprintf("read changed files of the session failed: %v", err), berio.MsgTypeWarning)
+		return nil
+	}
+	status, err := git.Status()
+	if err != nil {
+		a.output.OnSystemMsg(locales.Sprintf("git status failed: %v", err), berio.MsgTypeWarning)
+		return nil
+	}
+	dirty := map[string]bool{}
+	for _, file := range status.Files {
+		dirty[file.Path] = true
+		if file.OrigPath != "" {
+			dirty[file.OrigPath] = true
+		}
+	}
+	var files []string
+	for _, file := range changed {
+		if dirty[file] && !slices.Contains(files, file) {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// draftCommitMessage 把暂存区的diff和历史提交发给主模型，解析出提交信息
+func (a *Agent) draftCommitMessage(ctx context.Context, git *utils.Git, staged []string) (*utils.CommitProposal, error) {
+	stat, err := git.Run("diff", "--cached", "--stat")
+	if err != nil {
+		return nil, err
+	}
+	diff, err := git.Run("diff", "--cached", "--no-ext-diff", "--no-textconv")
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimRight(diff, "\n"), "\n")
+	if len(lines) > COMMIT_MAX_DIFF_LINE {
+		diff = strings.Join(lines[:COMMIT_MAX_DIFF_LINE], "\n") + fmt.Sprintf("\n...%d lines are truncated...", len(lines)-COMMIT_MAX_DIFF_LINE)
+	}
+	// 仓库还没有提交时git log会失败，此时没有可参考的历史
+	history, _ := git.Run("log", "-n", fmt.Sprint(COMMIT_HISTORY_SAMPLE), "--pretty=format:%s")
+
+	modelConf := config.GlobalConfig.GetModelConfig(config.GlobalConfig.MainModel)
+	if modelConf == nil {
+		return nil, fmt.Errorf("main model %s not found", config.GlobalConfig.MainModel)
+	}
+	chats := []*llm.ChatItem{{
+		Role:    "user",
+		Message: prompt.GetCommitPrompt(history, staged, stat, diff),
+	}}
+	streamer, err := utils.NewLlmStreamer(ctx, modelConf, chats, nil)
+	if err != nil {
+		return nil, err
+	}
+	_, content, err := streamer.ReadFull()
+	if err != nil {
+		return nil, err
+	}
+	return utils.ParseCommitProposal(content)
+}
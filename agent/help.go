@@ -0,0 +1,169 @@
+package agent
+
+import (
+	"bergo/config"
+	"bergo/locales"
+	"bergo/prompt"
+	"bergo/skills"
+	"bergo/tools"
+	"bergo/utils/cli"
+	"sort"
+	"strings"
+)
+
+// helpSections 生成 /help 中展示的内容，每次打开时重新生成，保证和当前加载的工具、模式、skills一致
+func (a *Agent) helpSections() []*cli.HelpSection {
+	return []*cli.HelpSection{
+		a.helpCommands(),
+		a.helpAttachments(),
+		a.helpModes(),
+		a.helpTools(),
+		a.helpSkills(),
+		a.helpConcepts(),
+		a.helpKeys(),
+	}
+}
+
+func (a *Agent) helpCommands() *cli.HelpSection {
+	section := &cli.HelpSection{
+		Key:   "commands",
+		Title: locales.Sprintf("Commands"),
+		Intro: locales.Sprintf("Type / to see completions. Custom commands come from .bergo/commands and ~/.bergo/commands."),
+	}
+	for _, item := range cli.GetCmdSuggestions() {
+		section.Entries = append(section.Entries, cli.HelpEntry{Name: item.Text, Description: item.Description})
+	}
+	return section
+}
+
+func (a *Agent) helpAttachments() *cli.HelpSection {
+	section := &cli.HelpSection{
+		Key:   "attachments",
+		Title: locales.Sprintf("@ Attachments"),
+		Intro: locales.Sprintf("Attach context to your input with @, paths must not contain spaces."),
+	}
+	for _, cmd := range cli.GetAtCmds(a.modeModel()) {
+		section.Entries = append(section.Entries, cli.HelpEntry{Name: cmd.Text + "<path>", Description: cmd.Gen()})
+	}
+	modelConf := config.GlobalConfig.GetModelConfig(a.modeModel())
+	if modelConf == nil || !modelConf.SupportVision {
+		section.Entries = append(section.Entries, cli.HelpEntry{Name: "@img:<path>", Description: locales.Sprintf("attach an image, only available when the model sets support_vision")})
+	}
+	section.Entries = append(section.Entries, cli.HelpEntry{Name: "@bergo", Description: locales.Sprintf("write @bergo in a comment of any file, the file is appended to your input automatically")})
+	return section
+}
+
+func (a *Agent) helpModes() *cli.HelpSection {
+	section := &cli.HelpSection{
+		Key:   "modes",
+		Title: locales.Sprintf("Modes"),
+		Intro: locales.Sprintf("Current mode: %v", a.agentMode),
+	}
+	// view和planner只在提示词中要求不修改文件，编辑工具仍然可用
+	section.Entries = append(section.Entries,
+		cli.HelpEntry{Name: prompt.MODE_AGENT, Description: locales.Sprintf("complete coding tasks, may edit files. tools: %v", a.modeToolNames(prompt.MODE_AGENT))},
+		cli.HelpEntry{Name: prompt.MODE_VIEW, Description: locales.Sprintf("answer questions and review code, asked not to edit files but edit tools stay available. tools: %v", a.modeToolNames(prompt.MODE_VIEW))},
+		cli.HelpEntry{Name: prompt.MODE_PLANNER, Description: locales.Sprintf("think step by step and make a plan, asked not to edit files but edit tools stay available. tools: %v", a.modeToolNames(prompt.MODE_PLANNER))},
+	)
+	for _, mode := range prompt.GetCustomModes() {
+		desc := locales.Sprintf("%v (command %v, model %v). tools: %v", orDefault(mode.Description, locales.Sprintf("custom mode")), mode.Command, orDefault(mode.Model, config.GlobalConfig.MainModel), a.modeToolNames(mode.Name))
+		section.Entries = append(section.Entries, cli.HelpEntry{Name: mode.Name, Description: desc})
+	}
+	return section
+}
+
+func (a *Agent) helpTools() *cli.HelpSection {
+	section := &cli.HelpSection{
+		Key:   "tools",
+		Title: locales.Sprintf("Tools"),
+		Intro: locales.Sprintf("Tools the model can call in this session."),
+	}
+	for _, name := range a.toolNames() {
+		desc := ""
+		if toolDesc := tools.ToolsMap[name]; toolDesc != nil && toolDesc.Schema != nil {
+			desc = strings.TrimSpace(strings.SplitN(toolDesc.Schema.Function.Description, "\n", 2)[0])
+		}
+		section.Entries = append(section.Entries, cli.HelpEntry{Name: name, Description: desc})
+	}
+	return section
+}
+
+func (a *Agent) helpSkills() *cli.HelpSection {
+	section := &cli.HelpSection{
+		Key:   "skills",
+		Title: locales.Sprintf("Skills"),
+		Intro: locales.Sprintf("Skills loaded from %v", skills.GetManager().GetSkillsPath()),
+	}
+	allSkills := skills.GetManager().GetAllSkills()
+	sort.Slice(allSkills, func(i, j int) bool {
+		return allSkills[i].Name < allSkills[j].Name
+	})
+	for _, skill := range allSkills {
+		section.Entries = append(section.Entries, cli.HelpEntry{Name: skill.Name, Description: skill.Description})
+	}
+	return section
+}
+
+func (a *Agent) helpConcepts() *cli.HelpSection {
+	return &cli.HelpSection{
+		Key:   "concepts",
+		Title: locales.Sprintf("Concepts"),
+		Entries: []cli.HelpEntry{
+			{Name: "memento", Description: locales.Sprintf("./.bergo.memento is the working memory of the model during a task. It keeps the goal, progress and todo list so the task can continue after the context is compacted or bergo exits unexpectedly.")},
+			{Name: "checkpoint", Description: locales.Sprintf("A snapshot of the workspace is saved before every task. Use /revert to go back to the last checkpoint, or /history to revert to any earlier one.")},
+			{Name: "compact", Description: locales.Sprintf("When the context reaches compact_threshold of the context window, the model writes its state to the memento file and the history is compacted. /compact does it manually.")},
+			{Name: "session", Description: locales.Sprintf("Every conversation is stored as a session, use /sessions to reload one and /clear to start a new one.")},
+		},
+	}
+}
+
+func (a *Agent) helpKeys() *cli.HelpSection {
+	return &cli.HelpSection{
+		Key:   "keys",
+		Title: locales.Sprintf("Keybindings"),
+		Entries: []cli.HelpEntry{
+			{Name: "Enter", Description: locales.Sprintf("send input, or accept the selected completion")},
+			{Name: "↑/↓", Description: locales.Sprintf("choose a completion")},
+			{Name: "ESC / Ctrl+C", Description: locales.Sprintf("clear input, press twice to exit bergo")},
+			{Name: "Ctrl+C (task)", Description: locales.Sprintf("interrupt the running task")},
+			{Name: locales.Sprintf("typing (task)"), Description: locales.Sprintf("steer the running task, press Enter to queue the message")},
+			{Name: "/multiline", Description: locales.Sprintf("multi-line input for the next message, ESC to finish")},
+		},
+	}
+}
+
+// toolNames 当前会话注册的工具，按名称排序
+func (a *Agent) toolNames() []string {
+	names := make([]string, 0, len(a.toolHandler))
+	for name := range a.toolHandler {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// modeToolNames 指定模式下可用的工具
+func (a *Agent) modeToolNames(mode string) string {
+	var names []string
+	for _, name := range a.toolNames() {
+		if toolInModeOf(mode, name) {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+func orDefault(value string, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+func helpTopics(sections []*cli.HelpSection) string {
+	var keys []string
+	for _, section := range sections {
+		keys = append(keys, section.Key)
+	}
+	return strings.Join(keys, ", ")
+}
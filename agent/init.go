@@ -0,0 +1,69 @@
+package agent
+
+import (
+	"bergo/berio"
+	"bergo/locales"
+	"bergo/prompt"
+	"bergo/tools"
+	"bergo/utils"
+	"bergo/utils/cli"
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+// initCmd /init [force] 生成或刷新项目概览，只重新生成内容有变化的目录对应的章节，force时全部重新生成。
+// 概览保存在prompt.OVERVIEW_FILE中，之后的请求会把它注入到system prompt
+func (a *Agent) initCmd(input string) (string, bool) {
+	args := strings.Fields(strings.TrimPrefix(strings.TrimSpace(input), "/init"))
+	force := false
+	switch {
+	case len(args) == 0:
+	case len(args) == 1 && args[0] == "force":
+		force = true
+	default:
+		a.output.OnSystemMsg(locales.Sprintf("usage: /init [force]"), berio.MsgTypeWarning)
+		return "", true
+	}
+	a.output.OnSystemMsg(locales.Sprintf("Bergo is generating the project overview"), berio.MsgTypeText)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cli.SetCancelFunc(cancel)
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, os.Interrupt)
+	defer func() {
+		signal.Reset(os.Interrupt)
+		close(signalChan)
+	}()
+	go func() {
+		if _, ok := <-signalChan; ok {
+			cancel()
+		}
+	}()
+	result, err := tools.GenerateOverview(ctx, a.output, force)
+	if err != nil {
+		a.output.OnSystemMsg(locales.Sprintf("generate overview failed: %v", err), berio.MsgTypeWarning)
+		// 中断前已经生成的章节已经保存，下次/init只刷新剩下的目录
+		if result != nil && len(result.Updated) > 0 {
+			a.output.OnSystemMsg(utils.InfoMessageStyle(locales.Sprintf("saved sections: %v", strings.Join(result.Updated, ", "))), berio.MsgTypeDump)
+		}
+		return "", true
+	}
+	if len(result.Updated) == 0 && len(result.Removed) == 0 && len(result.Failed) == 0 {
+		a.output.OnSystemMsg(locales.Sprintf("the overview in %v is up to date", prompt.OVERVIEW_FILE), berio.MsgTypeText)
+		return "", true
+	}
+	lines := []string{locales.Sprintf("overview saved to %v, token usage: %v", prompt.OVERVIEW_FILE, result.Usage.String())}
+	if len(result.Updated) > 0 {
+		lines = append(lines, locales.Sprintf("updated sections: %v", strings.Join(result.Updated, ", ")))
+	}
+	if len(result.Removed) > 0 {
+		lines = append(lines, locales.Sprintf("removed sections: %v", strings.Join(result.Removed, ", ")))
+	}
+	a.output.OnSystemMsg(utils.InfoMessageStyle(strings.Join(lines, "\n")), berio.MsgTypeDump)
+	if len(result.Failed) > 0 {
+		a.output.OnSystemMsg(locales.Sprintf("some sections failed and will be retried by the next /init:\n%v", strings.Join(result.Failed, "\n")), berio.MsgTypeWarning)
+	}
+	return "", true
+}
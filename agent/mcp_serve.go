@@ -0,0 +1,221 @@
+package agent
+
+import (
+	"bergo/berio"
+	"bergo/llm"
+	"bergo/mcp"
+	"bergo/tools"
+	"bergo/utils"
+	"bergo/version"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	MCP_SERVE_CHECKPOINT_LIST   = "bergo_checkpoint_list"
+	MCP_SERVE_CHECKPOINT_REVERT = "bergo_checkpoint_revert"
+	// 通过mcp-serve调用工具时传给hook的mode
+	MCP_SERVE_MODE = "mcp-serve"
+)
+
+// mcpServeForbidden 依赖agent循环或者子agent上下文的工具，不能对外提供
+var mcpServeForbidden = map[string]bool{
+	tools.TOOL_STOP_LOOP:      true,
+	tools.TOOL_EXTRACT_RESULT: true,
+	tools.TOOL_BERAG_EXTRACT:  true,
+	tools.TOOL_COMPACT:        true,
+	tools.TOOL_REPORT_FINDING: true,
+}
+
+// mcpServeWriteTools 会修改工作区的工具，调用前先保存checkpoint
+var mcpServeWriteTools = map[string]bool{
+	tools.TOOL_EDIT_DIFF:  true,
+	tools.TOOL_EDIT_WHOLE: true,
+	tools.TOOL_REMOVE:     true,
+	tools.TOOL_SHELL_CMD:  true,
+	tools.TOOL_RUN_TESTS:  true,
+	tools.TOOL_DELEGATE:   true,
+}
+
+// McpSession bergo作为MCP服务器运行时的会话，每次工具调用都记录到timeline中
+type McpSession struct {
+	sessionId string
+	timeline  *utils.Timeline
+	ignore    *utils.Ignore
+	output    berio.BerOutput
+	allowMap  map[string]bool
+	callNum   int
+	stored    bool
+}
+
+func NewMcpSession() *McpSession {
+	s := &McpSession{
+		sessionId: time.Now().Format("20060102150405"),
+		timeline:  &utils.Timeline{},
+		ignore:    utils.NewIgnore(".", []string{".gitignore", ".bergoignore"}),
+		output:    berio.NewNopOutput(),
+		allowMap:  make(map[string]bool),
+	}
+	s.timeline.Init(s.sessionId)
+	return s
+}
+
+func (s *McpSession) SessionId() string {
+	return s.sessionId
+}
+
+// NewServer 创建MCP服务器，names中的工具必须已经注册到ToolsMap
+func (s *McpSession) NewServer(names []string) (*mcp.Server, error) {
+	server := mcp.NewServer("bergo", version.Version)
+	server.Instructions = "Tools of bergo, every call is recorded in bergo session " + s.sessionId + ", files are saved to a checkpoint before they are modified."
+	for _, name := range names {
+		desc, ok := tools.ToolsMap[name]
+		if !ok || tools.ToolFuncMap[name] == nil {
+			return nil, fmt.Errorf("tool %s not found", name)
+		}
+		if mcpServeForbidden[name] || strings.HasPrefix(name, tools.MCP_TOOL_PREFIX) {
+			return nil, fmt.Errorf("tool %s can not be served", name)
+		}
+		schema, err := McpInputSchema(desc.Schema)
+		if err != nil {
+			return nil, fmt.Errorf("tool %s: %w", name, err)
+		}
+		toolName := name
+		server.AddTool(&mcp.Tool{
+			Name:        name,
+			Description: desc.Schema.Function.Description,
+			InputSchema: schema,
+		}, func(ctx context.Context, arguments json.RawMessage) *mcp.CallToolResult {
+			return s.callTool(ctx, toolName, arguments)
+		})
+	}
+	server.AddTool(&mcp.Tool{
+		Name:        MCP_SERVE_CHECKPOINT_LIST,
+		Description: "List the checkpoints saved in this session, the latest one comes last.",
+		InputSchema: json.RawMessage(`{"type":"object","properties":{}}`),
+	}, s.listCheckpoints)
+	server.AddTool(&mcp.Tool{
+		Name:        MCP_SERVE_CHECKPOINT_REVERT,
+		Description: "Revert the workspace to a checkpoint, changes made after it are discarded.",
+		InputSchema: json.RawMessage(`{"type":"object","properties":{"hash":{"type":"string","description":"hash of the checkpoint"}},"required":["hash"]}`),
+	}, s.revertCheckpoint)
+	return server, nil
+}
+
+// McpInputSchema 把工具的参数定义转换为MCP的inputSchema，与发送给模型的定义一致
+func McpInputSchema(schema *llm.ToolSchema) (json.RawMessage, error) {
+	return json.Marshal(schema.Function.Parameters)
+}
+
+// callTool 以无人值守的方式调用工具，并把调用过程记录到timeline
+func (s *McpSession) callTool(ctx context.Context, name string, arguments json.RawMessage) (result *mcp.CallToolResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = mcp.TextResult(fmt.Sprintf("tool %s panic: %v", name, r), true)
+		}
+	}()
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+	s.callNum++
+	call := &llm.ToolCall{ID: fmt.Sprintf("mcp_call_%d", s.callNum), Type: "function"}
+	call.Function.Name = name
+	call.Function.Arguments = string(arguments)
+	if err := tools.JsonSchemaExam(call); err != nil {
+		return mcp.TextResult(err.Error(), true)
+	}
+
+	if mcpServeWriteTools[name] {
+		s.timeline.InitCheckpoint()
+		s.timeline.CheckpointSave(fmt.Sprintf("before mcp call %s", name), llm.TokenUsage{})
+	}
+	summary := fmt.Sprintf("MCP client called %s %s", name, call.Function.Arguments)
+	if !s.stored {
+		utils.AddSessionItem(s.sessionId, summary)
+		s.stored = true
+	}
+	query := &utils.Query{}
+	query.SetUserInput(summary)
+	if s.timeline.CanAddQuery() {
+		s.timeline.AddUserInput(query)
+	} else {
+		s.timeline.ReplaceLastUserInput(query)
+	}
+	s.timeline.AddLLMResponse("", "", "", []*llm.ToolCall{call}, "")
+
+	input := &tools.AgentInput{
+		ToolCall: call,
+		Output:   s.output,
+		Ig:       s.ignore,
+		Timeline: s.timeline,
+		AllowMap: s.allowMap,
+	}
+	input.SetHeadless()
+	// 和主agent一样执行PreToolUse和PostToolUse hook
+	answer, err := tools.CallToolWithHooks(ctx, s.output, s.sessionId, MCP_SERVE_MODE, call, func(ctx context.Context, call *llm.ToolCall) (*tools.AgentOutput, error) {
+		return tools.ToolFuncMap[name](ctx, input), nil
+	})
+	if err == nil {
+		err = answer.Error
+	}
+	if err == nil {
+		err = answer.InterruptErr
+	}
+	if err != nil {
+		s.timeline.AddToolCallResult(call.ID, name, err.Error(), "", "")
+		s.timeline.Store()
+		return mcp.TextResult(err.Error(), true)
+	}
+	rendered := ""
+	if desc := tools.ToolsMap[name]; desc.OutputFunc != nil {
+		rendered = desc.OutputFunc(call, answer.Content)
+	}
+	s.timeline.AddToolCallResult(call.ID, name, answer.Content, answer.ImgPath, rendered)
+	s.timeline.Store()
+	return mcp.TextResult(answer.Content, false)
+}
+
+func (s *McpSession) listCheckpoints(ctx context.Context, arguments json.RawMessage) *mcp.CallToolResult {
+	lines := []string{}
+	for _, item := range s.timeline.GetHistory() {
+		if item.Type != utils.TL_CheckpointSave {
+			continue
+		}
+		commit := ""
+		if data, ok := item.Data.(*utils.CheckpointData); ok {
+			commit = data.Commit
+		}
+		lines = append(lines, fmt.Sprintf("%s %s %s", item.GitHash, time.Unix(item.Ts, 0).Format("2006-01-02 15:04:05"), commit))
+	}
+	if len(lines) == 0 {
+		return mcp.TextResult("no checkpoint saved in this session", false)
+	}
+	return mcp.TextResult(strings.Join(lines, "\n"), false)
+}
+
+func (s *McpSession) revertCheckpoint(ctx context.Context, arguments json.RawMessage) *mcp.CallToolResult {
+	params := struct {
+		Hash string `json:"hash"`
+	}{}
+	if err := json.Unmarshal(arguments, &params); err != nil || params.Hash == "" {
+		return mcp.TextResult("hash is required", true)
+	}
+	found := false
+	for _, item := range s.timeline.GetHistory() {
+		if item.Type == utils.TL_CheckpointSave && item.GitHash == params.Hash {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return mcp.TextResult(fmt.Sprintf("checkpoint %s not found in this session", params.Hash), true)
+	}
+	s.timeline.InitCheckpoint()
+	if err := s.timeline.Revert(params.Hash); err != nil {
+		return mcp.TextResult(err.Error(), true)
+	}
+	return mcp.TextResult(fmt.Sprintf("reverted to checkpoint %s", params.Hash), false)
+}
@@ -0,0 +1,223 @@
+package agent
+
+import (
+	"bergo/berio"
+	"bergo/locales"
+	"bergo/prompt"
+	"bergo/tools"
+	"bergo/utils"
+	"bergo/utils/cli"
+	"bergo/version"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	REVIEW_FORMAT_MARKDOWN = "markdown"
+	REVIEW_FORMAT_SARIF    = "sarif"
+	// fail-on为none时，bergo review不会因为发现问题而失败
+	REVIEW_FAIL_ON_NONE = "none"
+	// 交互模式下导出的审查报告保存在这里
+	REVIEW_EXPORT_DIR = ".bergo/reviews"
+)
+
+// reviewCmd /review [ref|--staged|path] 在VIEW模式下审查改动，模型通过report_finding记录问题，
+// 任务结束后在showReview中浏览和导出
+func (a *Agent) reviewCmd(input string) (string, bool) {
+	arg := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(input), "/review"))
+	git := utils.NewGit(".")
+	if !git.IsRepo() {
+		a.output.OnSystemMsg(locales.Sprintf("the workspace is not in a git repository"), berio.MsgTypeWarning)
+		return "", true
+	}
+	target, err := tools.ResolveReviewTarget(git, arg)
+	if err != nil {
+		a.output.OnSystemMsg(locales.Sprintf("review failed: %v", err), berio.MsgTypeWarning)
+		return "", true
+	}
+	a.review = utils.NewReviewReport(target.Description)
+	tools.StartReview(a.review)
+	a.cmdPrevMode = a.agentMode
+	a.agentMode = prompt.MODE_VIEW
+	return prompt.GetReviewPrompt(target.Description, target.DiffArguments(), target.Stat, false), false
+}
+
+// showReview 审查任务结束后列出问题，用户可以浏览详情或者导出为Markdown和SARIF
+func (a *Agent) showReview() {
+	report := a.review
+	if report == nil {
+		return
+	}
+	findings := report.Findings()
+	lines := []string{locales.Sprintf("review finished: %v", report.Summary())}
+	for _, f := range findings {
+		lines = append(lines, fmt.Sprintf("%s %s", f.Title(), f.Simple()))
+	}
+	a.output.OnSystemMsg(utils.InfoMessageStyle(strings.Join(lines, "\n")), berio.MsgTypeDump)
+	if len(findings) == 0 {
+		return
+	}
+	receiver := a.getCliInput()
+	for {
+		res := receiver.Select(locales.Sprintf("What to do with the review findings?"), []string{locales.Sprintf("Browse findings"), locales.Sprintf("Export Markdown"), locales.Sprintf("Export SARIF"), locales.Sprintf("Done")})
+		format := ""
+		switch res {
+		case locales.Sprintf("Browse findings"):
+			var items []cli.HistoryItem
+			for _, f := range findings {
+				items = append(items, f)
+			}
+			cli.NewHistoryList([]*cli.HistoryList{{Title: locales.Sprintf("Review findings"), Items: items}}, 0).Show()
+			continue
+		case locales.Sprintf("Export Markdown"):
+			format = REVIEW_FORMAT_MARKDOWN
+		case locales.Sprintf("Export SARIF"):
+			format = REVIEW_FORMAT_SARIF
+		default:
+			return
+		}
+		path := filepath.Join(REVIEW_EXPORT_DIR, fmt.Sprintf("review-%s.%s", time.Now().Format("20060102150405"), reviewFileExt(format)))
+		if err := WriteReviewReport(report, format, path); err != nil {
+			a.output.OnSystemMsg(locales.Sprintf("export review report failed: %v", err), berio.MsgTypeWarning)
+			continue
+		}
+		a.output.OnSystemMsg(locales.Sprintf("review report exported to %v", path), berio.MsgTypeText)
+	}
+}
+
+func reviewFileExt(format string) string {
+	if format == REVIEW_FORMAT_SARIF {
+		return "sarif"
+	}
+	return "md"
+}
+
+// ReviewReportContent 把审查结果按格式输出
+func ReviewReportContent(report *utils.ReviewReport, format string) ([]byte, error) {
+	switch format {
+	case REVIEW_FORMAT_MARKDOWN:
+		return []byte(report.Markdown()), nil
+	case REVIEW_FORMAT_SARIF:
+		return report.SARIF(version.Version)
+	}
+	return nil, fmt.Errorf("unsupported review format %s", format)
+}
+
+func WriteReviewReport(report *utils.ReviewReport, format string, path string) error {
+	content, err := ReviewReportContent(report, format)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0644)
+}
+
+// ReviewOptions bergo review的参数
+type ReviewOptions struct {
+	Config string
+	Target string
+	Format string
+	Output string
+	FailOn string
+}
+
+// ParseReviewArgs 解析 bergo review <配置文件> [--staged|ref|path] [--format markdown|sarif] [--output 文件] [--fail-on 严重程度]
+func ParseReviewArgs(args []string) (*ReviewOptions, error) {
+	opts := &ReviewOptions{Format: REVIEW_FORMAT_MARKDOWN, FailOn: utils.SEVERITY_BLOCKER}
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--staged" || arg == "--cached" {
+			positional = append(positional, "--staged")
+			continue
+		}
+		if !strings.HasPrefix(arg, "--") {
+			positional = append(positional, arg)
+			continue
+		}
+		name, value, hasValue := strings.Cut(arg, "=")
+		if !hasValue {
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("%s requires a value", name)
+			}
+			i++
+			value = args[i]
+		}
+		switch name {
+		case "--format":
+			opts.Format = value
+		case "--output":
+			opts.Output = value
+		case "--fail-on":
+			opts.FailOn = value
+		default:
+			return nil, fmt.Errorf("unknown flag %s", name)
+		}
+	}
+	// 第一个位置参数是配置文件，--staged不能作为配置文件
+	if len(positional) == 0 || positional[0] == "--staged" {
+		return nil, fmt.Errorf("config file is required")
+	}
+	opts.Config = positional[0]
+	switch len(positional) {
+	case 1:
+	case 2:
+		opts.Target = positional[1]
+	default:
+		return nil, fmt.Errorf("only one review target is allowed, got %s", strings.Join(positional[1:], " "))
+	}
+	if opts.Format != REVIEW_FORMAT_MARKDOWN && opts.Format != REVIEW_FORMAT_SARIF {
+		return nil, fmt.Errorf("unsupported review format %s", opts.Format)
+	}
+	if opts.FailOn != REVIEW_FAIL_ON_NONE && !slices.Contains(utils.ReviewSeverities, opts.FailOn) {
+		return nil, fmt.Errorf("unsupported fail-on %s, use one of %s or %s", opts.FailOn, strings.Join(utils.ReviewSeverities, ", "), REVIEW_FAIL_ON_NONE)
+	}
+	return opts, nil
+}
+
+// RunHeadlessReview 非交互地审查改动，报告写到out或者--output指定的文件，进度和总结写到stderr。
+// 返回进程退出码：0 没有阻塞性问题，1 有严重程度不低于fail-on的问题，2 审查失败
+func RunHeadlessReview(ctx context.Context, opts *ReviewOptions, out io.Writer) int {
+	git := utils.NewGit(".")
+	if !git.IsRepo() {
+		fmt.Fprintln(os.Stderr, locales.Sprintf("the workspace is not in a git repository"))
+		return 2
+	}
+	target, err := tools.ResolveReviewTarget(git, opts.Target)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, locales.Sprintf("review failed: %v", err))
+		return 2
+	}
+	fmt.Fprintln(os.Stderr, locales.Sprintf("reviewing %v", target.Description))
+	report, overview, err := tools.RunReview(ctx, target, berio.NewNopOutput())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, locales.Sprintf("review failed: %v", err))
+		return 2
+	}
+	report.Overview = overview
+	if opts.Output != "" {
+		err = WriteReviewReport(report, opts.Format, opts.Output)
+	} else {
+		var content []byte
+		if content, err = ReviewReportContent(report, opts.Format); err == nil {
+			_, err = out.Write(content)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, locales.Sprintf("export review report failed: %v", err))
+		return 2
+	}
+	fmt.Fprintln(os.Stderr, locales.Sprintf("review finished: %v", report.Summary()))
+	if opts.FailOn != REVIEW_FAIL_ON_NONE && len(report.Blocking(opts.FailOn)) > 0 {
+		return 1
+	}
+	return 0
+}
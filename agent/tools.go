@@ -14,20 +14,60 @@ func (a *Agent) initToolHandler() {
 
 	a.toolHandler[tools.TOOL_READ_FILE] = tools.ReadFile
 
+	a.toolHandler[tools.TOOL_READ_FILES] = tools.ReadFiles
+
 	a.toolHandler[tools.TOOL_REMOVE] = tools.Remove
 
 	a.toolHandler[tools.TOOL_SHELL_CMD] = tools.ShellCommand
 
 	a.toolHandler[tools.TOOL_BERAG] = tools.Berag
 
+	a.toolHandler[tools.TOOL_TODO_WRITE] = tools.TodoWrite
+
+	a.toolHandler[tools.TOOL_ASK_USER] = tools.AskUser
+
+	a.toolHandler[tools.TOOL_DELEGATE] = tools.Delegate
+
+	a.toolHandler[tools.TOOL_GIT_STATUS] = tools.GitStatus
+
+	a.toolHandler[tools.TOOL_GIT_DIFF] = tools.GitDiff
+
+	a.toolHandler[tools.TOOL_GIT_LOG] = tools.GitLog
+
+	a.toolHandler[tools.TOOL_GIT_BLAME] = tools.GitBlame
+
+	a.toolHandler[tools.TOOL_GIT_COMMIT] = tools.GitCommit
+
+	a.toolHandler[tools.TOOL_REPORT_FINDING] = tools.ReportFinding
+
+	a.toolHandler[tools.TOOL_RUN_TESTS] = tools.RunTests
+
+	a.toolHandler[tools.TOOL_SEARCH_CODE] = tools.SearchCode
+
 	// 检查模型是否支持视觉能力，如果支持则添加 read_img 工具
 	modelConf := config.GlobalConfig.GetModelConfig(config.GlobalConfig.MainModel)
 	if modelConf != nil && modelConf.SupportVision {
 		a.toolHandler[tools.TOOL_READ_IMG] = tools.ReadImg
 	}
 
+	// 配置文件中声明的工具
+	for _, toolName := range tools.CustomToolNames() {
+		a.toolHandler[toolName] = tools.ToolFuncMap[toolName]
+	}
+
+	// MCP服务器提供的工具
+	for _, toolName := range tools.McpToolNames() {
+		a.toolHandler[toolName] = tools.ToolFuncMap[toolName]
+	}
+
+	a.buildToolSchema()
+
+}
+
+// buildToolSchema 根据已注册的工具生成提供给模型的schema
+func (a *Agent) buildToolSchema() {
+	a.toolSchema = nil
 	for toolName := range a.toolHandler {
 		a.toolSchema = append(a.toolSchema, tools.ToolsMap[toolName].Schema)
 	}
-
 }
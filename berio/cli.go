@@ -1,6 +1,7 @@
 package berio
 
 import (
+	"bergo/utils"
 	"bergo/utils/cli"
 	"fmt"
 	"sync"
@@ -8,15 +9,28 @@ import (
 
 type CliOutput struct {
 	sync.Mutex
-	llmPrinter *cli.LLMPrinter
-	intents    map[string]func(string) string
+	llmPrinter  *cli.LLMPrinter
+	resumeSteer func()
+	intents     map[string]func(string) string
 }
 
+// initPrinter 流式输出窗口自己读取引导输入，显示期间暂停工具执行时的引导输入
 func (l *CliOutput) initPrinter() {
 	if l.llmPrinter == nil {
+		l.resumeSteer = cli.PauseSteerCapture()
 		l.llmPrinter = cli.NewLLMPrinter()
 	}
 }
+
+func (l *CliOutput) stopPrinter() string {
+	if l.llmPrinter == nil {
+		return ""
+	}
+	str := l.llmPrinter.Stop()
+	l.llmPrinter = nil
+	l.resumeSteer()
+	return str
+}
 func (l *CliOutput) OnLLMResponse(response string, isReasoning bool) {
 	l.Lock()
 	defer l.Unlock()
@@ -34,12 +48,7 @@ func (l *CliOutput) OnLLMResponse(response string, isReasoning bool) {
 func (l *CliOutput) Stop() string {
 	l.Lock()
 	defer l.Unlock()
-	if l.llmPrinter != nil {
-		str := l.llmPrinter.Stop()
-		l.llmPrinter = nil
-		return str
-	}
-	return ""
+	return l.stopPrinter()
 }
 func (l *CliOutput) UpdateTail(tail string) {
 	l.Lock()
@@ -53,10 +62,8 @@ func (l *CliOutput) UpdateTail(tail string) {
 func (l *CliOutput) OnSystemMsg(msg interface{}, typ int) {
 	l.Lock()
 	defer l.Unlock()
-	if l.llmPrinter != nil {
-		l.llmPrinter.Stop()
-		l.llmPrinter = nil
-	}
+	l.stopPrinter()
+	defer cli.PauseSteerCapture()()
 	if typ == MsgTypeText {
 		cli.PrintSystemText(msg.(string))
 	}
@@ -67,6 +74,11 @@ func (l *CliOutput) OnSystemMsg(msg interface{}, typ int) {
 	if typ == MsgTypeDump {
 		fmt.Println(msg.(string))
 	}
+	if typ == MsgTypeTodoList {
+		if list, ok := msg.(*utils.TodoList); ok {
+			fmt.Println(utils.TodoListStyle(list))
+		}
+	}
 }
 
 type CliInput struct {
@@ -79,12 +91,14 @@ func NewCliInput(options cli.InputOptions) BerInput {
 	}
 }
 func (r *CliInput) Read() (string, error) {
+	defer cli.PauseSteerCapture()()
 	if r.options.Multiline {
 		return cli.NewTeaInput().WithHeader(r.options.String()).ReadMultilines()
 	}
 	return cli.NewTeaInput().WithHeader(r.options.String()).Read()
 }
 func (r *CliInput) Select(prompt string, options []string) string {
+	defer cli.PauseSteerCapture()()
 	return cli.CliSelect(prompt, options, 0)
 }
 func NewCliOutput() BerOutput {
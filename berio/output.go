@@ -21,3 +21,15 @@ type BerOutput interface {
 	OnSystemMsg(msg interface{}, typ int)
 	UpdateTail(tail string)
 }
+
+// NopOutput 丢弃所有输出，用于没有终端的场景，例如作为MCP服务器运行时
+type NopOutput struct{}
+
+func (n *NopOutput) OnLLMResponse(response string, isReasoning bool) {}
+func (n *NopOutput) Stop() string                                    { return "" }
+func (n *NopOutput) OnSystemMsg(msg interface{}, typ int)            {}
+func (n *NopOutput) UpdateTail(tail string)                          {}
+
+func NewNopOutput() BerOutput {
+	return &NopOutput{}
+}
@@ -0,0 +1,148 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CommandsDir 自定义命令所在的目录名，项目下为 .bergo/commands，全局为 ~/.bergo/commands
+const CommandsDir = ".bergo/commands"
+
+// Command 由markdown模板定义的斜杠命令
+type Command struct {
+	Name        string `yaml:"-"`
+	Description string `yaml:"description"`
+	Mode        string `yaml:"mode"`  // 执行该命令时使用的模式，为空时使用当前模式
+	Model       string `yaml:"model"` // 执行该命令时使用的模型，为空时使用当前模型
+	Template    string `yaml:"-"`
+	Path        string `yaml:"-"`
+}
+
+var registry = struct {
+	sync.RWMutex
+	commands map[string]*Command
+}{commands: make(map[string]*Command)}
+
+var nameRegex = regexp.MustCompile(`^[a-zA-Z0-9]+([-_][a-zA-Z0-9]+)*$`)
+
+// placeholderRegex 匹配$ARGUMENTS和$后面的数字，占位符后面不能紧跟字母、数字或下划线。
+// 多位数字（例如$100）和超出参数个数的$n不是占位符，原样保留，避免改写"costs $5"这样的文字
+var placeholderRegex = regexp.MustCompile(`\$ARGUMENTS\b|\$[1-9][0-9]*\b`)
+
+// GetCommandDirs 返回自定义命令目录，项目目录在前，优先级更高
+func GetCommandDirs() []string {
+	dirs := []string{CommandsDir}
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(homeDir, CommandsDir))
+	}
+	return dirs
+}
+
+// LoadCommands 依次从dirs中加载*.md，同名命令以先加载的为准，返回加载失败的原因
+func LoadCommands(dirs ...string) []error {
+	var errs []error
+	registry.Lock()
+	defer registry.Unlock()
+	for _, dir := range dirs {
+		files, err := filepath.Glob(filepath.Join(dir, "*.md"))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			cmd, err := ParseCommandFile(file)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if _, ok := registry.commands[cmd.Name]; ok {
+				continue
+			}
+			registry.commands[cmd.Name] = cmd
+		}
+	}
+	return errs
+}
+
+// GetCommands 获取所有自定义命令，按名称排序
+func GetCommands() []*Command {
+	registry.RLock()
+	defer registry.RUnlock()
+	cmds := make([]*Command, 0, len(registry.commands))
+	for _, cmd := range registry.commands {
+		cmds = append(cmds, cmd)
+	}
+	sort.Slice(cmds, func(i, j int) bool {
+		return cmds[i].Name < cmds[j].Name
+	})
+	return cmds
+}
+
+// ClearCommands 清空所有自定义命令（主要用于测试）
+func ClearCommands() {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.commands = make(map[string]*Command)
+}
+
+// ParseCommandFile 解析命令模板文件，文件名为命令名，front matter可选，正文为模板
+func ParseCommandFile(path string) (*Command, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read command file: %w", err)
+	}
+	cmd := &Command{}
+	body := string(content)
+	if strings.HasPrefix(strings.TrimSpace(body), "---") {
+		parts := strings.SplitN(body, "---", 3)
+		if len(parts) < 3 {
+			return nil, fmt.Errorf("%s: invalid front matter: missing closing ---", path)
+		}
+		if err := yaml.Unmarshal([]byte(strings.TrimSpace(parts[1])), cmd); err != nil {
+			return nil, fmt.Errorf("%s: failed to parse front matter: %w", path, err)
+		}
+		body = parts[2]
+	}
+	cmd.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if !nameRegex.MatchString(cmd.Name) {
+		return nil, fmt.Errorf("%s: invalid command name %q", path, cmd.Name)
+	}
+	cmd.Template = strings.TrimSpace(body)
+	if cmd.Template == "" {
+		return nil, fmt.Errorf("%s: template is empty", path)
+	}
+	cmd.Path = path
+	return cmd, nil
+}
+
+// Expand 用参数替换模板中的占位符，$ARGUMENTS为全部参数，$1到$9为按空白分隔的第n个参数，超出参数个数时原样保留，
+// 只扫描一遍模板，参数中的占位符不会被再次替换。模板中没有占位符时，参数追加到模板末尾
+func (c *Command) Expand(arguments string) string {
+	arguments = strings.TrimSpace(arguments)
+	args := strings.Fields(arguments)
+	hasPlaceholder := false
+	result := placeholderRegex.ReplaceAllStringFunc(c.Template, func(s string) string {
+		if s == "$ARGUMENTS" {
+			hasPlaceholder = true
+			return arguments
+		}
+		idx := int(s[1] - '0')
+		if len(s) > 2 || idx > len(args) {
+			return s
+		}
+		hasPlaceholder = true
+		return args[idx-1]
+	})
+	if !hasPlaceholder && arguments != "" {
+		result += "\n\n" + arguments
+	}
+	return result
+}
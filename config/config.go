@@ -19,6 +19,38 @@ type Config struct {
 	HttpProxy         string         `toml:"http_proxy,omitempty"`
 	CompactThreshold  float64        `toml:"compact_threshold,omitempty"`
 	MaxSessionCount   int            `toml:"max_session_count,omitempty"`
+	// 编辑文件后自动运行的格式化命令，key为扩展名(如 .go)或语言(如 python)
+	Formatters map[string]string `toml:"formatters,omitempty"`
+	// agent模式下修改文件后，结束任务前自动运行的校验命令
+	Verify *VerifyConfig `toml:"verify,omitempty"`
+	// 无法交互时ask_user工具返回给模型的默认回答
+	AskUserDefaultAnswer string `toml:"ask_user_default_answer,omitempty"`
+	// delegate工具同时运行的子agent数量上限
+	DelegateConcurrency int `toml:"delegate_concurrency,omitempty"`
+	// berag提取结果缓存的条目数上限，小于0时不使用缓存
+	BeragCacheMaxEntries int `toml:"berag_cache_max_entries,omitempty"`
+	// berag提取结果缓存文件的大小上限（MB）
+	BeragCacheMaxSize int `toml:"berag_cache_max_size,omitempty"`
+	// berag同时运行的berag_extract子agent数量上限
+	BeragConcurrency int `toml:"berag_concurrency,omitempty"`
+	// 单次berag的token预算，0表示不限制
+	BeragTokenBudget int `toml:"berag_token_budget,omitempty"`
+	// 单次berag的费用预算，按模型的price_per_mil_token计算，0表示不限制
+	BeragCostBudget float64 `toml:"berag_cost_budget,omitempty"`
+	// 每个berag_extract子agent的超时时间（秒）
+	BeragExtractTimeout int `toml:"berag_extract_timeout,omitempty"`
+	// berag返回的代码片段总行数上限
+	BeragLineBudget int `toml:"berag_line_budget,omitempty"`
+	// 用户自定义的模式
+	Modes []*ModeConfig `toml:"modes,omitempty"`
+	// 生命周期钩子，在特定事件发生时执行外部命令
+	Hooks []*HookConfig `toml:"hooks,omitempty"`
+	// MCP服务器，提供的工具会注册给agent使用
+	McpServers []*McpServerConfig `toml:"mcp_servers,omitempty"`
+	// 在配置文件中声明的工具
+	CustomTools []*CustomToolConfig `toml:"tools,omitempty"`
+	// bergo mcp-serve 对外提供的工具
+	McpServeTools []string `toml:"mcp_serve_tools,omitempty"`
 
 	DeepseekApiKey   string `toml:"deepseek_api_key,omitempty"`
 	OpenaiApiKey     string `toml:"openai_api_key,omitempty"`
@@ -48,6 +80,57 @@ type ModelConfig struct {
 	SupportVision     bool    `toml:"support_vision,omitempty"`      // 是否支持图片输入
 }
 
+type VerifyConfig struct {
+	Commands []string `toml:"commands,omitempty"`
+	MaxRetry int      `toml:"max_retry,omitempty"` // 校验失败后最多让模型修复的次数
+	Timeout  int      `toml:"timeout,omitempty"`   // 单条命令超时时间（秒）
+}
+
+// ModeConfig 用户自定义模式，可以写在配置文件的[[modes]]中，也可以写在.bergo/modes/*.md的front matter中
+type ModeConfig struct {
+	Name        string   `toml:"name,omitempty" yaml:"name"`
+	Description string   `toml:"description,omitempty" yaml:"description"`
+	Prompt      string   `toml:"prompt,omitempty" yaml:"prompt"`
+	Tools       []string `toml:"tools,omitempty" yaml:"tools"`     // 允许使用的工具，为空时不限制
+	Model       string   `toml:"model,omitempty" yaml:"model"`     // 该模式下使用的模型，为空时使用main_model
+	Command     string   `toml:"command,omitempty" yaml:"command"` // 切换到该模式的命令，默认为 /name
+}
+
+type HookConfig struct {
+	Event   string `toml:"event,omitempty"`   // SessionStart、UserPromptSubmit、PreToolUse、PostToolUse、Stop
+	Command string `toml:"command,omitempty"` // 执行的命令，事件信息以JSON格式写入stdin
+	Matcher string `toml:"matcher,omitempty"` // 匹配工具名的正则，只对PreToolUse和PostToolUse生效，为空时匹配所有工具
+	Timeout int    `toml:"timeout,omitempty"` // 超时时间（秒）
+}
+
+// CustomToolConfig 在配置文件中声明的工具，参数通过校验后填入命令模板执行
+type CustomToolConfig struct {
+	Name           string   `toml:"name,omitempty"`
+	Description    string   `toml:"description,omitempty"`
+	Parameters     string   `toml:"parameters,omitempty"`       // 参数的JSON Schema，省略时没有参数
+	Command        string   `toml:"command,omitempty"`          // 命令模板，使用text/template语法，如 make test PKG={{.pkg}}
+	Stdin          bool     `toml:"stdin,omitempty"`            // 为true时参数以JSON格式写入命令的stdin
+	Timeout        int      `toml:"timeout,omitempty"`          // 超时时间（秒）
+	MaxOutputLines int      `toml:"max_output_lines,omitempty"` // 输出超过该行数时只保留最后的部分
+	Trust          bool     `toml:"trust,omitempty"`            // 为true时调用前不再询问
+	Modes          []string `toml:"modes,omitempty"`            // 可以使用该工具的模式，为空时所有模式都可以使用
+}
+
+// McpServerConfig MCP服务器配置，command和url二选一，分别对应stdio和streamable http
+type McpServerConfig struct {
+	Name      string            `toml:"name,omitempty"`
+	Command   string            `toml:"command,omitempty"`
+	Args      []string          `toml:"args,omitempty"`
+	Env       map[string]string `toml:"env,omitempty"`
+	Url       string            `toml:"url,omitempty"`
+	Headers   map[string]string `toml:"headers,omitempty"`
+	Disabled  bool              `toml:"disabled,omitempty"`
+	Trust     bool              `toml:"trust,omitempty"`     // 调用工具前不需要用户确认
+	Timeout   int               `toml:"timeout,omitempty"`   // 单次请求超时时间（秒）
+	Resources bool              `toml:"resources,omitempty"` // 是否把resources作为工具提供给模型
+	Prompts   bool              `toml:"prompts,omitempty"`   // 是否把prompts作为工具提供给模型
+}
+
 func (c *ModelConfig) ConfigMerge(userDefine *ModelConfig) {
 	if ApiKey := userDefine.ApiKey; ApiKey != "" {
 		c.ApiKey = ApiKey
@@ -120,6 +203,56 @@ func setDefault() {
 		GlobalConfig.CompactThreshold = 0.8 //默认0.8
 	}
 	// MaxSessionCount 默认为0，表示不限制session数量
+	if GlobalConfig.DelegateConcurrency == 0 {
+		GlobalConfig.DelegateConcurrency = 3
+	}
+	if GlobalConfig.BeragCacheMaxEntries == 0 {
+		GlobalConfig.BeragCacheMaxEntries = 2000
+	}
+	if GlobalConfig.BeragCacheMaxSize == 0 {
+		GlobalConfig.BeragCacheMaxSize = 16
+	}
+	if GlobalConfig.BeragConcurrency == 0 {
+		GlobalConfig.BeragConcurrency = 5
+	}
+	if GlobalConfig.BeragExtractTimeout == 0 {
+		GlobalConfig.BeragExtractTimeout = 180
+	}
+	if GlobalConfig.BeragLineBudget == 0 {
+		GlobalConfig.BeragLineBudget = 2000
+	}
+	if GlobalConfig.AskUserDefaultAnswer == "" {
+		GlobalConfig.AskUserDefaultAnswer = "用户暂时无法回答，请根据你自己的判断选择最合理的方案继续，并在最终回复中说明你做的假设"
+	}
+	if GlobalConfig.McpServeTools == nil {
+		GlobalConfig.McpServeTools = []string{"berag", "read_file", "read_files", "edit_diff", "edit_whole", "remove"}
+	}
+	for _, tool := range GlobalConfig.CustomTools {
+		if tool.Timeout == 0 {
+			tool.Timeout = 120
+		}
+		if tool.MaxOutputLines == 0 {
+			tool.MaxOutputLines = 500
+		}
+	}
+	for _, server := range GlobalConfig.McpServers {
+		if server.Timeout == 0 {
+			server.Timeout = 60
+		}
+	}
+	for _, hook := range GlobalConfig.Hooks {
+		if hook.Timeout == 0 {
+			hook.Timeout = 60
+		}
+	}
+	if GlobalConfig.Verify != nil {
+		if GlobalConfig.Verify.MaxRetry == 0 {
+			GlobalConfig.Verify.MaxRetry = 3
+		}
+		if GlobalConfig.Verify.Timeout == 0 {
+			GlobalConfig.Verify.Timeout = 300
+		}
+	}
 
 	if GlobalConfig.DeepseekApiKey != "" {
 		for _, model := range GlobalConfig.Models {
@@ -4,6 +4,7 @@ import (
 	"bergo/config"
 	"bergo/locales"
 	"context"
+	"encoding/json"
 	"fmt"
 )
 
@@ -29,14 +30,35 @@ type ToolFunctionDefinition struct {
 type ToolParameters struct {
 	Type       string                  `json:"type"`
 	Properties map[string]ToolProperty `json:"properties"`
-	Required   []string                `json:"required"`
+	Required   []string                `json:"required,omitempty"`
+	// bool或者*ToolProperty
+	AdditionalProperties interface{} `json:"additionalProperties,omitempty"`
+	// 完整的JSON Schema，设置后忽略其他字段
+	Raw json.RawMessage `json:"-"`
 }
 
 type ToolProperty struct {
-	Type        string                  `json:"type"`
-	Description string                  `json:"description"`
+	Type        string                  `json:"type,omitempty"`
+	Description string                  `json:"description,omitempty"`
 	Items       *ToolProperty           `json:"items,omitempty"`
 	Properties  map[string]ToolProperty `json:"properties,omitempty"`
+	Required    []string                `json:"required,omitempty"`
+	Enum        []interface{}           `json:"enum,omitempty"`
+	Default     interface{}             `json:"default,omitempty"`
+	Format      string                  `json:"format,omitempty"`
+	Pattern     string                  `json:"pattern,omitempty"`
+	Minimum     *float64                `json:"minimum,omitempty"`
+	Maximum     *float64                `json:"maximum,omitempty"`
+	MinLength   *int                    `json:"minLength,omitempty"`
+	MaxLength   *int                    `json:"maxLength,omitempty"`
+	MinItems    *int                    `json:"minItems,omitempty"`
+	MaxItems    *int                    `json:"maxItems,omitempty"`
+	OneOf       []*ToolProperty         `json:"oneOf,omitempty"`
+	AnyOf       []*ToolProperty         `json:"anyOf,omitempty"`
+	// bool或者*ToolProperty
+	AdditionalProperties interface{} `json:"additionalProperties,omitempty"`
+	// 完整的JSON Schema，设置后忽略其他字段
+	Raw json.RawMessage `json:"-"`
 }
 
 type Response struct {
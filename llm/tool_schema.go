@@ -0,0 +1,62 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// 模型接口要求工具参数的顶层是object，且不支持这些组合关键字
+var topLevelUnsupported = []string{"$schema", "oneOf", "anyOf", "allOf", "not", "enum"}
+
+// JSONSchema 完整的参数JSON Schema，用于校验模型给出的参数
+func (p ToolParameters) JSONSchema() (json.RawMessage, error) {
+	if len(p.Raw) > 0 {
+		if !json.Valid(p.Raw) {
+			return nil, fmt.Errorf("invalid raw json schema")
+		}
+		return p.Raw, nil
+	}
+	type plain ToolParameters
+	if p.Properties == nil {
+		p.Properties = map[string]ToolProperty{}
+	}
+	if p.Type == "" {
+		p.Type = "object"
+	}
+	return json.Marshal(plain(p))
+}
+
+// MarshalJSON 生成发送给模型的参数定义，OpenAI的parameters和Anthropic的input_schema都使用它
+// 顶层固定为object并去掉模型接口不支持的关键字，被去掉的约束仍然会在JsonSchemaExam中校验
+func (p ToolParameters) MarshalJSON() ([]byte, error) {
+	data, err := p.JSONSchema()
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Raw) == 0 {
+		return data, nil
+	}
+	schema := map[string]interface{}{}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("raw json schema must be an object: %w", err)
+	}
+	for _, key := range topLevelUnsupported {
+		delete(schema, key)
+	}
+	schema["type"] = "object"
+	if _, ok := schema["properties"]; !ok {
+		schema["properties"] = map[string]interface{}{}
+	}
+	return json.Marshal(schema)
+}
+
+func (p ToolProperty) MarshalJSON() ([]byte, error) {
+	if len(p.Raw) > 0 {
+		if !json.Valid(p.Raw) {
+			return nil, fmt.Errorf("invalid raw json schema")
+		}
+		return p.Raw, nil
+	}
+	type plain ToolProperty
+	return json.Marshal(plain(p))
+}
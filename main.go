@@ -2,9 +2,13 @@ package main
 
 import (
 	"bergo/agent"
+	"bergo/commands"
 	"bergo/config"
 	"bergo/locales"
+	"bergo/mcp"
+	"bergo/prompt"
 	"bergo/skills"
+	"bergo/tools"
 	"bergo/utils"
 	"bergo/utils/cli"
 	"bergo/wizard"
@@ -110,7 +114,105 @@ func loadSkills() {
 	_ = manager.LoadSkills()
 }
 
+// loadModes 加载配置文件和 .bergo/modes 中的自定义模式
+func loadModes() {
+	for _, err := range prompt.LoadModes(config.GlobalConfig, prompt.ModesDir) {
+		pterm.Warning.Println(locales.Sprintf("failed to load mode: %v", err))
+	}
+}
+
+// loadCommands 加载项目和用户主目录下 .bergo/commands 中的自定义命令
+func loadCommands() {
+	for _, err := range commands.LoadCommands(commands.GetCommandDirs()...) {
+		pterm.Warning.Println(locales.Sprintf("failed to load command: %v", err))
+	}
+}
+
+// loadCustomTools 注册配置文件中声明的工具
+func loadCustomTools() {
+	_, errs := tools.RegisterCustomTools(config.GlobalConfig.CustomTools)
+	for _, err := range errs {
+		pterm.Warning.Println(locales.Sprintf("failed to load tool: %v", err))
+	}
+}
+
+// loadMcpServers 连接配置的MCP服务器，并把它们提供的工具注册给agent
+func loadMcpServers() {
+	manager := mcp.GetManager()
+	for _, err := range manager.Start(context.Background(), config.GlobalConfig.McpServers) {
+		pterm.Warning.Println(locales.Sprintf("failed to connect mcp server: %v", err))
+	}
+	for _, client := range manager.Clients() {
+		names, errs := tools.RegisterMcpTools(client)
+		for _, err := range errs {
+			pterm.Warning.Println(err)
+		}
+		pterm.Info.Println(locales.Sprintf("mcp server %s connected, %d tools registered", client.Name, len(names)))
+	}
+}
+
+// runMcpServe 作为MCP服务器运行，通过stdio对外提供配置的工具
+// stdout只用于协议通信，其他输出都重定向到stderr
+func runMcpServe() {
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	pterm.SetDefaultOutput(os.Stderr)
+	if len(os.Args) > 2 {
+		if err := config.ReadConfig(os.Args[2]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if config.GlobalConfig == nil {
+		fmt.Fprintln(os.Stderr, locales.Sprintf("config is nil"))
+		os.Exit(1)
+	}
+	loadSkills()
+	loadCustomTools()
+	loadMcpServers()
+	defer mcp.GetManager().CloseAll()
+
+	session := agent.NewMcpSession()
+	server, err := session.NewServer(config.GlobalConfig.McpServeTools)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Fprintln(os.Stderr, locales.Sprintf("bergo mcp server started, session: %s", session.SessionId()))
+	if err := server.Serve(context.Background(), os.Stdin, stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// runReview 非交互地审查改动，用于pre-commit等场景，报告输出到stdout，其他输出重定向到stderr
+func runReview() {
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	pterm.SetDefaultOutput(os.Stderr)
+	opts, err := agent.ParseReviewArgs(os.Args[2:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, locales.Sprintf("usage: bergo review <config> [--staged|<ref>|<path>] [--format markdown|sarif] [--output <file>] [--fail-on blocker|major|minor|nit|none]"))
+		os.Exit(2)
+	}
+	if err := config.ReadConfig(opts.Config); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	loadSkills()
+	os.Exit(agent.RunHeadlessReview(context.Background(), opts, stdout))
+}
+
 func main() {
+	// mcp-serve 和 review 需要在输出任何内容之前处理
+	if len(os.Args) > 1 && os.Args[1] == "mcp-serve" {
+		runMcpServe()
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "review" {
+		runReview()
+		return
+	}
 	utils.EnvInit()
 	// 检查是否有init命令
 	if len(os.Args) > 1 && os.Args[1] == "init" {
@@ -129,6 +231,11 @@ func main() {
 	version.CheckAndHandleUpdates()
 
 	readConfig()
+	loadModes()
+	loadCommands()
+	loadCustomTools()
+	loadMcpServers()
+	defer mcp.GetManager().CloseAll()
 
 	// 检查session数量，如果超过配置的最大值则提示是否清空
 	checkAndCleanSessions()
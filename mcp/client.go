@@ -0,0 +1,195 @@
+package mcp
+
+import (
+	"bergo/config"
+	"bergo/version"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Client 连接到一个MCP服务器
+type Client struct {
+	Name      string
+	Conf      *config.McpServerConfig
+	Info      *InitializeResult
+	Tools     []*Tool
+	Resources []*Resource
+	Prompts   []*Prompt
+
+	transport transport
+	nextId    atomic.Int64
+}
+
+// Connect 连接服务器，完成初始化并获取工具列表，配置开启时同时获取resources和prompts
+func Connect(ctx context.Context, conf *config.McpServerConfig) (*Client, error) {
+	c := &Client{Name: conf.Name, Conf: conf}
+	switch {
+	case conf.Command != "":
+		t, err := newStdioTransport(conf.Command, conf.Args, conf.Env)
+		if err != nil {
+			return nil, fmt.Errorf("start server: %w", err)
+		}
+		c.transport = t
+	case conf.Url != "":
+		c.transport = newHttpTransport(conf.Url, conf.Headers)
+	default:
+		return nil, fmt.Errorf("either command or url is required")
+	}
+	if err := c.initialize(ctx); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) initialize(ctx context.Context) error {
+	params := &initializeParams{
+		ProtocolVersion: PROTOCOL_VERSION,
+		Capabilities:    map[string]interface{}{},
+		ClientInfo:      Implementation{Name: "bergo", Version: version.Version},
+	}
+	c.Info = &InitializeResult{}
+	if err := c.call(ctx, "initialize", params, c.Info); err != nil {
+		return fmt.Errorf("initialize: %w", err)
+	}
+	if err := c.notify(ctx, "notifications/initialized"); err != nil {
+		return fmt.Errorf("initialized notification: %w", err)
+	}
+	if err := c.listTools(ctx); err != nil {
+		return fmt.Errorf("list tools: %w", err)
+	}
+	if _, ok := c.Info.Capabilities["resources"]; ok && c.Conf.Resources {
+		if err := c.listResources(ctx); err != nil {
+			return fmt.Errorf("list resources: %w", err)
+		}
+	}
+	if _, ok := c.Info.Capabilities["prompts"]; ok && c.Conf.Prompts {
+		if err := c.listPrompts(ctx); err != nil {
+			return fmt.Errorf("list prompts: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) timeout() time.Duration {
+	if c.Conf.Timeout <= 0 {
+		return 60 * time.Second
+	}
+	return time.Duration(c.Conf.Timeout) * time.Second
+}
+
+// call 发送请求并把结果解析到result中
+func (c *Client) call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+	id := c.nextId.Add(1)
+	msg, err := c.transport.roundTrip(ctx, &rpcRequest{JsonRpc: "2.0", Id: &id, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	if msg.Error != nil {
+		return msg.Error
+	}
+	if result == nil || len(msg.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(msg.Result, result)
+}
+
+func (c *Client) notify(ctx context.Context, method string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+	_, err := c.transport.roundTrip(ctx, &rpcRequest{JsonRpc: "2.0", Method: method})
+	return err
+}
+
+func (c *Client) listTools(ctx context.Context) error {
+	cursor := ""
+	for {
+		result := &listToolsResult{}
+		if err := c.call(ctx, "tools/list", cursorParams(cursor), result); err != nil {
+			return err
+		}
+		c.Tools = append(c.Tools, result.Tools...)
+		if cursor = result.NextCursor; cursor == "" {
+			return nil
+		}
+	}
+}
+
+func (c *Client) listResources(ctx context.Context) error {
+	cursor := ""
+	for {
+		result := &listResourcesResult{}
+		if err := c.call(ctx, "resources/list", cursorParams(cursor), result); err != nil {
+			return err
+		}
+		c.Resources = append(c.Resources, result.Resources...)
+		if cursor = result.NextCursor; cursor == "" {
+			return nil
+		}
+	}
+}
+
+func (c *Client) listPrompts(ctx context.Context) error {
+	cursor := ""
+	for {
+		result := &listPromptsResult{}
+		if err := c.call(ctx, "prompts/list", cursorParams(cursor), result); err != nil {
+			return err
+		}
+		c.Prompts = append(c.Prompts, result.Prompts...)
+		if cursor = result.NextCursor; cursor == "" {
+			return nil
+		}
+	}
+}
+
+func cursorParams(cursor string) map[string]interface{} {
+	if cursor == "" {
+		return map[string]interface{}{}
+	}
+	return map[string]interface{}{"cursor": cursor}
+}
+
+// CallTool 调用服务器上的工具，arguments为JSON对象
+func (c *Client) CallTool(ctx context.Context, name string, arguments json.RawMessage) (*CallToolResult, error) {
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+	result := &CallToolResult{}
+	params := map[string]interface{}{"name": name, "arguments": arguments}
+	if err := c.call(ctx, "tools/call", params, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ReadResource 读取服务器上的resource
+func (c *Client) ReadResource(ctx context.Context, uri string) ([]*ResourceContent, error) {
+	result := &readResourceResult{}
+	if err := c.call(ctx, "resources/read", map[string]interface{}{"uri": uri}, result); err != nil {
+		return nil, err
+	}
+	return result.Contents, nil
+}
+
+// GetPrompt 获取服务器上的prompt
+func (c *Client) GetPrompt(ctx context.Context, name string, arguments map[string]string) (*GetPromptResult, error) {
+	result := &GetPromptResult{}
+	params := map[string]interface{}{"name": name, "arguments": arguments}
+	if err := c.call(ctx, "prompts/get", params, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *Client) Close() error {
+	if c.transport == nil {
+		return nil
+	}
+	return c.transport.close()
+}
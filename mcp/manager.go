@@ -0,0 +1,109 @@
+package mcp
+
+import (
+	"bergo/config"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Manager 管理所有已连接的MCP服务器
+type Manager struct {
+	mu      sync.Mutex
+	clients map[string]*Client
+}
+
+var (
+	globalManager *Manager
+	once          sync.Once
+)
+
+// GetManager 获取全局MCP管理器
+func GetManager() *Manager {
+	once.Do(func() {
+		globalManager = &Manager{
+			clients: make(map[string]*Client),
+		}
+	})
+	return globalManager
+}
+
+// Start 并行连接所有未禁用的服务器，返回连接失败的原因
+func (m *Manager) Start(ctx context.Context, confs []*config.McpServerConfig) []error {
+	var errs []error
+	var errMu sync.Mutex
+	wg := sync.WaitGroup{}
+	for _, conf := range confs {
+		if conf.Disabled {
+			continue
+		}
+		wg.Add(1)
+		go func(conf *config.McpServerConfig) {
+			defer wg.Done()
+			if _, err := m.Connect(ctx, conf); err != nil {
+				errMu.Lock()
+				errs = append(errs, err)
+				errMu.Unlock()
+			}
+		}(conf)
+	}
+	wg.Wait()
+	return errs
+}
+
+// Connect 连接一个服务器，同名服务器已经连接时直接返回
+func (m *Manager) Connect(ctx context.Context, conf *config.McpServerConfig) (*Client, error) {
+	if conf.Name == "" {
+		return nil, fmt.Errorf("mcp server name is required")
+	}
+	if client := m.GetClient(conf.Name); client != nil {
+		return client, nil
+	}
+	client, err := Connect(ctx, conf)
+	if err != nil {
+		return nil, fmt.Errorf("mcp server %s: %w", conf.Name, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clients[conf.Name] = client
+	return client, nil
+}
+
+// Disconnect 断开服务器连接
+func (m *Manager) Disconnect(name string) {
+	m.mu.Lock()
+	client := m.clients[name]
+	delete(m.clients, name)
+	m.mu.Unlock()
+	if client != nil {
+		client.Close()
+	}
+}
+
+func (m *Manager) GetClient(name string) *Client {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.clients[name]
+}
+
+// Clients 获取所有已连接的服务器，按名称排序
+func (m *Manager) Clients() []*Client {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	clients := make([]*Client, 0, len(m.clients))
+	for _, client := range m.clients {
+		clients = append(clients, client)
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].Name < clients[j].Name
+	})
+	return clients
+}
+
+// CloseAll 断开所有服务器，退出时调用
+func (m *Manager) CloseAll() {
+	for _, client := range m.Clients() {
+		m.Disconnect(client.Name)
+	}
+}
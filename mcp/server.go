@@ -0,0 +1,130 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// ToolHandler 处理客户端的工具调用，工具执行失败时返回IsError为true的结果
+type ToolHandler func(ctx context.Context, arguments json.RawMessage) *CallToolResult
+
+// Server 通过stdio按行收发消息的MCP服务器，只提供tools能力，请求按顺序处理
+type Server struct {
+	Info         Implementation
+	Instructions string
+
+	tools    []*Tool
+	handlers map[string]ToolHandler
+	writeMu  sync.Mutex
+}
+
+func NewServer(name string, version string) *Server {
+	return &Server{
+		Info:     Implementation{Name: name, Version: version},
+		handlers: make(map[string]ToolHandler),
+	}
+}
+
+// AddTool 注册一个工具，同名工具会被覆盖
+func (s *Server) AddTool(tool *Tool, handler ToolHandler) {
+	if _, ok := s.handlers[tool.Name]; !ok {
+		s.tools = append(s.tools, tool)
+	}
+	s.handlers[tool.Name] = handler
+}
+
+// TextResult 生成只包含文本的工具结果
+func TextResult(text string, isError bool) *CallToolResult {
+	return &CallToolResult{
+		Content: []*Content{{Type: "text", Text: text}},
+		IsError: isError,
+	}
+}
+
+// Serve 从r中读取请求并把响应写入w，直到r关闭或者ctx结束
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	reader := bufio.NewReader(r)
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			if reply := s.handle(ctx, line); reply != nil {
+				if werr := s.write(w, reply); werr != nil {
+					return werr
+				}
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) write(w io.Writer, reply *rpcReply) error {
+	data, err := json.Marshal(reply)
+	if err != nil {
+		return err
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+type serverRequest struct {
+	Id     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// handle 处理一条消息，通知和客户端的响应不需要回复，返回nil
+func (s *Server) handle(ctx context.Context, line []byte) *rpcReply {
+	req := &serverRequest{}
+	if err := json.Unmarshal(line, req); err != nil {
+		return &rpcReply{JsonRpc: "2.0", Id: json.RawMessage("null"), Error: &RpcError{Code: -32700, Message: "parse error"}}
+	}
+	if len(req.Id) == 0 || req.Method == "" {
+		return nil
+	}
+	reply := &rpcReply{JsonRpc: "2.0", Id: req.Id}
+	switch req.Method {
+	case "initialize":
+		reply.Result = &InitializeResult{
+			ProtocolVersion: PROTOCOL_VERSION,
+			Capabilities:    map[string]json.RawMessage{"tools": json.RawMessage("{}")},
+			ServerInfo:      s.Info,
+			Instructions:    s.Instructions,
+		}
+	case "ping":
+		reply.Result = map[string]interface{}{}
+	case "tools/list":
+		reply.Result = &listToolsResult{Tools: s.tools}
+	case "tools/call":
+		params := struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}{}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			reply.Error = &RpcError{Code: -32602, Message: "invalid params: " + err.Error()}
+			break
+		}
+		handler, ok := s.handlers[params.Name]
+		if !ok {
+			reply.Error = &RpcError{Code: -32602, Message: "unknown tool: " + params.Name}
+			break
+		}
+		reply.Result = handler(ctx, params.Arguments)
+	default:
+		reply.Error = &RpcError{Code: -32601, Message: "method not found: " + req.Method}
+	}
+	return reply
+}
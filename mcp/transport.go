@@ -0,0 +1,364 @@
+package mcp
+
+import (
+	"bergo/utils"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// transport 负责把JSON-RPC消息送到服务器，请求没有id时为通知，不等待响应
+type transport interface {
+	roundTrip(ctx context.Context, req *rpcRequest) (*rpcMessage, error)
+	close() error
+}
+
+const (
+	// 保留stdio服务器stderr的最后一部分，连接失败时展示给用户
+	STDERR_TAIL_SIZE = 4096
+	// 关闭stdio服务器时等待进程退出和输出管道关闭的时间
+	STDIO_CLOSE_TIMEOUT = 3 * time.Second
+)
+
+type tailBuffer struct {
+	sync.Mutex
+	buf []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.Lock()
+	defer t.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > STDERR_TAIL_SIZE {
+		t.buf = t.buf[len(t.buf)-STDERR_TAIL_SIZE:]
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	t.Lock()
+	defer t.Unlock()
+	return strings.TrimSpace(string(t.buf))
+}
+
+// stdioTransport 启动子进程，通过stdin/stdout按行收发消息
+type stdioTransport struct {
+	cmd     *exec.Cmd
+	cancel  context.CancelFunc
+	stdin   io.WriteCloser
+	stderr  *tailBuffer
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	pending map[int64]chan *rpcMessage
+	done    chan struct{}
+	err     error
+}
+
+func newStdioTransport(command string, args []string, env map[string]string) (*stdioTransport, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := exec.CommandContext(ctx, command, args...)
+	// 服务器通常通过npx、uvx或者sh -c启动，在单独的进程组中运行，关闭时才能终止真正的服务器
+	utils.SetProcessGroup(cmd)
+	cmd.WaitDelay = STDIO_CLOSE_TIMEOUT
+	cmd.Env = os.Environ()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+os.ExpandEnv(v))
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	t := &stdioTransport{
+		cmd:     cmd,
+		cancel:  cancel,
+		stdin:   stdin,
+		stderr:  &tailBuffer{},
+		pending: make(map[int64]chan *rpcMessage),
+		done:    make(chan struct{}),
+	}
+	cmd.Stderr = t.stderr
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, err
+	}
+	go t.readLoop(stdout)
+	return t, nil
+}
+
+func (t *stdioTransport) readLoop(stdout io.Reader) {
+	reader := bufio.NewReader(stdout)
+	var err error
+	for {
+		var line []byte
+		line, err = reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			t.dispatch(line)
+		}
+		if err != nil {
+			break
+		}
+	}
+	t.mu.Lock()
+	t.err = fmt.Errorf("server exited: %v", err)
+	if stderr := t.stderr.String(); stderr != "" {
+		t.err = fmt.Errorf("server exited: %v, stderr: %s", err, stderr)
+	}
+	t.mu.Unlock()
+	close(t.done)
+}
+
+func (t *stdioTransport) dispatch(line []byte) {
+	msg := &rpcMessage{}
+	if err := json.Unmarshal(line, msg); err != nil {
+		return
+	}
+	if msg.Method != "" {
+		if len(msg.Id) > 0 {
+			t.replyServerRequest(msg)
+		}
+		return
+	}
+	id, err := strconv.ParseInt(string(msg.Id), 10, 64)
+	if err != nil {
+		return
+	}
+	t.mu.Lock()
+	ch := t.pending[id]
+	delete(t.pending, id)
+	t.mu.Unlock()
+	if ch != nil {
+		ch <- msg
+	}
+}
+
+// replyServerRequest 回复服务器发来的请求，只支持ping
+func (t *stdioTransport) replyServerRequest(msg *rpcMessage) {
+	reply := &rpcReply{JsonRpc: "2.0", Id: msg.Id}
+	if msg.Method == "ping" {
+		reply.Result = map[string]interface{}{}
+	} else {
+		reply.Error = &RpcError{Code: -32601, Message: "method not found: " + msg.Method}
+	}
+	data, _ := json.Marshal(reply)
+	t.write(data)
+}
+
+func (t *stdioTransport) write(data []byte) error {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_, err := t.stdin.Write(append(data, '\n'))
+	return err
+}
+
+func (t *stdioTransport) roundTrip(ctx context.Context, req *rpcRequest) (*rpcMessage, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	var ch chan *rpcMessage
+	if req.Id != nil {
+		ch = make(chan *rpcMessage, 1)
+		t.mu.Lock()
+		t.pending[*req.Id] = ch
+		t.mu.Unlock()
+		defer func() {
+			t.mu.Lock()
+			delete(t.pending, *req.Id)
+			t.mu.Unlock()
+		}()
+	}
+	if err := t.write(data); err != nil {
+		return nil, t.exitErr(err)
+	}
+	if ch == nil {
+		return nil, nil
+	}
+	select {
+	case msg := <-ch:
+		return msg, nil
+	case <-t.done:
+		return nil, t.exitErr(nil)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (t *stdioTransport) exitErr(err error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err != nil {
+		return t.err
+	}
+	return err
+}
+
+func (t *stdioTransport) close() error {
+	t.stdin.Close()
+	t.cancel()
+	select {
+	case <-t.done:
+	case <-time.After(STDIO_CLOSE_TIMEOUT):
+	}
+	t.cmd.Wait()
+	return nil
+}
+
+// httpTransport streamable http，每个消息一次POST，响应可能是JSON或者SSE流
+type httpTransport struct {
+	url       string
+	headers   map[string]string
+	client    *http.Client
+	mu        sync.Mutex
+	sessionId string
+}
+
+func newHttpTransport(url string, headers map[string]string) *httpTransport {
+	return &httpTransport{
+		url:     url,
+		headers: headers,
+		client:  &http.Client{},
+	}
+}
+
+func (t *httpTransport) newRequest(ctx context.Context, method string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, t.url, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range t.headers {
+		req.Header.Set(k, os.ExpandEnv(v))
+	}
+	t.mu.Lock()
+	if t.sessionId != "" {
+		req.Header.Set("Mcp-Session-Id", t.sessionId)
+	}
+	t.mu.Unlock()
+	return req, nil
+}
+
+func (t *httpTransport) roundTrip(ctx context.Context, rpcReq *rpcRequest) (*rpcMessage, error) {
+	data, err := json.Marshal(rpcReq)
+	if err != nil {
+		return nil, err
+	}
+	req, err := t.newRequest(ctx, http.MethodPost, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if sessionId := resp.Header.Get("Mcp-Session-Id"); sessionId != "" {
+		t.mu.Lock()
+		t.sessionId = sessionId
+		t.mu.Unlock()
+	}
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("http status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if rpcReq.Id == nil {
+		return nil, nil
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return readSSEResponse(resp.Body, *rpcReq.Id)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return findResponse(body, *rpcReq.Id)
+}
+
+// readSSEResponse 读取SSE事件直到出现对应id的响应
+func readSSEResponse(body io.Reader, id int64) (*rpcMessage, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	data := bytes.NewBuffer(nil)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "data:") {
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+			data.WriteString("\n")
+			continue
+		}
+		if line != "" || data.Len() == 0 {
+			continue
+		}
+		if msg, err := findResponse(data.Bytes(), id); err == nil {
+			return msg, nil
+		}
+		data.Reset()
+	}
+	if data.Len() > 0 {
+		if msg, err := findResponse(data.Bytes(), id); err == nil {
+			return msg, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("event stream ended without response")
+}
+
+// findResponse 从单个消息或者批量消息中找到对应id的响应
+func findResponse(data []byte, id int64) (*rpcMessage, error) {
+	data = bytes.TrimSpace(data)
+	var msgs []*rpcMessage
+	if bytes.HasPrefix(data, []byte("[")) {
+		if err := json.Unmarshal(data, &msgs); err != nil {
+			return nil, err
+		}
+	} else {
+		msg := &rpcMessage{}
+		if err := json.Unmarshal(data, msg); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+	for _, msg := range msgs {
+		if msg.Method == "" && string(msg.Id) == strconv.FormatInt(id, 10) {
+			return msg, nil
+		}
+	}
+	return nil, fmt.Errorf("response of request %d not found", id)
+}
+
+func (t *httpTransport) close() error {
+	t.mu.Lock()
+	sessionId := t.sessionId
+	t.mu.Unlock()
+	if sessionId == "" {
+		return nil
+	}
+	req, err := t.newRequest(context.Background(), http.MethodDelete, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
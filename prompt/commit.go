@@ -0,0 +1,24 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+)
+
+var bergoCommitPrompt = `你是一个熟悉git的软件工程师，需要根据暂存区的改动为用户写提交信息。
+要求：
+1. 使用conventional commit的风格，比如 feat: xxx、fix(parser): xxx，但如果仓库的历史提交有自己的约定（语言、前缀、大小写、是否带范围等），以历史提交为准
+2. 第一行是不超过72个字符的标题，需要时空一行后用几行说明为什么做这个改动，不要逐个罗列文件
+3. 如果改动明显包含几件互不相关的事情，可以在split中建议拆分成多个提交，files使用staged_files中的路径，每个文件只能属于一个提交，并且所有暂存的文件都要被覆盖；不需要拆分时省略split
+4. 只输出一个JSON对象，不要输出其他内容，格式如下：
+{"message": "提交信息", "split": [{"message": "第一个提交的信息", "files": ["a.go"]}, {"message": "第二个提交的信息", "files": ["b.go"]}]}
+`
+
+// GetCommitPrompt 生成/commit请求模型写提交信息时的用户消息
+func GetCommitPrompt(history string, files []string, stat string, diff string) string {
+	if strings.TrimSpace(history) == "" {
+		history = "（仓库还没有提交）"
+	}
+	return fmt.Sprintf("%s\n<history>\n%s\n</history>\n<staged_files>\n%s\n</staged_files>\n<stat>\n%s\n</stat>\n<diff>\n%s\n</diff>",
+		bergoCommitPrompt, strings.TrimSpace(history), strings.Join(files, "\n"), strings.TrimRight(stat, "\n"), strings.TrimRight(diff, "\n"))
+}
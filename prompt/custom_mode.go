@@ -0,0 +1,149 @@
+package prompt
+
+import (
+	"bergo/config"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ModesDir 项目中自定义模式文件所在的目录
+const ModesDir = ".bergo/modes"
+
+var customModes = struct {
+	sync.RWMutex
+	modes map[string]*config.ModeConfig
+}{modes: make(map[string]*config.ModeConfig)}
+
+var modeNameRegex = regexp.MustCompile(`^[a-z0-9]+([-_][a-z0-9]+)*$`)
+
+// RegisterMode 注册一个自定义模式，不能和内置模式或者已注册的模式重名
+func RegisterMode(mode *config.ModeConfig) error {
+	if mode == nil {
+		return fmt.Errorf("mode is nil")
+	}
+	mode.Name = strings.TrimSpace(mode.Name)
+	if !modeNameRegex.MatchString(mode.Name) {
+		return fmt.Errorf("invalid mode name %q, only lowercase letters, numbers, '-' and '_' are allowed", mode.Name)
+	}
+	if _, ok := bergoModes[mode.Name]; ok {
+		return fmt.Errorf("mode %s conflicts with builtin mode", mode.Name)
+	}
+	if strings.TrimSpace(mode.Prompt) == "" {
+		return fmt.Errorf("prompt of mode %s is empty", mode.Name)
+	}
+	mode.Command = strings.TrimSpace(mode.Command)
+	if mode.Command == "" {
+		mode.Command = "/" + mode.Name
+	}
+	if !strings.HasPrefix(mode.Command, "/") {
+		mode.Command = "/" + mode.Command
+	}
+	if strings.ContainsAny(mode.Command, " \t\n") {
+		return fmt.Errorf("invalid command %q of mode %s", mode.Command, mode.Name)
+	}
+	customModes.Lock()
+	defer customModes.Unlock()
+	if _, ok := customModes.modes[mode.Name]; ok {
+		return fmt.Errorf("mode %s is already defined", mode.Name)
+	}
+	customModes.modes[mode.Name] = mode
+	return nil
+}
+
+// GetCustomMode 获取自定义模式，不存在时返回nil
+func GetCustomMode(name string) *config.ModeConfig {
+	customModes.RLock()
+	defer customModes.RUnlock()
+	return customModes.modes[name]
+}
+
+// GetCustomModes 获取所有自定义模式，按名称排序
+func GetCustomModes() []*config.ModeConfig {
+	customModes.RLock()
+	defer customModes.RUnlock()
+	modes := make([]*config.ModeConfig, 0, len(customModes.modes))
+	for _, mode := range customModes.modes {
+		modes = append(modes, mode)
+	}
+	sort.Slice(modes, func(i, j int) bool {
+		return modes[i].Name < modes[j].Name
+	})
+	return modes
+}
+
+// ClearCustomModes 清空所有自定义模式（主要用于测试）
+func ClearCustomModes() {
+	customModes.Lock()
+	defer customModes.Unlock()
+	customModes.modes = make(map[string]*config.ModeConfig)
+}
+
+func customModePrompt(name string) string {
+	mode := GetCustomMode(name)
+	if mode == nil {
+		return ""
+	}
+	return fmt.Sprintf("<mode>\n你处于%s模式。\n%s\n</mode>\n", mode.Name, strings.TrimSpace(mode.Prompt))
+}
+
+// LoadModes 加载配置文件中的[[modes]]和modesDir目录下的*.md，返回加载失败的原因
+func LoadModes(conf *config.Config, modesDir string) []error {
+	var errs []error
+	if conf != nil {
+		for _, mode := range conf.Modes {
+			if err := RegisterMode(mode); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	files, err := filepath.Glob(filepath.Join(modesDir, "*.md"))
+	if err != nil {
+		return append(errs, err)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		mode, err := ParseModeFile(file)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := RegisterMode(mode); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", file, err))
+		}
+	}
+	return errs
+}
+
+// ParseModeFile 解析模式文件，front matter中是模式的配置，正文是模式的提示词
+func ParseModeFile(path string) (*config.ModeConfig, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mode file: %w", err)
+	}
+	mode := &config.ModeConfig{}
+	body := string(content)
+	if strings.HasPrefix(strings.TrimSpace(body), "---") {
+		parts := strings.SplitN(body, "---", 3)
+		if len(parts) < 3 {
+			return nil, fmt.Errorf("%s: invalid front matter: missing closing ---", path)
+		}
+		if err := yaml.Unmarshal([]byte(strings.TrimSpace(parts[1])), mode); err != nil {
+			return nil, fmt.Errorf("%s: failed to parse front matter: %w", path, err)
+		}
+		body = parts[2]
+	}
+	if mode.Name == "" {
+		mode.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if strings.TrimSpace(body) != "" {
+		mode.Prompt = strings.TrimSpace(body)
+	}
+	return mode, nil
+}
@@ -25,6 +25,7 @@ var bergoDebugModePrompt = `<mode>
 var bergoBeragPrompt = `<mode>
 你正处于Berag模式，你是被分支出来的SubAgent,你可以看到之前的上下文。你*现在的任务*是尽量并行地调用工具，以获取对用户解决问题有用的文本。
 当你找到你想看的文件时，你可以并行地使用*berag_extract*工具来提取文件中的信息。注意该工具只作用于文件，而不是文件夹
+user_input中的<candidate_files>是本地代码索引按关键词检索到的候选文件，可以优先考虑；需要更多线索时用*search_code*按关键词检索
 当你觉得获得足够的信息后，使用*stop_loop*工具来停止流程，并通过这个工具把工作总结返回。
 如果任务是总结性质的，那么你可以在返回结果时，多做下总结
 *这个模式下只能收集信息！禁止做文件改动！*
@@ -45,6 +46,22 @@ var bergoCompactModePrompt = `<mode>
 </mode>
 `
 
+var bergoDelegatePrompt = `<mode>
+你是被主Agent委派出来的SubAgent，负责独立完成下面user_input中描述的子任务，别的SubAgent可能在并行处理其他子任务，不要做子任务范围以外的事情。
+你无法向用户提问，遇到不确定的地方按最合理的方案处理，并在总结中说明。
+完成后使用*stop_loop*工具结束流程，message中返回简洁的工作总结：做了什么，修改了哪些文件，还有哪些遗留问题。
+这个模式下你不需要维护memento file
+</mode>
+`
+
+var bergoOverviewPrompt = `<mode>
+你正处于Overview模式，你是被分支出来的SubAgent，负责为项目概览编写user_input中指定的章节。概览会在以后的对话中作为上下文提供给Agent，帮助它快速了解项目。
+使用*read_file*、*read_files*和*search_code*查看需要的文件，不用逐个读完所有文件，看懂结构和关键代码即可。
+完成后使用*stop_loop*工具结束流程，message中只返回章节的markdown内容，不要有多余的开场白。内容要具体、简洁，写出真实的文件名、类型名和命令，不要编造。
+*这个模式下只能收集信息！禁止做文件改动！*不需要维护memento file
+</mode>
+`
+
 const (
 	MODE_VIEW          = "view"
 	MODE_PLANNER       = "planner"
@@ -53,6 +70,8 @@ const (
 	MODE_BERAG         = "berag"
 	MODE_BERAG_EXTRACT = "berag_extract"
 	MODE_COMPACT       = "compact"
+	MODE_DELEGATE      = "delegate"
+	MODE_OVERVIEW      = "overview"
 )
 
 var bergoModes = map[string]string{
@@ -63,8 +82,13 @@ var bergoModes = map[string]string{
 	MODE_BERAG:         bergoBeragPrompt,
 	MODE_BERAG_EXTRACT: bergoBeragExtractPrompt,
 	MODE_COMPACT:       bergoCompactModePrompt,
+	MODE_DELEGATE:      bergoDelegatePrompt,
+	MODE_OVERVIEW:      bergoOverviewPrompt,
 }
 
 var GetModePrompt = func(mode string) string {
-	return bergoModes[mode]
+	if modePrompt, ok := bergoModes[mode]; ok {
+		return modePrompt
+	}
+	return customModePrompt(mode)
 }
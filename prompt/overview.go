@@ -0,0 +1,62 @@
+package prompt
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// OVERVIEW_FILE /init生成的项目概览，会注入到system prompt中
+const OVERVIEW_FILE = ".bergo/overview.md"
+
+var bergoOverviewDirPrompt = `为目录 %s 编写概览章节，以"## %s"作为标题，包括：
+1. 这个目录负责什么，在项目中的位置
+2. 主要的文件和它们的职责，关键的类型、函数或接口
+3. 和其他目录的依赖关系，以及修改这里的代码时需要注意的地方
+不超过40行。目录下已建索引的文件如下：
+<files>
+%s
+</files>`
+
+var bergoOverviewProjectPrompt = `为项目编写整体概览章节，以"## 项目概况"作为标题，包括以下小节：
+### 架构：项目是做什么的，由哪些部分组成，它们如何协作
+### 入口：程序的入口文件和主要的执行流程
+### 构建和测试：构建、运行、测试和格式化的命令，优先从构建配置文件(如go.mod、package.json、Makefile、Cargo.toml、pyproject.toml)和CI配置中确认
+### 约定：代码风格、命名、错误处理、测试的组织方式、注释语言等需要遵守的约定
+不超过80行。下面是根目录的文件和各目录的概览，可以用工具查看配置文件确认细节：
+<root_files>
+%s
+</root_files>
+<directories>
+%s
+</directories>`
+
+// GetOverviewDirPrompt 生成/init为单个目录编写概览时的任务描述
+func GetOverviewDirPrompt(dir string, files []string) string {
+	title := dir + "/"
+	if dir == "." {
+		title = "根目录"
+	}
+	return fmt.Sprintf(bergoOverviewDirPrompt, dir, title, strings.Join(files, "\n"))
+}
+
+// GetOverviewProjectPrompt 生成/init编写项目整体概览时的任务描述
+func GetOverviewProjectPrompt(rootFiles []string, sections []string) string {
+	return fmt.Sprintf(bergoOverviewProjectPrompt, strings.Join(rootFiles, "\n"), strings.Join(sections, "\n\n"))
+}
+
+// ReloadOverview /init更新概览文件后重新加载，之后的请求使用新的概览
+func ReloadOverview() {
+	OnceLoad.Do(func() {
+		AgentMd = loadAgentSuggestion()
+	})
+	OverviewMd = loadOverview()
+}
+
+func loadOverview() string {
+	content, err := os.ReadFile(OVERVIEW_FILE)
+	if err != nil {
+		return ""
+	}
+	return string(content)
+}
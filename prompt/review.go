@@ -0,0 +1,30 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+)
+
+var bergoReviewPrompt = `你现在要对下面的改动做代码审查(Code Review)。
+1. 使用*git_diff*工具读取改动，参数见<diff_arguments>；改动太多被截断时，按paths分批读取
+2. 需要了解上下文时，使用*berag*、*read_file*或*git_blame*等工具查看相关代码，确认问题真实存在，不要凭猜测报告
+3. 每发现一个问题就调用一次*report_finding*工具记录，行号使用改动后文件中的行号。只报告值得修改的问题，不要重复报告
+4. severity的含义：blocker 会导致错误、数据丢失或安全问题，必须修复后才能合并；major 应该修复的缺陷或明显的设计问题；minor 可以改进的地方；nit 风格、命名等细节
+5. 不能修改任何文件
+`
+
+var bergoReviewInteractiveEnd = `6. 审查完成后，用几句话总结这次改动的整体质量和最需要关注的问题，不需要重复每个问题的细节
+`
+
+var bergoReviewHeadlessEnd = `6. 你无法向用户提问。审查完成后使用*stop_loop*工具结束流程，message中用几句话总结审查结果
+`
+
+// GetReviewPrompt 生成/review和bergo review的任务描述，headless为true时要求用stop_loop结束
+func GetReviewPrompt(target string, diffArguments string, stat string, headless bool) string {
+	end := bergoReviewInteractiveEnd
+	if headless {
+		end = bergoReviewHeadlessEnd
+	}
+	return fmt.Sprintf("%s%s<target>%s</target>\n<diff_arguments>%s</diff_arguments>\n<stat>\n%s\n</stat>",
+		bergoReviewPrompt, end, target, diffArguments, strings.TrimRight(stat, "\n"))
+}
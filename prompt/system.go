@@ -25,6 +25,7 @@ func GetSystemPrompt() string {
 	// 加载agents.md文件内容（不区分大小写）
 	OnceLoad.Do(func() {
 		AgentMd = loadAgentSuggestion()
+		OverviewMd = loadOverview()
 	})
 	agentSuggestion := AgentMd
 	skillsSummary := getSkillsSummary()
@@ -46,6 +47,7 @@ func GetSystemPrompt() string {
 		"Language":        language,
 		"Workspace":       workspace,
 		"AgentSuggestion": agentSuggestion,
+		"Overview":        OverviewMd,
 		"Skills":          skillsSummary,
 		"SkillsPath":      skills.GetManager().GetSkillsPath(),
 	})
@@ -56,6 +58,7 @@ func GetSystemPrompt() string {
 }
 
 var AgentMd string
+var OverviewMd string
 var OnceLoad sync.Once
 
 // getSkillsSummary 获取 skills 摘要
@@ -128,7 +131,7 @@ var bergoSystemPrompt = `
 你需要不断在合适的时机更新这个文件，并记录如下信息进去:
 1.对之前对话和用户任务的总结，你的解决思路和关键的信息（比如你修改了哪些文件，那些文件和任务相关，以及你正在修改什么)
 2.你的解决思路和关键的信息（如修改了哪些文件，那些文件和任务相关)，还有你通过探索了解到的项目相关的知识
-3.TODO列表，你拆解出来的子任务，和每个任务的完成情况,正在做的任务应该标明，防止一个操作做到一半中途失忆
+3.TODO列表，你拆解出来的子任务，和每个任务的完成情况,正在做的任务应该标明，防止一个操作做到一半中途失忆。使用todo_write工具维护任务列表时，它会自动同步到# todo部分，不需要再手动编辑
 
 
 ## 回复
@@ -156,6 +159,13 @@ var bergoSystemPrompt = `
 {{.AgentSuggestion}}
 </suggestion>
 
+{{if .Overview}}
+## 项目概览
+下面是/init生成的项目概览，可以帮助你快速了解项目的结构、命令和约定。它可能落后于代码，和代码冲突时以代码为准。
+<overview>
+{{.Overview}}
+</overview>
+{{end}}
 {{if .Skills}}
 ## Skills
 下面是一些你可以通过阅读文档学会的Skill
@@ -0,0 +1,79 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"bergo/config"
+	"bergo/llm"
+	"bergo/tools"
+)
+
+type fakeInput struct {
+	text    string
+	err     error
+	choice  string
+	options []string
+}
+
+func (f *fakeInput) Read() (string, error) {
+	return f.text, f.err
+}
+
+func (f *fakeInput) Select(prompt string, options []string) string {
+	f.options = options
+	if f.choice == "" {
+		return options[0]
+	}
+	return f.choice
+}
+
+func TestAskUser(t *testing.T) {
+	config.GlobalConfig = &config.Config{AskUserDefaultAnswer: "use your judgement"}
+	defer func() { config.GlobalConfig = nil }()
+
+	newCall := func(args string) *llm.ToolCall {
+		call := &llm.ToolCall{}
+		call.Function.Name = tools.TOOL_ASK_USER
+		call.Function.Arguments = args
+		return call
+	}
+
+	input := &fakeInput{choice: "postgres"}
+	out := tools.AskUser(context.Background(), &tools.AgentInput{
+		ToolCall: newCall(`{"question":"which db?","options":["mysql","postgres"]}`),
+		Input:    input,
+	})
+	if out.Content != "user answered: postgres" {
+		t.Errorf("unexpected answer: %s", out.Content)
+	}
+	if len(input.options) != 2 {
+		t.Errorf("free text option should not be added: %v", input.options)
+	}
+
+	input = &fakeInput{text: "sqlite please"}
+	out = tools.AskUser(context.Background(), &tools.AgentInput{
+		ToolCall: newCall(`{"question":"which db?"}`),
+		Input:    input,
+	})
+	if out.Content != "user answered: sqlite please" {
+		t.Errorf("unexpected free text answer: %s", out.Content)
+	}
+
+	out = tools.AskUser(context.Background(), &tools.AgentInput{
+		ToolCall: newCall(`{"question":"which db?"}`),
+		Input:    &fakeInput{err: errors.New("interrupted")},
+	})
+	if !strings.Contains(out.Content, "use your judgement") {
+		t.Errorf("expected default answer on read error: %s", out.Content)
+	}
+
+	out = tools.AskUser(context.Background(), &tools.AgentInput{
+		ToolCall: newCall(`{"question":"which db?","options":["a","b"]}`),
+	})
+	if out.Error != nil || !strings.Contains(out.Content, "default answer: use your judgement") {
+		t.Errorf("expected default answer without input: %+v", out)
+	}
+}
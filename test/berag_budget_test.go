@@ -0,0 +1,66 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"bergo/config"
+	"bergo/llm"
+	"bergo/tools"
+)
+
+func TestSharedExtractBudget(t *testing.T) {
+	shared := &tools.SharedExtract{}
+	shared.UsageUpdate(llm.TokenUsage{TotalTokens: 1000000})
+	shared.CostUpdate(llm.TokenUsage{TotalTokens: 1000000}, 2)
+	if err := shared.BudgetExceeded(); err != nil {
+		t.Errorf("no budget should never be exceeded: %v", err)
+	}
+
+	shared = &tools.SharedExtract{TokenBudget: 1000}
+	shared.UsageUpdate(llm.TokenUsage{TotalTokens: 999})
+	if err := shared.BudgetExceeded(); err != nil {
+		t.Errorf("budget should not be exceeded yet: %v", err)
+	}
+	shared.UsageUpdate(llm.TokenUsage{TotalTokens: 1})
+	if err := shared.BudgetExceeded(); !errors.Is(err, tools.ErrBudgetExhausted) || !strings.Contains(err.Error(), "token usage 1000") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	shared = &tools.SharedExtract{CostBudget: 0.5}
+	shared.CostUpdate(llm.TokenUsage{TotalTokens: 200000}, 2)
+	if err := shared.BudgetExceeded(); err != nil {
+		t.Errorf("cost 0.4 should be within budget: %v", err)
+	}
+	shared.CostUpdate(llm.TokenUsage{TotalTokens: 50000}, 2)
+	if err := shared.BudgetExceeded(); !errors.Is(err, tools.ErrBudgetExhausted) || !strings.Contains(err.Error(), "cost") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestBeragExtractBudgetExhausted(t *testing.T) {
+	t.Chdir(t.TempDir())
+	config.GlobalConfig = &config.Config{BeragCacheMaxEntries: -1}
+	defer func() { config.GlobalConfig = nil }()
+
+	// 预算已经用完时不请求llm，返回说明而不是终止berag
+	shared := &tools.SharedExtract{TokenBudget: 10}
+	shared.UsageUpdate(llm.TokenUsage{TotalTokens: 20})
+	call := &llm.ToolCall{ID: "call_1"}
+	call.Function.Name = tools.TOOL_BERAG_EXTRACT
+	call.Function.Arguments = `{"file_path":"a.go"}`
+	input := &tools.AgentInput{ToolCall: call, TasKShared: shared}
+	input.SetHeadless()
+	output := tools.ToolFuncMap[tools.TOOL_BERAG_EXTRACT](context.Background(), input)
+	if output.Error != nil || output.InterruptErr != nil {
+		t.Fatalf("unexpected error: %v %v", output.Error, output.InterruptErr)
+	}
+	if !strings.HasPrefix(output.Content, "skipped: budget exhausted") {
+		t.Errorf("unexpected content: %s", output.Content)
+	}
+	if len(shared.GetAll()) != 0 {
+		t.Error("nothing should be extracted")
+	}
+}
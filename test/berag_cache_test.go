@@ -0,0 +1,143 @@
+package test
+
+import (
+	"context"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"bergo/config"
+	"bergo/llm"
+	"bergo/tools"
+	"bergo/utils"
+)
+
+func TestBeragKeywords(t *testing.T) {
+	keywords := utils.BeragKeywords("Load the Config from bergo.toml，配置加载 load")
+	want := []string{"bergo", "config", "load", "toml", "加载", "置加", "配置"}
+	if !slices.Equal(keywords, want) {
+		t.Errorf("unexpected keywords: %v", keywords)
+	}
+	if score := utils.KeywordScore([]string{"a", "b"}, []string{"b", "c"}); score < 0.33 || score > 0.34 {
+		t.Errorf("unexpected score: %v", score)
+	}
+	if utils.KeywordScore(nil, []string{"a"}) != 0 {
+		t.Error("empty keywords should not match")
+	}
+}
+
+func TestBeragCache(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.WriteFile("a.go", []byte("package a\n"), 0644)
+	os.WriteFile("b.go", []byte("package b\n"), 0644)
+	hashA, hashB := utils.FileHash("a.go"), utils.FileHash("b.go")
+	cache := utils.NewBeragCache(utils.BERAG_CACHE_FILE, 10, 0)
+	cache.Put(&utils.BeragCacheEntry{Path: "./a.go", Hash: hashA, Keywords: []string{"config", "load"}, Summary: "loads config", Items: []utils.BeragCacheItem{{StartLine: 1, EndLine: 1}}})
+	cache.Put(&utils.BeragCacheEntry{Path: "b.go", Hash: hashB, Keywords: []string{"http"}, Summary: "http server"})
+	if _, err := os.Stat(utils.BERAG_CACHE_FILE); !os.IsNotExist(err) {
+		t.Error("put should not write the cache file before flush")
+	}
+	if err := cache.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新打开，确认结果保存在工作区中
+	cache = utils.NewBeragCache(utils.BERAG_CACHE_FILE, 10, 0)
+	saved, _ := os.ReadFile(utils.BERAG_CACHE_FILE)
+	cached, hint := cache.Lookup("a.go", hashA, []string{"config", "load", "toml"})
+	if cached == nil || hint != nil || cached.Summary != "loads config" {
+		t.Fatalf("similar query should reuse the result: %+v %+v", cached, hint)
+	}
+	// 命中只更新内存中的使用时间，Flush时才写入文件
+	cached.LastUsed = 1
+	if data, _ := os.ReadFile(utils.BERAG_CACHE_FILE); string(data) != string(saved) {
+		t.Error("lookup should not rewrite the cache file")
+	}
+	if err := cache.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(utils.BERAG_CACHE_FILE); !strings.Contains(string(data), `"last_used":1}`) {
+		t.Errorf("flush should save the usage time: %s", data)
+	}
+	cached, hint = cache.Lookup("a.go", hashA, []string{"http"})
+	if cached != nil || hint == nil || hint.Summary != "loads config" {
+		t.Errorf("different query should only get a hint: %+v %+v", cached, hint)
+	}
+	// 只提示和查询相关的总结
+	hints := cache.Hints([]string{"http", "load"}, 10)
+	if len(hints) != 2 || hints[0].Path != "b.go" {
+		t.Errorf("unexpected hints: %+v", hints)
+	}
+	if hints := cache.Hints([]string{"http"}, 10); len(hints) != 1 || hints[0].Path != "b.go" {
+		t.Errorf("unrelated summary should not be hinted: %+v", hints)
+	}
+
+	// 文件改动后之前的结果失效
+	os.WriteFile("a.go", []byte("package a\n\nfunc Load() {}\n"), 0644)
+	if hints := cache.Hints([]string{"http", "load"}, 10); len(hints) != 1 || hints[0].Path != "b.go" {
+		t.Errorf("changed file should not be hinted: %+v", hints)
+	}
+	if cached, hint := cache.Lookup("a.go", utils.FileHash("a.go"), []string{"config", "load"}); cached != nil || hint != nil {
+		t.Errorf("changed file should not be reused: %+v %+v", cached, hint)
+	}
+	cache.Flush()
+	if entries, size := cache.Stats(); entries != 1 || size == 0 {
+		t.Errorf("unexpected stats: %d %d", entries, size)
+	}
+	if err := cache.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(utils.BERAG_CACHE_FILE); !os.IsNotExist(err) {
+		t.Error("cache file should be removed")
+	}
+}
+
+func TestBeragCacheEvict(t *testing.T) {
+	t.Chdir(t.TempDir())
+	cache := utils.NewBeragCache(utils.BERAG_CACHE_FILE, 2, 0)
+	for _, path := range []string{"a.go", "b.go", "c.go"} {
+		cache.Put(&utils.BeragCacheEntry{Path: path, Hash: "h", Keywords: []string{"x"}})
+	}
+	if entries, _ := cache.Stats(); entries != 2 {
+		t.Fatalf("expected 2 entries, got %d", entries)
+	}
+	if cached, _ := cache.Lookup("c.go", "h", []string{"x"}); cached == nil {
+		t.Error("the newest entry should be kept")
+	}
+	if cached, _ := cache.Lookup("a.go", "h", []string{"x"}); cached != nil {
+		t.Error("the oldest entry should be evicted")
+	}
+	// 大小限制
+	cache = utils.NewBeragCache(utils.BERAG_CACHE_FILE+".small", 0, 300)
+	for _, path := range []string{"a.go", "b.go", "c.go"} {
+		cache.Put(&utils.BeragCacheEntry{Path: path, Hash: "h", Keywords: []string{"x"}, Summary: strings.Repeat("s", 100)})
+	}
+	cache.Flush()
+	if entries, size := cache.Stats(); entries != 1 || size > 300 {
+		t.Errorf("cache should be limited by size: %d entries, %d bytes", entries, size)
+	}
+}
+
+func TestBeragExtractCached(t *testing.T) {
+	t.Chdir(t.TempDir())
+	config.GlobalConfig = &config.Config{BeragCacheMaxEntries: 100, BeragCacheMaxSize: 1}
+	defer func() { config.GlobalConfig = nil }()
+	cache := tools.GetBeragCache()
+	cache.Clear()
+	os.WriteFile("a.go", []byte("package a\n\nfunc Load() {}\n"), 0644)
+	keywords := utils.BeragKeywords("how to load config")
+	cache.Put(&utils.BeragCacheEntry{Path: "a.go", Hash: utils.FileHash("a.go"), Keywords: keywords, Summary: "loads config", Items: []utils.BeragCacheItem{{StartLine: 3, EndLine: 4}}})
+
+	call := &llm.ToolCall{}
+	call.Function.Name = tools.TOOL_BERAG_EXTRACT
+	call.Function.Arguments = `{"file_path":"a.go"}`
+	shared := &tools.SharedExtract{Keywords: keywords}
+	out := tools.BeragExtract(context.Background(), &tools.AgentInput{ToolCall: call, TasKShared: shared})
+	if out.Error != nil || !strings.Contains(out.Content, "func Load() {}") || !strings.Contains(out.Content, "loads config") {
+		t.Fatalf("unexpected output: %+v", out)
+	}
+	if items := shared.GetAll(); len(items) != 1 || items[0].Target != "a.go:3-4" {
+		t.Errorf("cached items should be shared: %+v", items)
+	}
+}
@@ -0,0 +1,102 @@
+package test
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"bergo/tools"
+)
+
+// extractLines 生成第start到end行的片段，每行内容为 文件名:行号
+func extractLines(path string, start, end int) *tools.ExtractItem {
+	content := ""
+	for i := start; i <= end; i++ {
+		content += fmt.Sprintf("%s:%d\n", path, i)
+	}
+	return &tools.ExtractItem{Path: path, Target: fmt.Sprintf("%s:%d-%d", path, start, end), Content: content, StartLine: start, EndLine: end}
+}
+
+func TestMergeExtractItems(t *testing.T) {
+	items := []*tools.ExtractItem{
+		extractLines("b.go", 1, 5),
+		extractLines("a.go", 10, 20),
+		extractLines("a.go", 15, 25),
+		extractLines("a.go", 26, 30),
+		extractLines("a.go", 10, 20),
+		extractLines("a.go", 40, 45),
+		extractLines("./b.go", 3, 4),
+		{Path: "c.go", Target: "c.go"},
+	}
+	merged, omitted := tools.MergeExtractItems(items, 0)
+	if len(omitted) != 0 {
+		t.Errorf("unexpected omitted: %v", omitted)
+	}
+	var targets []string
+	for _, item := range merged {
+		targets = append(targets, item.Target)
+	}
+	if want := []string{"a.go:10-30", "a.go:40-45", "b.go:1-5"}; !slices.Equal(targets, want) {
+		t.Fatalf("unexpected targets: %v", targets)
+	}
+	if merged[0].Content != extractLines("a.go", 10, 30).Content {
+		t.Errorf("unexpected merged content: %q", merged[0].Content)
+	}
+	if strings.Count(merged[2].Content, "\n") != 5 {
+		t.Errorf("duplicate lines should be dropped: %q", merged[2].Content)
+	}
+}
+
+func TestMergeExtractItemsBudget(t *testing.T) {
+	items := []*tools.ExtractItem{
+		extractLines("a.go", 1, 10),
+		extractLines("b.go", 1, 8),
+		extractLines("b.go", 5, 8),
+		extractLines("c.go", 1, 6),
+		extractLines("c.go", 3, 4),
+		extractLines("c.go", 6, 6),
+	}
+	// c.go被选中3次，b.go被选中2次，a.go只剩下budget-6-8=4行
+	merged, omitted := tools.MergeExtractItems(items, 18)
+	var targets []string
+	for _, item := range merged {
+		targets = append(targets, item.Target)
+	}
+	if want := []string{"a.go:1-4", "b.go:1-8", "c.go:1-6"}; !slices.Equal(targets, want) {
+		t.Errorf("unexpected targets: %v", targets)
+	}
+	if want := []string{"a.go:5-10"}; !slices.Equal(omitted, want) {
+		t.Errorf("unexpected omitted: %v", omitted)
+	}
+	if merged[0].Content != extractLines("a.go", 1, 4).Content {
+		t.Errorf("unexpected truncated content: %q", merged[0].Content)
+	}
+
+	_, omitted = tools.MergeExtractItems(items, 6)
+	if want := []string{"b.go:1-8", "a.go:1-10"}; !slices.Equal(omitted, want) {
+		t.Errorf("unexpected omitted: %v", omitted)
+	}
+}
+
+func TestMergeExtractItemsVotesBySource(t *testing.T) {
+	item := func(path string, start, end int, source string) *tools.ExtractItem {
+		e := extractLines(path, start, end)
+		e.Source = source
+		return e
+	}
+	// a.go被同一个berag_extract选中两次，只算一票；b.go被两个不同的目标选中
+	items := []*tools.ExtractItem{
+		item("a.go", 1, 8, "a.go"),
+		item("a.go", 3, 8, "a.go"),
+		item("b.go", 1, 8, "b.go"),
+		item("b.go", 1, 8, "b_test.go"),
+	}
+	merged, omitted := tools.MergeExtractItems(items, 8)
+	if len(merged) != 1 || merged[0].Target != "b.go:1-8" {
+		t.Errorf("distinct sources should win the budget: %v", merged)
+	}
+	if want := []string{"a.go:1-8"}; !slices.Equal(omitted, want) {
+		t.Errorf("unexpected omitted: %v", omitted)
+	}
+}
@@ -0,0 +1,87 @@
+package test
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"bergo/tools"
+	"bergo/utils"
+)
+
+func TestCodeTokens(t *testing.T) {
+	tokens := utils.CodeTokens("parseHTTPRequest_v2 // 解析配置 x")
+	for _, want := range []string{"parsehttprequest_v2", "parse", "http", "request", "解析", "析配", "配置"} {
+		if !slices.Contains(tokens, want) {
+			t.Errorf("%s should be a token: %v", want, tokens)
+		}
+	}
+	for _, unwanted := range []string{"x", "v", "2", "v2"} {
+		if slices.Contains(tokens, unwanted) {
+			t.Errorf("%s should not be a token: %v", unwanted, tokens)
+		}
+	}
+}
+
+func TestCodeIndex(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.MkdirAll("config", 0755)
+	os.MkdirAll("server", 0755)
+	os.MkdirAll("gen", 0755)
+	os.MkdirAll(".hidden", 0755)
+	os.WriteFile("config/load.go", []byte("package config\n\n// LoadConfig reads the config file\nfunc LoadConfig(path string) {}\n"), 0644)
+	os.WriteFile("server/http.go", []byte("package server\n\nfunc StartHTTPServer() {\n\tloadRoutes()\n}\n"), 0644)
+	os.WriteFile("gen/config.go", []byte("package gen\n\nfunc LoadConfig() {}\n"), 0644)
+	os.WriteFile(".hidden/config.go", []byte("package hidden\n\nfunc LoadConfig() {}\n"), 0644)
+	os.WriteFile("logo.bin", []byte{0, 1, 2, 'c', 'o', 'n', 'f', 'i', 'g'}, 0644)
+	os.WriteFile(".gitignore", []byte("gen/\n"), 0644)
+
+	index := utils.NewCodeIndex(".", utils.CODE_INDEX_FILE)
+	if changed, err := index.Update(); err != nil || changed != 2 || index.Len() != 2 {
+		t.Fatalf("unexpected update: %d %v, %d files", changed, err, index.Len())
+	}
+	results := index.Search("load config", "", 10)
+	if len(results) != 2 || results[0].Path != "config/load.go" || results[1].Path != "server/http.go" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if lines := results[0].Lines; len(lines) != 3 || lines[1].Line != 3 || lines[1].Text != "// LoadConfig reads the config file" {
+		t.Errorf("unexpected lines: %+v", lines)
+	}
+	if results := index.Search("http server", "./server", 10); len(results) != 1 || results[0].Path != "server/http.go" || len(results[0].Lines) < 2 || results[0].Lines[1].Text != "func StartHTTPServer() {" {
+		t.Errorf("unexpected results in server: %+v", results)
+	}
+	if results := index.Search("http", "config", 10); len(results) != 0 {
+		t.Errorf("path filter should exclude other directories: %+v", results)
+	}
+
+	// 重新打开索引，没有改动时不需要重新分词
+	index = utils.NewCodeIndex(".", utils.CODE_INDEX_FILE)
+	if changed, _ := index.Update(); changed != 0 || len(index.Search("config", "", 10)) != 1 {
+		t.Errorf("index should be loaded from disk, %d changed", changed)
+	}
+	os.WriteFile("server/http.go", []byte("package server\n\n// reload the config\nfunc Reload() {}\n"), 0644)
+	os.Remove("config/load.go")
+	if changed, _ := index.Update(); changed != 2 {
+		t.Errorf("expected 2 changed files, got %d", changed)
+	}
+	if results := index.Search("load config", "", 10); len(results) != 1 || results[0].Path != "server/http.go" {
+		t.Errorf("unexpected results after update: %+v", results)
+	}
+}
+
+func TestSearchCode(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.WriteFile("rate_limit.go", []byte("package main\n\ntype RateLimiter struct{}\n\nfunc (r *RateLimiter) Wait() {}\n"), 0644)
+	os.WriteFile("main.go", []byte("package main\n\nfunc main() {}\n"), 0644)
+	if out := callMcpTool(t, tools.TOOL_SEARCH_CODE, `{"query":""}`, nil); out.Error == nil {
+		t.Error("empty query should be rejected")
+	}
+	out := callMcpTool(t, tools.TOOL_SEARCH_CODE, `{"query":"rate limiter wait","limit":5}`, nil)
+	if out.Error != nil || !strings.HasPrefix(out.Content, "1. rate_limit.go (score ") || !strings.Contains(out.Content, "   5: func (r *RateLimiter) Wait() {}") || strings.Contains(out.Content, "main.go") {
+		t.Errorf("unexpected output: %+v", out)
+	}
+	if out := callMcpTool(t, tools.TOOL_SEARCH_CODE, `{"query":"database"}`, nil); out.Content != "no matching files" {
+		t.Errorf("unexpected output: %+v", out)
+	}
+}
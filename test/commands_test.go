@@ -0,0 +1,72 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"bergo/commands"
+)
+
+func TestCommandExpand(t *testing.T) {
+	cases := []struct {
+		template  string
+		arguments string
+		expected  string
+	}{
+		{"review $ARGUMENTS for concurrency bugs", "@file:a.go @file:b.go", "review @file:a.go @file:b.go for concurrency bugs"},
+		{"write tests for $1 in $2 style", "@file:a.go table-driven extra", "write tests for @file:a.go in table-driven style"},
+		// 超出参数个数的$n原样保留
+		{"compare $1 and $2", "only", "compare only and $2"},
+		{"it costs $5, check $1", "a.go", "it costs $5, check a.go"},
+		{"it costs $5", "a.go", "it costs $5\n\na.go"},
+		{"keep $1abc", "x", "keep $1abc\n\nx"},
+		{"explain the project", "briefly", "explain the project\n\nbriefly"},
+		{"explain the project", "", "explain the project"},
+		// 参数中的占位符不会被再次替换
+		{"fix $1 then $ARGUMENTS", "$ARGUMENTS $2", "fix $ARGUMENTS then $ARGUMENTS $2"},
+		{"summarize $ARGUMENTS", "$1 cost", "summarize $1 cost"},
+		// 多位数字是金额而不是占位符
+		{"budget is $100", "tight", "budget is $100\n\ntight"},
+	}
+	for _, c := range cases {
+		cmd := &commands.Command{Template: c.template}
+		if got := cmd.Expand(c.arguments); got != c.expected {
+			t.Errorf("Expand(%q, %q) = %q, want %q", c.template, c.arguments, got, c.expected)
+		}
+	}
+}
+
+func TestLoadCommands(t *testing.T) {
+	commands.ClearCommands()
+	defer commands.ClearCommands()
+
+	project := t.TempDir()
+	global := t.TempDir()
+	write := func(dir, name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(project, "review.md", "---\ndescription: review for concurrency bugs\nmode: view\nmodel: cheap\n---\nreview $ARGUMENTS\n")
+	write(global, "review.md", "global review $ARGUMENTS")
+	write(global, "tests.md", "write table-driven tests for $1")
+	write(global, "empty.md", "---\ndescription: nothing\n---\n")
+	write(global, "notes.txt", "not a command")
+
+	errs := commands.LoadCommands(project, global)
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error for empty template, got %v", errs)
+	}
+	cmds := commands.GetCommands()
+	if len(cmds) != 2 || cmds[0].Name != "review" || cmds[1].Name != "tests" {
+		t.Fatalf("unexpected commands: %+v", cmds)
+	}
+	review := cmds[0]
+	if review.Template != "review $ARGUMENTS" {
+		t.Errorf("project command should take precedence, got template %q", review.Template)
+	}
+	if review.Description != "review for concurrency bugs" || review.Mode != "view" || review.Model != "cheap" {
+		t.Errorf("unexpected front matter: %+v", review)
+	}
+}
@@ -0,0 +1,151 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"bergo/utils"
+)
+
+func TestParseCommitProposal(t *testing.T) {
+	proposal, err := utils.ParseCommitProposal("```json\n{\"message\": \"feat: add a\\n\\nbody\", \"split\": [{\"message\": \"feat: a\", \"files\": [\"a.go\"]}]}\n```")
+	if err != nil || proposal.Message != "feat: add a\n\nbody" || len(proposal.Split) != 1 || proposal.Split[0].Files[0] != "a.go" {
+		t.Errorf("unexpected proposal: %+v %v", proposal, err)
+	}
+	proposal, err = utils.ParseCommitProposal("fix: plain text message")
+	if err != nil || proposal.Message != "fix: plain text message" || len(proposal.Split) != 0 {
+		t.Errorf("plain text should be used as message: %+v %v", proposal, err)
+	}
+	if _, err := utils.ParseCommitProposal("  "); err == nil {
+		t.Error("empty reply should be rejected")
+	}
+}
+
+func TestValidateCommitSplit(t *testing.T) {
+	staged := []string{"a.go", "b.go", "c.go"}
+	valid := []*utils.CommitGroup{{Message: "feat: a", Files: []string{"a.go", "c.go"}}, {Message: "fix: b", Files: []string{"b.go"}}}
+	if err := utils.ValidateCommitSplit(valid, staged); err != nil {
+		t.Errorf("split should be valid: %v", err)
+	}
+	cases := [][]*utils.CommitGroup{
+		{{Message: "feat: all", Files: staged}},
+		{{Message: "feat: a", Files: []string{"a.go", "b.go"}}, {Message: "fix: b", Files: []string{"b.go", "c.go"}}},
+		{{Message: "feat: a", Files: []string{"a.go", "b.go"}}, {Message: "fix: d", Files: []string{"c.go", "d.go"}}},
+		{{Message: "feat: a", Files: []string{"a.go"}}, {Message: "fix: b", Files: []string{"b.go"}}},
+		{{Message: "feat: a", Files: []string{"a.go", "b.go"}}, {Message: " ", Files: []string{"c.go"}}},
+	}
+	for i, groups := range cases {
+		if err := utils.ValidateCommitSplit(groups, staged); err == nil {
+			t.Errorf("case %d should be rejected", i)
+		}
+	}
+}
+
+func TestGitCommitSplit(t *testing.T) {
+	t.Chdir(t.TempDir())
+	gitInRepo(t, "init", "-q", "-b", "main")
+	gitInRepo(t, "config", "user.name", "tester")
+	gitInRepo(t, "config", "user.email", "tester@example.com")
+	os.WriteFile("a.txt", []byte("a\n"), 0644)
+	os.WriteFile("b.txt", []byte("b\n"), 0644)
+	gitInRepo(t, "add", ".")
+	gitInRepo(t, "commit", "-q", "-m", "init")
+
+	// b.txt只暂存了一部分改动，拆分提交后未暂存的部分要保留在工作区
+	os.WriteFile("a.txt", []byte("a2\n"), 0644)
+	os.WriteFile("b.txt", []byte("b2\n"), 0644)
+	os.WriteFile("c.txt", []byte("c\n"), 0644)
+	gitInRepo(t, "add", "a.txt", "b.txt", "c.txt")
+	os.WriteFile("b.txt", []byte("b3\n"), 0644)
+
+	git := utils.NewGit(".")
+	staged, err := git.StagedFiles()
+	if err != nil || strings.Join(staged, ",") != "a.txt,b.txt,c.txt" {
+		t.Fatalf("unexpected staged files: %v %v", staged, err)
+	}
+	groups := []*utils.CommitGroup{{Message: "feat: update b", Files: []string{"b.txt"}}, {Message: "feat: a and c", Files: []string{"a.txt", "c.txt"}}}
+	heads, err := git.CommitSplit(groups)
+	if err != nil || len(heads) != 2 || !strings.HasSuffix(heads[0], " feat: update b") {
+		t.Fatalf("unexpected split commit: %v %v", heads, err)
+	}
+	out, _ := git.Run("show", "HEAD~1:b.txt")
+	if out != "b2\n" {
+		t.Errorf("staged content of b.txt should be committed: %q", out)
+	}
+	out, _ = git.Run("show", "--name-only", "--pretty=format:", "HEAD")
+	if strings.TrimSpace(out) != "a.txt\nc.txt" {
+		t.Errorf("unexpected files of last commit: %q", out)
+	}
+	if staged, _ := git.StagedFiles(); len(staged) != 0 {
+		t.Errorf("nothing should be left staged: %v", staged)
+	}
+	status, _ := git.Status()
+	if len(status.Files) != 1 || status.Files[0].Path != "b.txt" || status.Files[0].Worktree != 'M' {
+		t.Errorf("unstaged change of b.txt should be kept: %+v", status.Files)
+	}
+}
+
+func TestGitSubdirPaths(t *testing.T) {
+	// 工作区是仓库的子目录时，git输出的路径要转换成相对工作区的路径
+	t.Chdir(t.TempDir())
+	gitInRepo(t, "init", "-q", "-b", "main")
+	gitInRepo(t, "config", "user.name", "tester")
+	gitInRepo(t, "config", "user.email", "tester@example.com")
+	os.Mkdir("sub", 0755)
+	os.WriteFile("b.txt", []byte("b\n"), 0644)
+	os.WriteFile(filepath.Join("sub", "a.txt"), []byte("a\n"), 0644)
+	gitInRepo(t, "add", ".")
+	gitInRepo(t, "commit", "-q", "-m", "init")
+	t.Chdir("sub")
+
+	os.WriteFile("a.txt", []byte("a2\n"), 0644)
+	os.WriteFile(filepath.Join("..", "b.txt"), []byte("b2\n"), 0644)
+	git := utils.NewGit(".")
+	status, err := git.Status()
+	if err != nil || len(status.Files) != 2 || status.Files[0].Path != "../b.txt" || status.Files[1].Path != "a.txt" {
+		t.Fatalf("status paths should be relative to the workspace: %+v %v", status, err)
+	}
+	if _, err := git.Run("add", "-A", "--", "a.txt", "../b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	staged, err := git.StagedFiles()
+	if err != nil || strings.Join(staged, ",") != "../b.txt,a.txt" {
+		t.Fatalf("unexpected staged files: %v %v", staged, err)
+	}
+	groups := []*utils.CommitGroup{{Message: "feat: a", Files: []string{"a.txt"}}, {Message: "feat: b", Files: []string{"../b.txt"}}}
+	if heads, err := git.CommitSplit(groups); err != nil || len(heads) != 2 {
+		t.Fatalf("unexpected split commit: %v %v", heads, err)
+	}
+	out, _ := git.Run("show", "--name-only", "--pretty=format:", "HEAD~1")
+	if strings.TrimSpace(out) != "sub/a.txt" {
+		t.Errorf("unexpected files of first commit: %q", out)
+	}
+}
+
+func TestCheckpointChangedFiles(t *testing.T) {
+	t.Setenv("GIT_AUTHOR_NAME", "tester")
+	t.Setenv("GIT_AUTHOR_EMAIL", "tester@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "tester")
+	t.Setenv("GIT_COMMITTER_EMAIL", "tester@example.com")
+	workspace := t.TempDir()
+	os.WriteFile(filepath.Join(workspace, "a.txt"), []byte("a\n"), 0644)
+	os.WriteFile(filepath.Join(workspace, "b.txt"), []byte("b\n"), 0644)
+	checkpoint := utils.NewCheckpoint(workspace, filepath.Join(t.TempDir(), "shadow"))
+	if err := checkpoint.InitShadowRepo(); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := checkpoint.Save("before")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(workspace, "a.txt"), []byte("a2\n"), 0644)
+	os.Remove(filepath.Join(workspace, "b.txt"))
+	os.MkdirAll(filepath.Join(workspace, "dir"), 0755)
+	os.WriteFile(filepath.Join(workspace, "dir", "new file.txt"), []byte("c\n"), 0644)
+	files, err := checkpoint.ChangedFiles(hash)
+	if err != nil || strings.Join(files, ",") != "a.txt,b.txt,dir/new file.txt" {
+		t.Errorf("unexpected changed files: %v %v", files, err)
+	}
+}
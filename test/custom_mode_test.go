@@ -0,0 +1,75 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"bergo/config"
+	"bergo/prompt"
+)
+
+func TestLoadCustomModes(t *testing.T) {
+	prompt.ClearCustomModes()
+	defer prompt.ClearCustomModes()
+
+	dir := t.TempDir()
+	reviewer := `---
+description: review the code
+tools: [read_file, shell_cmd]
+model: cheap
+---
+你是一个严格的代码审查者，只指出问题，不修改文件。
+`
+	if err := os.WriteFile(filepath.Join(dir, "reviewer.md"), []byte(reviewer), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "view.md"), []byte("冲突的模式"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "empty.md"), []byte("---\ncommand: e\n---\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &config.Config{
+		Modes: []*config.ModeConfig{
+			{Name: "docs", Prompt: "只编写文档", Command: "write-docs"},
+		},
+	}
+	errs := prompt.LoadModes(conf, dir)
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors (builtin conflict and empty prompt), got %v", errs)
+	}
+
+	modes := prompt.GetCustomModes()
+	if len(modes) != 2 || modes[0].Name != "docs" || modes[1].Name != "reviewer" {
+		t.Fatalf("unexpected modes: %+v", modes)
+	}
+	if modes[0].Command != "/write-docs" {
+		t.Errorf("command should be prefixed with /, got %q", modes[0].Command)
+	}
+
+	mode := prompt.GetCustomMode("reviewer")
+	if mode.Command != "/reviewer" || mode.Model != "cheap" || mode.Description != "review the code" {
+		t.Errorf("unexpected reviewer mode: %+v", mode)
+	}
+	if len(mode.Tools) != 2 || mode.Tools[0] != "read_file" {
+		t.Errorf("unexpected tools: %v", mode.Tools)
+	}
+
+	modePrompt := prompt.GetModePrompt("reviewer")
+	if !strings.Contains(modePrompt, "你处于reviewer模式") || !strings.Contains(modePrompt, "严格的代码审查者") {
+		t.Errorf("unexpected mode prompt: %s", modePrompt)
+	}
+	if prompt.GetModePrompt(prompt.MODE_VIEW) == "" {
+		t.Error("builtin mode prompt should not be affected")
+	}
+
+	if err := prompt.RegisterMode(&config.ModeConfig{Name: "docs", Prompt: "again"}); err == nil {
+		t.Error("duplicated mode should be rejected")
+	}
+	if err := prompt.RegisterMode(&config.ModeConfig{Name: "Bad Name", Prompt: "x"}); err == nil {
+		t.Error("invalid mode name should be rejected")
+	}
+}
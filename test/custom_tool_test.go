@@ -0,0 +1,88 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"bergo/config"
+	"bergo/llm"
+	"bergo/tools"
+)
+
+func TestCustomTools(t *testing.T) {
+	confs := []*config.CustomToolConfig{
+		{
+			Name:           "say",
+			Description:    "echo the word",
+			Parameters:     `{"type":"object","properties":{"word":{"type":"string","enum":["hi","a'b; echo pwned"]},"times":{"type":"integer","minimum":1}},"required":["word"]}`,
+			Command:        "echo {{.word}} {{.times}}",
+			Timeout:        10,
+			MaxOutputLines: 500,
+			Modes:          []string{"agent"},
+		},
+		{Name: "stdin_tool", Command: "cat", Stdin: true, Timeout: 10, MaxOutputLines: 500},
+		{Name: "long", Command: "seq 1 10", Timeout: 10, MaxOutputLines: 3, Trust: true},
+		{Name: "slow", Command: "sleep 5; echo done", Timeout: 1, MaxOutputLines: 500},
+		{Name: "shell_cmd", Command: "echo"},
+		{Name: "bad name", Command: "echo"},
+		{Name: "no_command"},
+	}
+	names, errs := tools.RegisterCustomTools(confs)
+	defer tools.UnregisterCustomTools()
+	if len(names) != 4 || len(errs) != 3 {
+		t.Fatalf("unexpected registration: %v %v", names, errs)
+	}
+	if modes := tools.CustomToolModes("say"); len(modes) != 1 || modes[0] != "agent" {
+		t.Errorf("unexpected modes: %v", modes)
+	}
+
+	if out := callMcpTool(t, "say", `{}`, nil); out.Error == nil || !strings.Contains(out.Error.Error(), "validate") {
+		t.Errorf("missing required parameter should be rejected: %+v", out)
+	}
+	out := callMcpTool(t, "say", `{"word":"hi","times":2}`, nil)
+	if out.Error != nil || out.Content != "result:\nhi 2" {
+		t.Errorf("unexpected output: %+v", out)
+	}
+	out = callMcpTool(t, "say", `{"word":"a'b; echo pwned"}`, nil)
+	if out.Error != nil || out.Content != "result:\na'b; echo pwned" {
+		t.Errorf("arguments should be quoted: %+v", out)
+	}
+	if out := callMcpTool(t, "say", `{"word":"hi"}`, &fakeInput{choice: "Skip"}); out.Error == nil {
+		t.Error("skip should refuse the call")
+	}
+	out = callMcpTool(t, "stdin_tool", `{"x":1}`, nil)
+	if out.Content != `result:
+{"x":1}` {
+		t.Errorf("arguments should be written to stdin: %+v", out)
+	}
+	out = callMcpTool(t, "long", `{}`, &fakeInput{choice: "Skip"})
+	if out.Error != nil || !strings.HasSuffix(out.Content, "8\n9\n10") || !strings.Contains(out.Content, "7 lines omitted") {
+		t.Errorf("trusted tool should run and output should be truncated: %+v", out)
+	}
+	start := time.Now()
+	if out := callMcpTool(t, "slow", `{}`, nil); out.Error == nil || !strings.Contains(out.Error.Error(), "timed out") {
+		t.Errorf("slow command should time out: %+v", out)
+	}
+	if elapsed := time.Since(start); elapsed > 4*time.Second {
+		t.Errorf("timed out command should be killed, took %v", elapsed)
+	}
+	// 用户中断时不等待命令结束
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	call := &llm.ToolCall{}
+	call.Function.Name = "slow"
+	call.Function.Arguments = `{}`
+	if out := tools.ToolFuncMap["slow"](ctx, &tools.AgentInput{ToolCall: call}); out.Error == nil || !strings.Contains(out.Error.Error(), "interrupted") {
+		t.Errorf("canceled command should be interrupted: %+v", out)
+	}
+
+	tools.UnregisterCustomTools()
+	if _, ok := tools.ToolsMap["say"]; ok || len(tools.CustomToolNames()) != 0 {
+		t.Error("tools should be unregistered")
+	}
+	if _, ok := tools.ToolsMap[tools.TOOL_SHELL_CMD]; !ok {
+		t.Error("builtin tool should be kept")
+	}
+}
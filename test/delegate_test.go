@@ -0,0 +1,87 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"bergo/config"
+	"bergo/llm"
+	"bergo/tools"
+)
+
+func TestDelegateValidate(t *testing.T) {
+	config.GlobalConfig = &config.Config{
+		MainModel: "main",
+		Models:    []*config.ModelConfig{{Identifier: "main"}},
+	}
+	defer func() { config.GlobalConfig = nil }()
+
+	testCases := []struct {
+		args string
+		err  string
+	}{
+		{args: `{"subtasks":[]}`, err: "subtasks is empty"},
+		{args: `{"subtasks":[{"task":"  "}]}`, err: "task is empty"},
+		{args: `{"subtasks":[{"task":"a","mode":"berag"}]}`, err: "unsupported mode"},
+		{args: `{"subtasks":[{"task":"a","context":"copy"}]}`, err: "context must be"},
+		{args: `{"subtasks":[{"task":"a","model":"unknown"}]}`, err: "model unknown not found"},
+		{args: `{"subtasks":[{"task":"a","tools":["delegate"]}]}`, err: "not available for sub agents"},
+		{args: `{"subtasks":[{"task":"a","mode":"view","tools":["edit_diff"]}]}`, err: "not allowed in view mode"},
+	}
+	for _, tc := range testCases {
+		call := &llm.ToolCall{}
+		call.Function.Name = tools.TOOL_DELEGATE
+		call.Function.Arguments = tc.args
+		out := tools.Delegate(context.Background(), &tools.AgentInput{ToolCall: call})
+		if out.Error == nil || !strings.Contains(out.Error.Error(), tc.err) {
+			t.Errorf("args %s: expected error %q, got %v", tc.args, tc.err, out.Error)
+		}
+	}
+}
+
+func TestDelegateSkip(t *testing.T) {
+	config.GlobalConfig = &config.Config{
+		MainModel: "main",
+		Models:    []*config.ModelConfig{{Identifier: "main"}},
+	}
+	defer func() { config.GlobalConfig = nil }()
+
+	call := &llm.ToolCall{}
+	call.Function.Name = tools.TOOL_DELEGATE
+	call.Function.Arguments = `{"subtasks":[{"task":"write tests for package utils"}]}`
+	input := &fakeInput{choice: "Skip"}
+	out := tools.Delegate(context.Background(), &tools.AgentInput{ToolCall: call, Input: input, AllowMap: map[string]bool{}})
+	if out.Error == nil || !strings.Contains(out.Error.Error(), "not to delegate") {
+		t.Errorf("expected user skip error, got %v", out.Error)
+	}
+	if len(input.options) != 3 {
+		t.Errorf("expected confirm options, got %v", input.options)
+	}
+}
+
+func TestDelegateConfirmUntrustedTool(t *testing.T) {
+	config.GlobalConfig = &config.Config{
+		MainModel: "main",
+		Models:    []*config.ModelConfig{{Identifier: "main"}},
+	}
+	defer func() { config.GlobalConfig = nil }()
+	tools.RegisterCustomTools([]*config.CustomToolConfig{
+		{Name: "deploy", Command: "echo deploy", Timeout: 10, MaxOutputLines: 500},
+		{Name: "lint", Command: "echo lint", Timeout: 10, MaxOutputLines: 500, Trust: true},
+	})
+	defer tools.UnregisterCustomTools()
+
+	if !tools.IsUntrustedExternalTool("deploy") || tools.IsUntrustedExternalTool("lint") || !tools.IsUntrustedExternalTool("mcp__fs__write") {
+		t.Error("unexpected trust of external tools")
+	}
+	// 子agent中不会询问，启动前需要确认
+	call := &llm.ToolCall{}
+	call.Function.Name = tools.TOOL_DELEGATE
+	call.Function.Arguments = `{"subtasks":[{"task":"deploy the service","mode":"view","tools":["deploy"]}]}`
+	input := &fakeInput{choice: "Skip"}
+	out := tools.Delegate(context.Background(), &tools.AgentInput{ToolCall: call, Input: input, AllowMap: map[string]bool{}})
+	if out.Error == nil || !strings.Contains(out.Error.Error(), "not to delegate") || len(input.options) != 3 {
+		t.Errorf("untrusted custom tool should be confirmed: %v %v", out.Error, input.options)
+	}
+}
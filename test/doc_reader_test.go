@@ -0,0 +1,166 @@
+package test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"bergo/utils"
+)
+
+// buildPdf 生成只有一页文字的最小pdf
+func buildPdf(text string) []byte {
+	stream := fmt.Sprintf("BT /F1 12 Tf 72 712 Td (%s) Tj ET", text)
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+	buf := bytes.NewBufferString("%PDF-1.4\n")
+	var offsets []int
+	for i, obj := range objects {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+func TestReadPdf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spec.pdf")
+	os.WriteFile(path, buildPdf("Hello PDF"), 0644)
+	r := utils.ReadFile{Path: path, WithLineNum: true}
+	lines, err := r.ReadFile()
+	if err != nil {
+		t.Fatalf("read pdf failed: %v", err)
+	}
+	content := strings.Join(lines, "")
+	if !strings.Contains(content, "1|--- page 1 ---") || !strings.Contains(content, "Hello PDF") {
+		t.Errorf("unexpected pdf content:\n%s", content)
+	}
+}
+
+func TestReadMalformedPdf(t *testing.T) {
+	// 把第3个对象在xref中的偏移改到文件末尾之后，pdf库读取页面时会panic
+	data := buildPdf("Hello PDF")
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		if line == "xref" {
+			lines[i+5] = "0000009999 00000 n "
+			break
+		}
+	}
+	path := filepath.Join(t.TempDir(), "broken.pdf")
+	os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644)
+	r := utils.ReadFile{Path: path, WithLineNum: true}
+	if _, err := r.ReadFile(); err == nil || !strings.Contains(err.Error(), "broken.pdf") {
+		t.Errorf("expected parse error, got %v", err)
+	}
+}
+
+func TestReadNotebook(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "demo.ipynb")
+	nb := `{"cells":[
+		{"cell_type":"markdown","source":["# Title\n","text"]},
+		{"cell_type":"code","source":"print(1)","outputs":[
+			{"output_type":"stream","name":"stdout","text":["1\n"]},
+			{"output_type":"display_data","data":{"image/png":"aGVsbG8=","text/plain":["<Figure>"]}},
+			{"output_type":"error","ename":"ValueError","evalue":"bad","traceback":["\u001b[0;31mValueError\u001b[0m: bad"]}
+		]}
+	]}`
+	os.WriteFile(path, []byte(nb), 0644)
+	imgDir := filepath.Join(dir, "images")
+	r := utils.ReadFile{Path: path, ImageDir: imgDir}
+	lines, err := r.ReadFile()
+	if err != nil {
+		t.Fatalf("read notebook failed: %v", err)
+	}
+	content := strings.Join(lines, "")
+	for _, want := range []string{
+		"--- cell 1 (markdown) ---\n# Title\ntext\n",
+		"--- cell 2 (code) ---\nprint(1)\n--- output ---\n1\n",
+		"use read_img to view it",
+		"ValueError: bad\nValueError: bad\n",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("notebook missing %q, got:\n%s", want, content)
+		}
+	}
+	images, _ := filepath.Glob(filepath.Join(imgDir, "*.png"))
+	if len(images) != 1 {
+		t.Fatalf("expected 1 saved image, got %v", images)
+	}
+	if data, _ := os.ReadFile(images[0]); string(data) != "hello" {
+		t.Errorf("unexpected image content %q", data)
+	}
+}
+
+func TestReadArchive(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"src/main.go": "package main\nfunc main() {}\n",
+		"README":      "readme",
+	}
+
+	zipPath := filepath.Join(dir, "a.zip")
+	zipBuf := bytes.NewBuffer(nil)
+	zw := zip.NewWriter(zipBuf)
+	for name, content := range files {
+		w, _ := zw.Create(name)
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	os.WriteFile(zipPath, zipBuf.Bytes(), 0644)
+
+	tgzPath := filepath.Join(dir, "a.tar.gz")
+	tgzBuf := bytes.NewBuffer(nil)
+	gw := gzip.NewWriter(tgzBuf)
+	tw := tar.NewWriter(gw)
+	for name, content := range files {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gw.Close()
+	os.WriteFile(tgzPath, tgzBuf.Bytes(), 0644)
+
+	for _, path := range []string{zipPath, tgzPath} {
+		r := utils.ReadFile{Path: path}
+		lines, err := r.ReadFile()
+		if err != nil {
+			t.Fatalf("list %s failed: %v", path, err)
+		}
+		listing := strings.Join(lines, "")
+		if !strings.Contains(listing, "2 entries") || !strings.Contains(listing, "src/main.go\t28 bytes") {
+			t.Errorf("unexpected listing of %s:\n%s", path, listing)
+		}
+
+		r = utils.ReadFile{Path: path, Entry: "src/main.go", WithLineNum: true}
+		lines, err = r.ReadFileTruncated(2, 2)
+		if err != nil {
+			t.Fatalf("read entry of %s failed: %v", path, err)
+		}
+		if strings.Join(lines, "") != "2|func main() {}\n" {
+			t.Errorf("unexpected entry content of %s: %q", path, lines)
+		}
+
+		r = utils.ReadFile{Path: path, Entry: "missing"}
+		if _, err := r.ReadFile(); err == nil {
+			t.Errorf("expected error for missing entry of %s", path)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bergo/config"
+	"bergo/utils"
+)
+
+func TestFormatterCommand(t *testing.T) {
+	config.GlobalConfig = &config.Config{
+		Formatters: map[string]string{
+			".go":    "gofmt -w",
+			"python": "ruff format",
+			"rs":     "rustfmt",
+		},
+	}
+	defer func() { config.GlobalConfig = nil }()
+
+	testCases := []struct {
+		path     string
+		expected string
+	}{
+		{path: "main.go", expected: "gofmt -w"},
+		{path: "a/b/script.py", expected: "ruff format"},
+		{path: "lib.rs", expected: "rustfmt"},
+		{path: "README.md", expected: ""},
+	}
+	for _, tc := range testCases {
+		f := &utils.Formatter{Path: tc.path}
+		if got := f.Command(); got != tc.expected {
+			t.Errorf("%s: expected %q, got %q", tc.path, tc.expected, got)
+		}
+	}
+}
+
+func TestFormatterFormat(t *testing.T) {
+	config.GlobalConfig = &config.Config{
+		Formatters: map[string]string{
+			".txt":  "printf 'formatted\\n' > {file}",
+			".bad":  "exit 3",
+			".slow": "sleep 30",
+		},
+	}
+	defer func() { config.GlobalConfig = nil }()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a file.txt")
+	os.WriteFile(path, []byte("raw"), 0644)
+	formatted, err := (&utils.Formatter{Path: path}).Format(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !formatted {
+		t.Error("expected file to be reformatted")
+	}
+	content, _ := os.ReadFile(path)
+	if string(content) != "formatted\n" {
+		t.Errorf("unexpected content %q", content)
+	}
+	formatted, err = (&utils.Formatter{Path: path}).Format(context.Background())
+	if err != nil || formatted {
+		t.Errorf("expected no change on second run, got formatted=%v err=%v", formatted, err)
+	}
+
+	bad := filepath.Join(dir, "x.bad")
+	os.WriteFile(bad, []byte("raw"), 0644)
+	if _, err := (&utils.Formatter{Path: bad}).Format(context.Background()); err == nil {
+		t.Error("expected formatter failure to be reported")
+	}
+
+	// 工具被取消时格式化命令也要终止
+	slow := filepath.Join(dir, "x.slow")
+	os.WriteFile(slow, []byte("raw"), 0644)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	if _, err := (&utils.Formatter{Path: slow}).Format(ctx); err == nil || time.Since(start) > 10*time.Second {
+		t.Errorf("canceled formatter should stop at once: %v", err)
+	}
+}
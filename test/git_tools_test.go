@@ -0,0 +1,144 @@
+package test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"bergo/tools"
+	"bergo/utils"
+)
+
+func TestParseGitStatus(t *testing.T) {
+	out := "## main...origin/main [ahead 2, behind 1]\x00 M a.go\x00M  b.go\x00R  new name.go\x00old.go\x00?? c.go\x00UU d.go\x00"
+	status := utils.ParseGitStatus(out)
+	if status.Branch != "main" || status.Upstream != "origin/main" || status.Ahead != 2 || status.Behind != 1 {
+		t.Errorf("unexpected branch: %+v", status)
+	}
+	if len(status.Files) != 5 || status.Files[2].Path != "new name.go" || status.Files[2].OrigPath != "old.go" {
+		t.Fatalf("unexpected files: %+v", status.Files)
+	}
+	formatted := tools.FormatGitStatus(status)
+	for _, want := range []string{"conflicts:\n  d.go", "staged:\n  modified: b.go\n  renamed: old.go -> new name.go", "unstaged:\n  modified: a.go", "untracked:\n  c.go"} {
+		if !strings.Contains(formatted, want) {
+			t.Errorf("%q not found in:\n%s", want, formatted)
+		}
+	}
+	if status := utils.ParseGitStatus("## No commits yet on main\x00"); status.Branch != "main" {
+		t.Errorf("unexpected branch of empty repo: %+v", status)
+	}
+}
+
+func TestGitDiffArgs(t *testing.T) {
+	args, err := tools.GitDiffArgs(&tools.GitDiffToolResult{Staged: true, From: "HEAD~1", Paths: []string{"a.go"}, Stat: true})
+	if err != nil || strings.Join(args, " ") != "diff --no-ext-diff --no-textconv --stat --cached HEAD~1 -- a.go" {
+		t.Errorf("unexpected args: %v %v", args, err)
+	}
+	if _, err := tools.GitDiffArgs(&tools.GitDiffToolResult{From: "--output=/tmp/x"}); err == nil {
+		t.Error("ref starting with - should be rejected")
+	}
+	if _, err := tools.GitDiffArgs(&tools.GitDiffToolResult{To: "main"}); err == nil {
+		t.Error("to without from should be rejected")
+	}
+}
+
+func gitInRepo(t *testing.T, args ...string) {
+	cmd := exec.Command("git", args...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v %s", args, err, out)
+	}
+}
+
+func TestGitTools(t *testing.T) {
+	t.Chdir(t.TempDir())
+	gitInRepo(t, "init", "-q", "-b", "main")
+	gitInRepo(t, "config", "user.name", "tester")
+	gitInRepo(t, "config", "user.email", "tester@example.com")
+	os.WriteFile("a.txt", []byte("one\ntwo\nthree\n"), 0644)
+	gitInRepo(t, "add", "a.txt")
+	gitInRepo(t, "commit", "-q", "-m", "add a")
+	os.WriteFile("a.txt", []byte("one\n2\nthree\n"), 0644)
+	os.WriteFile("b.txt", []byte("b\n"), 0644)
+
+	out := callMcpTool(t, tools.TOOL_GIT_STATUS, `{}`, nil)
+	if out.Error != nil || !strings.Contains(out.Content, "unstaged:\n  modified: a.txt") || !strings.Contains(out.Content, "untracked:\n  b.txt") {
+		t.Errorf("unexpected status: %+v", out)
+	}
+	out = callMcpTool(t, tools.TOOL_GIT_DIFF, `{"paths":["a.txt"]}`, nil)
+	if out.Error != nil || !strings.Contains(out.Content, "-two\n+2") {
+		t.Errorf("unexpected diff: %+v", out)
+	}
+	if out := callMcpTool(t, tools.TOOL_GIT_DIFF, `{"staged":true}`, nil); out.Content != "no differences" {
+		t.Errorf("nothing is staged: %+v", out)
+	}
+	out = callMcpTool(t, tools.TOOL_GIT_BLAME, `{"path":"a.txt","start_line":2,"end_line":3}`, nil)
+	if out.Error != nil || !strings.HasPrefix(out.Content, "2 | 00000000") || !strings.Contains(out.Content, "tester (add a) | three") {
+		t.Errorf("unexpected blame: %+v", out)
+	}
+
+	if out := callMcpTool(t, tools.TOOL_GIT_COMMIT, `{"message":"update","paths":["a.txt"]}`, nil); out.Error == nil {
+		t.Error("commit should require confirmation")
+	}
+	if out := callMcpTool(t, tools.TOOL_GIT_COMMIT, `{"message":"update","paths":["a.txt"]}`, &fakeInput{choice: "Skip"}); out.Error == nil {
+		t.Error("skip should refuse the commit")
+	}
+	if out := callMcpTool(t, tools.TOOL_GIT_DIFF, `{"staged":true}`, nil); out.Content != "no differences" {
+		t.Errorf("skipped paths should be unstaged: %+v", out)
+	}
+	out = callMcpTool(t, tools.TOOL_GIT_COMMIT, `{"message":"update a","paths":["a.txt"]}`, &fakeInput{choice: "Edit message", text: "change two to 2"})
+	if out.Error != nil || !strings.HasSuffix(out.Content, " change two to 2") {
+		t.Errorf("unexpected commit: %+v", out)
+	}
+	out = callMcpTool(t, tools.TOOL_GIT_LOG, `{"path":"a.txt","max_count":5}`, nil)
+	lines := strings.Split(out.Content, "\n")
+	if out.Error != nil || len(lines) != 2 || !strings.HasSuffix(lines[0], "tester: change two to 2") {
+		t.Errorf("unexpected log: %+v", out)
+	}
+	if out := callMcpTool(t, tools.TOOL_GIT_LOG, `{"ref":"-p"}`, nil); out.Error == nil {
+		t.Error("invalid ref should be rejected")
+	}
+
+	// 取消提交时恢复原来的暂存区，只暂存了部分改动的文件保持原样
+	os.WriteFile("a.txt", []byte("one\n2\n3\n"), 0644)
+	gitInRepo(t, "add", "a.txt")
+	os.WriteFile("a.txt", []byte("one\n2\n3\nfour\n"), 0644)
+	if out := callMcpTool(t, tools.TOOL_GIT_COMMIT, `{"message":"update","paths":["a.txt"]}`, &fakeInput{choice: "Skip"}); out.Error == nil {
+		t.Error("skip should refuse the commit")
+	}
+	if staged, _ := utils.NewGit(".").Run("show", ":a.txt"); staged != "one\n2\n3\n" {
+		t.Errorf("partially staged file should be restored: %q", staged)
+	}
+
+	// 钩子拒绝提交时也要取消本次暂存的文件
+	os.WriteFile(filepath.Join(".git", "hooks", "pre-commit"), []byte("#!/bin/sh\nexit 1\n"), 0755)
+	if out := callMcpTool(t, tools.TOOL_GIT_COMMIT, `{"message":"add b","paths":["b.txt"]}`, &fakeInput{choice: "Yes"}); out.Error == nil {
+		t.Error("commit rejected by the hook should fail")
+	}
+	if staged, _ := utils.NewGit(".").StagedFiles(); strings.Join(staged, ",") != "a.txt" {
+		t.Errorf("b.txt should be unstaged after the commit failed: %v", staged)
+	}
+
+	// 工作区是子目录时，git_status的路径和其他git工具接受的路径一致
+	os.Mkdir("sub", 0755)
+	os.WriteFile(filepath.Join("sub", "c.txt"), []byte("c\n"), 0644)
+	t.Chdir("sub")
+	out = callMcpTool(t, tools.TOOL_GIT_STATUS, `{}`, nil)
+	if out.Error != nil || !strings.Contains(out.Content, "untracked:\n  ../b.txt\n  c.txt") {
+		t.Errorf("status paths should be relative to the workspace: %+v", out)
+	}
+	out = callMcpTool(t, tools.TOOL_GIT_DIFF, `{"paths":["../a.txt"]}`, nil)
+	if out.Error != nil || !strings.Contains(out.Content, "+four") {
+		t.Errorf("unexpected diff: %+v", out)
+	}
+}
+
+func TestParseGitBlameSha256(t *testing.T) {
+	hash := strings.Repeat("ab", 32)
+	out := hash + " 1 1 1\nauthor tester\nauthor-time 1700000000\nsummary init\nfilename a.txt\n\tline one\n"
+	lines := utils.ParseGitBlame(out)
+	if len(lines) != 1 || lines[0].Hash != hash || lines[0].Author != "tester" || lines[0].Content != "line one" {
+		t.Errorf("unexpected blame lines: %+v", lines)
+	}
+}
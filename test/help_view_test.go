@@ -0,0 +1,53 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"bergo/utils/cli"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestHelpView(t *testing.T) {
+	sections := []*cli.HelpSection{
+		{Key: "commands", Title: "Commands", Entries: []cli.HelpEntry{{Name: "/planner", Description: "switch to planner mode"}}},
+		{Key: "attachments", Title: "@ Attachments", Entries: []cli.HelpEntry{{Name: "@file:<path>", Description: "attach a file"}}},
+		{Key: "tools", Title: "Tools", Entries: []cli.HelpEntry{{Name: "read_file", Description: "read a file"}}},
+	}
+
+	cases := map[string]int{
+		"tools":     2,
+		"/tools":    2,
+		"TOO":       2,
+		"@":         1,
+		"attach":    1,
+		"":          -1,
+		"not-exist": -1,
+	}
+	for topic, expected := range cases {
+		if got := cli.FindHelpSection(sections, topic); got != expected {
+			t.Errorf("FindHelpSection(%q) = %d, want %d", topic, got, expected)
+		}
+	}
+
+	var model tea.Model = cli.NewHelpView(sections, 0)
+	model, _ = model.Update(tea.WindowSizeMsg{Width: 100, Height: 30})
+	if !strings.Contains(model.View(), "/planner") {
+		t.Errorf("first section should be shown:\n%s", model.View())
+	}
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRight})
+	if !strings.Contains(model.View(), "@file:<path>") {
+		t.Errorf("right key should switch to next section:\n%s", model.View())
+	}
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'3'}})
+	if !strings.Contains(model.View(), "read_file") {
+		t.Errorf("number key should jump to section:\n%s", model.View())
+	}
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyLeft})
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyLeft})
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyLeft})
+	if !strings.Contains(model.View(), "read_file") {
+		t.Errorf("left key should wrap around:\n%s", model.View())
+	}
+}
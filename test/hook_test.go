@@ -0,0 +1,102 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"bergo/berio"
+	"bergo/config"
+	"bergo/llm"
+	"bergo/tools"
+	"bergo/utils"
+)
+
+func TestRunHooks(t *testing.T) {
+	hooks := []*config.HookConfig{
+		// 只匹配shell_cmd，把参数改写为安全的命令
+		{Event: utils.HOOK_PRE_TOOL_USE, Matcher: "shell_cmd", Command: `echo '{"tool_input":{"command":"ls"},"feedback":"rewritten"}'`, Timeout: 5},
+		// 根据stdin中的内容阻止删除
+		{Event: utils.HOOK_PRE_TOOL_USE, Matcher: "remove|shell_cmd", Command: `grep -q '"rm ' && echo "rm is forbidden" >&2 && exit 2; exit 0`, Timeout: 5},
+		{Event: utils.HOOK_POST_TOOL_USE, Command: `echo '{"feedback":"remember to run tests"}'`, Timeout: 5},
+		{Event: utils.HOOK_STOP, Command: `grep -q stop_hook_active || echo '{"decision":"continue","reason":"tests not run yet"}'`, Timeout: 5},
+		{Event: utils.HOOK_USER_PROMPT_SUBMIT, Command: `echo not json`, Timeout: 5},
+		{Event: utils.HOOK_SESSION_START, Command: `sleep 5; echo done`, Timeout: 1},
+	}
+
+	result := utils.RunHooks(context.Background(), hooks, &utils.HookInput{Event: utils.HOOK_PRE_TOOL_USE, ToolName: "shell_cmd", ToolInput: []byte(`{"command":"rm -rf /"}`)})
+	if result.Block {
+		t.Errorf("rewritten input should pass the second hook, reason: %s", result.Reason)
+	}
+	if string(result.ToolInput) != `{"command":"ls"}` || result.JoinedFeedback() != "rewritten" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	result = utils.RunHooks(context.Background(), hooks, &utils.HookInput{Event: utils.HOOK_PRE_TOOL_USE, ToolName: "remove", ToolInput: []byte(`{"command":"rm x"}`)})
+	if !result.Block || result.Reason != "rm is forbidden" {
+		t.Errorf("expected block by exit code 2, got %+v", result)
+	}
+
+	result = utils.RunHooks(context.Background(), hooks, &utils.HookInput{Event: utils.HOOK_PRE_TOOL_USE, ToolName: "shell_cmd_x"})
+	if result.Block || len(result.Feedback) > 0 {
+		t.Errorf("matcher should match the whole tool name, got %+v", result)
+	}
+
+	result = utils.RunHooks(context.Background(), hooks, &utils.HookInput{Event: utils.HOOK_POST_TOOL_USE, ToolName: "read_file", ToolOutput: "content"})
+	if result.JoinedFeedback() != "remember to run tests" {
+		t.Errorf("unexpected feedback: %+v", result)
+	}
+
+	result = utils.RunHooks(context.Background(), hooks, &utils.HookInput{Event: utils.HOOK_STOP})
+	if !result.Continue || result.Reason != "tests not run yet" {
+		t.Errorf("expected continue, got %+v", result)
+	}
+	result = utils.RunHooks(context.Background(), hooks, &utils.HookInput{Event: utils.HOOK_STOP, StopHookActive: true})
+	if result.Continue {
+		t.Errorf("hook should be able to stop continuing, got %+v", result)
+	}
+
+	result = utils.RunHooks(context.Background(), hooks, &utils.HookInput{Event: utils.HOOK_USER_PROMPT_SUBMIT, Prompt: "hi"})
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Error(), "not valid json") || result.Block {
+		t.Errorf("invalid output should be reported without blocking, got %+v", result)
+	}
+
+	// 超时后终止整个进程组，不等待sleep结束
+	start := time.Now()
+	result = utils.RunHooks(context.Background(), hooks, &utils.HookInput{Event: utils.HOOK_SESSION_START})
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Error(), "timed out") {
+		t.Errorf("expected timeout error, got %+v", result)
+	}
+	if elapsed := time.Since(start); elapsed > 4*time.Second {
+		t.Errorf("timed out hook should be killed, took %v", elapsed)
+	}
+}
+
+func TestCallToolWithHooks(t *testing.T) {
+	config.GlobalConfig = &config.Config{Hooks: []*config.HookConfig{
+		{Event: utils.HOOK_PRE_TOOL_USE, Matcher: "shell_cmd", Command: `grep -q '"rm ' && echo "rm is forbidden" >&2 && exit 2; exit 0`, Timeout: 5},
+		{Event: utils.HOOK_POST_TOOL_USE, Command: `grep -q '"session_id":"s1"' && echo '{"feedback":"checked"}'`, Timeout: 5},
+	}}
+	defer func() { config.GlobalConfig = nil }()
+
+	// 子agent和主agent使用同一个入口，被阻止时不调用工具
+	called := 0
+	callTool := func(ctx context.Context, call *llm.ToolCall) (*tools.AgentOutput, error) {
+		called++
+		return &tools.AgentOutput{Content: "ok", ToolCall: call}, nil
+	}
+	call := &llm.ToolCall{}
+	call.Function.Name = tools.TOOL_SHELL_CMD
+	call.Function.Arguments = `{"command":"rm -rf /"}`
+	out, err := tools.CallToolWithHooks(context.Background(), berio.NewNopOutput(), "s1", "agent", call, callTool)
+	if err != nil || called != 0 || !strings.Contains(out.Content, "rm is forbidden") {
+		t.Errorf("tool call should be blocked: %+v %v %d", out, err, called)
+	}
+
+	call.Function.Arguments = `{"command":"ls"}`
+	out, err = tools.CallToolWithHooks(context.Background(), berio.NewNopOutput(), "s1", "agent", call, callTool)
+	if err != nil || called != 1 || out.Content != "ok\n<hook_feedback>\nchecked\n</hook_feedback>" {
+		t.Errorf("unexpected output: %+v %v %d", out, err, called)
+	}
+}
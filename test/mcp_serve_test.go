@@ -0,0 +1,144 @@
+package test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"bergo/agent"
+	"bergo/config"
+	"bergo/mcp"
+	"bergo/tools"
+	"bergo/utils"
+)
+
+type serveReply struct {
+	Id     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *mcp.RpcError   `json:"error"`
+}
+
+// serveLines 把请求逐行发给服务器，返回每个响应
+func serveLines(t *testing.T, server *mcp.Server, lines ...string) []*serveReply {
+	out := &strings.Builder{}
+	if err := server.Serve(context.Background(), strings.NewReader(strings.Join(lines, "\n")+"\n"), out); err != nil {
+		t.Fatal(err)
+	}
+	var replies []*serveReply
+	scanner := bufio.NewScanner(strings.NewReader(out.String()))
+	for scanner.Scan() {
+		reply := &serveReply{}
+		if err := json.Unmarshal(scanner.Bytes(), reply); err != nil {
+			t.Fatalf("invalid reply %s: %v", scanner.Text(), err)
+		}
+		replies = append(replies, reply)
+	}
+	return replies
+}
+
+func TestMcpServer(t *testing.T) {
+	server := mcp.NewServer("bergo", "test")
+	server.AddTool(&mcp.Tool{Name: "echo", InputSchema: json.RawMessage(`{"type":"object"}`)}, func(ctx context.Context, arguments json.RawMessage) *mcp.CallToolResult {
+		return mcp.TextResult(string(arguments), false)
+	})
+	replies := serveLines(t, server,
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"c","version":"1"}}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"echo","arguments":{"a":1}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"missing"}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"resources/list"}`,
+	)
+	if len(replies) != 5 {
+		t.Fatalf("notifications should not be replied, got %d replies", len(replies))
+	}
+	initResult := &mcp.InitializeResult{}
+	json.Unmarshal(replies[0].Result, initResult)
+	if initResult.ServerInfo.Name != "bergo" || initResult.Capabilities["tools"] == nil {
+		t.Errorf("unexpected initialize result: %s", replies[0].Result)
+	}
+	if !strings.Contains(string(replies[1].Result), `"name":"echo"`) {
+		t.Errorf("unexpected tools: %s", replies[1].Result)
+	}
+	result := &mcp.CallToolResult{}
+	json.Unmarshal(replies[2].Result, result)
+	if len(result.Content) != 1 || result.Content[0].Text != `{"a":1}` {
+		t.Errorf("unexpected call result: %s", replies[2].Result)
+	}
+	if replies[3].Error == nil || replies[3].Error.Code != -32602 {
+		t.Errorf("unknown tool should be invalid params: %+v", replies[3].Error)
+	}
+	if replies[4].Error == nil || replies[4].Error.Code != -32601 {
+		t.Errorf("unsupported method should be method not found: %+v", replies[4].Error)
+	}
+}
+
+func TestMcpServeTools(t *testing.T) {
+	schema, err := agent.McpInputSchema(tools.ToolsMap[tools.TOOL_READ_FILE].Schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed := struct {
+		Type       string                     `json:"type"`
+		Properties map[string]json.RawMessage `json:"properties"`
+		Required   []string                   `json:"required"`
+	}{}
+	if err := json.Unmarshal(schema, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Type != "object" || len(parsed.Properties) == 0 || len(parsed.Required) == 0 {
+		t.Errorf("unexpected input schema: %s", schema)
+	}
+
+	session := agent.NewMcpSession()
+	if _, err := session.NewServer([]string{tools.TOOL_STOP_LOOP}); err == nil {
+		t.Error("stop_loop should not be served")
+	}
+	if _, err := session.NewServer([]string{"not_exist"}); err == nil {
+		t.Error("unknown tool should be rejected")
+	}
+	server, err := session.NewServer([]string{tools.TOOL_READ_FILE, tools.TOOL_EDIT_DIFF})
+	if err != nil {
+		t.Fatal(err)
+	}
+	replies := serveLines(t, server,
+		`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"read_file","arguments":{}}}`,
+	)
+	list := struct {
+		Tools []*mcp.Tool `json:"tools"`
+	}{}
+	json.Unmarshal(replies[0].Result, &list)
+	if len(list.Tools) != 4 || list.Tools[0].Name != tools.TOOL_READ_FILE || list.Tools[3].Name != agent.MCP_SERVE_CHECKPOINT_REVERT {
+		t.Errorf("unexpected tools: %s", replies[0].Result)
+	}
+	result := &mcp.CallToolResult{}
+	json.Unmarshal(replies[1].Result, result)
+	if !result.IsError || !strings.Contains(result.Content[0].Text, "validate") {
+		t.Errorf("invalid arguments should be rejected by schema: %s", replies[1].Result)
+	}
+}
+
+func TestMcpServeHooks(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.WriteFile("a.txt", []byte("hello\n"), 0644)
+	config.GlobalConfig = &config.Config{Hooks: []*config.HookConfig{
+		{Event: utils.HOOK_PRE_TOOL_USE, Matcher: "read_file", Command: `grep -q '"mode":"mcp-serve"' && echo "blocked in mcp-serve" >&2 && exit 2; exit 0`, Timeout: 5},
+	}}
+	defer func() { config.GlobalConfig = nil }()
+
+	// 通过mcp-serve调用的工具同样要经过hook
+	server, err := agent.NewMcpSession().NewServer([]string{tools.TOOL_READ_FILE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	replies := serveLines(t, server, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"read_file","arguments":{"path":"a.txt"}}}`)
+	result := &mcp.CallToolResult{}
+	json.Unmarshal(replies[0].Result, result)
+	if len(result.Content) == 0 || !strings.Contains(result.Content[0].Text, "blocked in mcp-serve") || strings.Contains(result.Content[0].Text, "hello") {
+		t.Errorf("read_file should be blocked by the hook: %s", replies[0].Result)
+	}
+}
@@ -0,0 +1,260 @@
+package test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"bergo/config"
+	"bergo/llm"
+	"bergo/mcp"
+	"bergo/tools"
+)
+
+// fakeMcpResult 模拟MCP服务器对请求的处理
+func fakeMcpResult(method string, params json.RawMessage) (interface{}, error) {
+	switch method {
+	case "initialize":
+		return map[string]interface{}{
+			"protocolVersion": mcp.PROTOCOL_VERSION,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}, "resources": map[string]interface{}{}, "prompts": map[string]interface{}{}},
+			"serverInfo":      map[string]interface{}{"name": "fake", "version": "1.0"},
+		}, nil
+	case "tools/list":
+		p := struct {
+			Cursor string `json:"cursor"`
+		}{}
+		json.Unmarshal(params, &p)
+		if p.Cursor == "" {
+			return map[string]interface{}{
+				"tools": []interface{}{map[string]interface{}{
+					"name":        "echo",
+					"description": "echo the text",
+					"inputSchema": json.RawMessage(`{"type":"object","properties":{"text":{"type":"string","description":"text to echo"},"times":{"type":["integer","null"],"minimum":1}},"required":["text"]}`),
+				}},
+				"nextCursor": "2",
+			}, nil
+		}
+		return map[string]interface{}{
+			"tools": []interface{}{map[string]interface{}{"name": "fail", "inputSchema": json.RawMessage(`{"type":"object"}`)}},
+		}, nil
+	case "tools/call":
+		p := struct {
+			Name      string `json:"name"`
+			Arguments struct {
+				Text string `json:"text"`
+			} `json:"arguments"`
+		}{}
+		json.Unmarshal(params, &p)
+		if p.Name == "fail" {
+			return map[string]interface{}{"isError": true, "content": []interface{}{map[string]interface{}{"type": "text", "text": "something broke"}}}, nil
+		}
+		return map[string]interface{}{"content": []interface{}{map[string]interface{}{"type": "text", "text": "echo: " + p.Arguments.Text}}}, nil
+	case "resources/list":
+		return map[string]interface{}{"resources": []interface{}{map[string]interface{}{"uri": "db://schema/users", "name": "users"}}}, nil
+	case "resources/read":
+		return map[string]interface{}{"contents": []interface{}{map[string]interface{}{"uri": "db://schema/users", "text": "id int, name text"}}}, nil
+	case "prompts/list":
+		return map[string]interface{}{"prompts": []interface{}{map[string]interface{}{"name": "triage", "arguments": []interface{}{map[string]interface{}{"name": "issue", "required": true}}}}}, nil
+	case "prompts/get":
+		return map[string]interface{}{"messages": []interface{}{map[string]interface{}{"role": "user", "content": map[string]interface{}{"type": "text", "text": "triage the issue"}}}}, nil
+	}
+	return nil, fmt.Errorf("method not found")
+}
+
+func fakeMcpReply(line []byte) []byte {
+	msg := struct {
+		Id     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+	}{}
+	if err := json.Unmarshal(line, &msg); err != nil || len(msg.Id) == 0 {
+		return nil
+	}
+	reply := map[string]interface{}{"jsonrpc": "2.0", "id": msg.Id}
+	result, err := fakeMcpResult(msg.Method, msg.Params)
+	if err != nil {
+		reply["error"] = map[string]interface{}{"code": -32601, "message": err.Error()}
+	} else {
+		reply["result"] = result
+	}
+	data, _ := json.Marshal(reply)
+	return data
+}
+
+// TestMcpFakeStdioServer 被测试进程作为子进程启动，充当stdio MCP服务器
+func TestMcpFakeStdioServer(t *testing.T) {
+	if os.Getenv("BERGO_FAKE_MCP") != "1" {
+		t.Skip("only runs as a subprocess")
+	}
+	// 先发一个通知和一个请求，客户端应该忽略通知并回复ping
+	fmt.Println(`{"jsonrpc":"2.0","method":"notifications/message","params":{}}`)
+	fmt.Println(`{"jsonrpc":"2.0","id":"s1","method":"ping"}`)
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if reply := fakeMcpReply(line); reply != nil {
+			fmt.Println(string(reply))
+		}
+		if err != nil {
+			// 模拟不处理stdin关闭的服务器
+			if os.Getenv("BERGO_FAKE_MCP_HANG") == "1" {
+				time.Sleep(30 * time.Second)
+			}
+			os.Exit(0)
+		}
+	}
+}
+
+func TestMcpStdioCloseWrapper(t *testing.T) {
+	// 通过sh启动的服务器，关闭时要终止sh启动的真正的服务器，不能一直等待输出关闭
+	conf := &config.McpServerConfig{
+		Name:    "wrapped",
+		Command: "sh",
+		Args:    []string{"-c", `"$0" -test.run='^TestMcpFakeStdioServer$'; exit 0`, os.Args[0]},
+		Env:     map[string]string{"BERGO_FAKE_MCP": "1", "BERGO_FAKE_MCP_HANG": "1"},
+		Timeout: 10,
+	}
+	client, err := mcp.Connect(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	client.Close()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("close should kill the whole process group, took %v", elapsed)
+	}
+}
+
+func callMcpTool(t *testing.T, name string, args string, input *fakeInput) *tools.AgentOutput {
+	call := &llm.ToolCall{}
+	call.Function.Name = name
+	call.Function.Arguments = args
+	if err := tools.JsonSchemaExam(call); err != nil {
+		return &tools.AgentOutput{Error: err}
+	}
+	agentInput := &tools.AgentInput{ToolCall: call, AllowMap: map[string]bool{}}
+	if input != nil {
+		agentInput.Input = input
+	}
+	return tools.ToolFuncMap[name](context.Background(), agentInput)
+}
+
+func TestMcpStdioClient(t *testing.T) {
+	conf := &config.McpServerConfig{
+		Name:      "fake",
+		Command:   os.Args[0],
+		Args:      []string{"-test.run=^TestMcpFakeStdioServer$"},
+		Env:       map[string]string{"BERGO_FAKE_MCP": "1"},
+		Timeout:   10,
+		Resources: true,
+		Prompts:   true,
+	}
+	client, err := mcp.Connect(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if len(client.Tools) != 2 || client.Tools[1].Name != "fail" {
+		t.Fatalf("tools of all pages should be listed, got %d", len(client.Tools))
+	}
+
+	names, errs := tools.RegisterMcpTools(client)
+	defer tools.UnregisterMcpTools(client.Name)
+	if len(errs) > 0 || len(names) != 4 {
+		t.Fatalf("unexpected registration: %v %v", names, errs)
+	}
+	echo := tools.McpToolName("fake", "echo")
+	if echo != "mcp__fake__echo" {
+		t.Errorf("unexpected tool name %s", echo)
+	}
+	schema := tools.ToolsMap[echo].Schema.Function.Parameters
+	if schema.Properties["times"].Type != "integer" || len(schema.Required) != 1 {
+		t.Errorf("unexpected converted schema: %+v", schema)
+	}
+
+	out := callMcpTool(t, echo, `{"text":"hi"}`, &fakeInput{choice: "Skip"})
+	if out.Error == nil {
+		t.Error("skip should refuse the call")
+	}
+	out = callMcpTool(t, echo, `{"text":"hi"}`, &fakeInput{choice: "Yes"})
+	if out.Error != nil || out.Content != "echo: hi" {
+		t.Errorf("unexpected output: %+v", out)
+	}
+	if out := callMcpTool(t, echo, `{"text":"hi","times":0}`, nil); out.Error == nil || !strings.Contains(out.Error.Error(), "validate") {
+		t.Errorf("original schema should be used for validation, got %+v", out)
+	}
+	if out := callMcpTool(t, tools.McpToolName("fake", "fail"), `{}`, nil); out.Error == nil || out.Error.Error() != "something broke" {
+		t.Errorf("isError result should be returned as error, got %+v", out)
+	}
+	out = callMcpTool(t, tools.McpToolName("fake", "read_resource"), `{"uri":"db://schema/users"}`, nil)
+	if !strings.Contains(out.Content, "id int, name text") {
+		t.Errorf("unexpected resource: %+v", out)
+	}
+	out = callMcpTool(t, tools.McpToolName("fake", "get_prompt"), `{"name":"triage","arguments":{"issue":"1"}}`, nil)
+	if !strings.Contains(out.Content, "triage the issue") {
+		t.Errorf("unexpected prompt: %+v", out)
+	}
+
+	tools.UnregisterMcpTools(client.Name)
+	if _, ok := tools.ToolsMap[echo]; ok || len(tools.McpToolNames()) != 0 {
+		t.Error("tools should be unregistered")
+	}
+}
+
+func TestMcpHttpClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		line := make([]byte, r.ContentLength)
+		r.Body.Read(line)
+		reply := fakeMcpReply(line)
+		if strings.Contains(string(line), `"initialize"`) {
+			w.Header().Set("Mcp-Session-Id", "session-1")
+		} else if r.Header.Get("Mcp-Session-Id") != "session-1" {
+			http.Error(w, "missing session", http.StatusBadRequest)
+			return
+		}
+		if reply == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		if strings.Contains(string(line), `"tools/call"`) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n")
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", reply)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(reply)
+	}))
+	defer server.Close()
+
+	client, err := mcp.Connect(context.Background(), &config.McpServerConfig{Name: "remote", Url: server.URL, Timeout: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if len(client.Resources) != 0 || len(client.Prompts) != 0 {
+		t.Error("resources and prompts are not requested unless enabled")
+	}
+	result, err := client.CallTool(context.Background(), "echo", json.RawMessage(`{"text":"sse"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Content) != 1 || result.Content[0].Text != "echo: sse" {
+		t.Errorf("unexpected result: %+v", result.Content)
+	}
+	if _, err := client.CallTool(context.Background(), "echo", nil); err != nil {
+		t.Errorf("empty arguments should be sent as object: %v", err)
+	}
+}
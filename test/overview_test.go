@@ -0,0 +1,161 @@
+package test
+
+import (
+	"context"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"bergo/berio"
+	"bergo/config"
+	"bergo/prompt"
+	"bergo/tools"
+	"bergo/utils"
+)
+
+func TestCodeIndexDirs(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.MkdirAll("cmd/server", 0755)
+	os.MkdirAll("pkg", 0755)
+	os.WriteFile("main.go", []byte("package main\n"), 0644)
+	os.WriteFile("cmd/server/main.go", []byte("package main\n"), 0644)
+	os.MkdirAll("cmd/server/handler", 0755)
+	os.WriteFile("cmd/server/handler/route.go", []byte("package handler\n"), 0644)
+	os.WriteFile("cmd/tool.go", []byte("package cmd\n"), 0644)
+	os.WriteFile("pkg/util.go", []byte("package pkg\n"), 0644)
+
+	index := utils.NewCodeIndex(".", utils.CODE_INDEX_FILE)
+	if _, err := index.Update(); err != nil {
+		t.Fatal(err)
+	}
+	dirs := index.Dirs(2)
+	var paths []string
+	for _, dir := range dirs {
+		paths = append(paths, dir.Path)
+	}
+	if want := []string{".", "cmd", "cmd/server", "pkg"}; !slices.Equal(paths, want) {
+		t.Fatalf("unexpected dirs: %v", paths)
+	}
+	if want := []string{"cmd/server/handler/route.go", "cmd/server/main.go"}; !slices.Equal(dirs[2].Files, want) {
+		t.Errorf("unexpected files: %v", dirs[2].Files)
+	}
+
+	// 只有改动的目录指纹会变化
+	os.WriteFile("pkg/util.go", []byte("package pkg\n\nfunc Util() {}\n"), 0644)
+	index.Update()
+	changed := index.Dirs(2)
+	for i := range dirs {
+		if (dirs[i].Hash != changed[i].Hash) != (dirs[i].Path == "pkg") {
+			t.Errorf("unexpected hash change of %s", dirs[i].Path)
+		}
+	}
+}
+
+func TestOverviewPlan(t *testing.T) {
+	overview := &utils.Overview{}
+	overview.Set("tools", "h1", "## tools/\nbuilt-in tools\n")
+	overview.Set("old", "h2", "## old/\n")
+	overview.Set(utils.OVERVIEW_PROJECT_SECTION, "p", "## 项目概况\n")
+	overview.Set("agent", "h3", "## agent/\n")
+
+	parsed := utils.ParseOverview(overview.String())
+	var keys []string
+	for _, section := range parsed.Sections {
+		keys = append(keys, section.Key)
+	}
+	if want := []string{utils.OVERVIEW_PROJECT_SECTION, "agent", "old", "tools"}; !slices.Equal(keys, want) {
+		t.Fatalf("unexpected sections: %v", keys)
+	}
+	if section := parsed.Section("tools"); section.Hash != "h1" || section.Content != "## tools/\nbuilt-in tools" {
+		t.Errorf("unexpected section: %+v", section)
+	}
+
+	dirs := []*utils.IndexedDir{{Path: "agent", Hash: "h3"}, {Path: "tools", Hash: "changed"}, {Path: "utils", Hash: "h4"}}
+	plan := parsed.Plan(dirs, false)
+	var planned []string
+	for _, dir := range plan.Dirs {
+		planned = append(planned, dir.Path)
+	}
+	if !slices.Equal(planned, []string{"tools", "utils"}) || !slices.Equal(plan.Removed, []string{"old"}) || !plan.Project {
+		t.Errorf("unexpected plan: %v %v %v", planned, plan.Removed, plan.Project)
+	}
+
+	// 目录都没有变化并且项目章节是最新的
+	parsed.Set("tools", "changed", "## tools/")
+	parsed.Set("utils", "h4", "## utils/")
+	parsed.Remove("old")
+	parsed.Set(utils.OVERVIEW_PROJECT_SECTION, plan.ProjectHash, "## 项目概况")
+	if plan := parsed.Plan(dirs, false); !plan.Empty() {
+		t.Errorf("plan should be empty: %+v", plan)
+	}
+	if plan := parsed.Plan(dirs, true); len(plan.Dirs) != 3 || !plan.Project {
+		t.Errorf("force should refresh all sections: %+v", plan)
+	}
+}
+
+func TestGenerateOverviewUpToDate(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.MkdirAll("pkg", 0755)
+	os.WriteFile("main.go", []byte("package main\n"), 0644)
+	os.WriteFile("pkg/util.go", []byte("package pkg\n"), 0644)
+
+	index := tools.GetCodeIndex()
+	if _, err := index.Update(); err != nil {
+		t.Fatal(err)
+	}
+	dirs := index.Dirs(utils.OVERVIEW_DIR_DEPTH)
+	overview := &utils.Overview{}
+	for _, dir := range dirs {
+		overview.Set(dir.Path, dir.Hash, "## "+dir.Path)
+	}
+	overview.Set(utils.OVERVIEW_PROJECT_SECTION, overview.Plan(dirs, false).ProjectHash, "## 项目概况")
+	if err := overview.Save(prompt.OVERVIEW_FILE); err != nil {
+		t.Fatal(err)
+	}
+
+	// 所有章节都是最新的，不需要请求模型
+	result, err := tools.GenerateOverview(context.Background(), berio.NewNopOutput(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Updated) != 0 || len(result.Removed) != 0 || len(result.Failed) != 0 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	content, _ := os.ReadFile(prompt.OVERVIEW_FILE)
+	if !strings.Contains(string(content), `<!-- bergo:section key="pkg"`) {
+		t.Errorf("unexpected overview: %s", content)
+	}
+}
+
+func TestGenerateOverviewInterrupted(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.MkdirAll("pkg", 0755)
+	os.WriteFile("main.go", []byte("package main\n"), 0644)
+	os.WriteFile("pkg/util.go", []byte("package pkg\n"), 0644)
+	config.GlobalConfig = &config.Config{
+		MainModel:  "main",
+		BeragModel: "main",
+		Models:     []*config.ModelConfig{{Identifier: "main", Provider: "openai", ModelName: "m", BaseUrl: "http://127.0.0.1:1/v1", ApiKey: "k"}},
+	}
+	defer func() { config.GlobalConfig = nil }()
+
+	overview := &utils.Overview{}
+	overview.Set("old", "h", "## old/")
+	if err := overview.Save(prompt.OVERVIEW_FILE); err != nil {
+		t.Fatal(err)
+	}
+	// 中断时返回已经完成的结果，不删除旧的章节，也不生成项目章节
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err := tools.GenerateOverview(ctx, berio.NewNopOutput(), false)
+	if err == nil || !strings.Contains(err.Error(), "interrupted") || result == nil {
+		t.Fatalf("expected interrupted result, got %+v %v", result, err)
+	}
+	if len(result.Removed) != 0 || len(result.Updated) != 0 || len(result.Failed) != 2 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if utils.LoadOverview(prompt.OVERVIEW_FILE).Section("old") == nil {
+		t.Error("old section should be kept until the next /init")
+	}
+}
@@ -0,0 +1,57 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"bergo/config"
+	"bergo/llm"
+	"bergo/tools"
+)
+
+func TestReadFiles(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	var lines []string
+	for i := 1; i <= 10; i++ {
+		lines = append(lines, fmt.Sprintf("line%d", i))
+	}
+	os.WriteFile(a, []byte(strings.Join(lines, "\n")), 0644)
+	os.WriteFile(b, []byte(strings.Join(lines, "\n")), 0644)
+	missing := filepath.Join(dir, "missing.txt")
+
+	config.GlobalConfig = &config.Config{LineBudget: 8}
+	defer func() { config.GlobalConfig = nil }()
+
+	call := &llm.ToolCall{}
+	call.Function.Name = tools.TOOL_READ_FILES
+	call.Function.Arguments = fmt.Sprintf(`{"files":[
+		{"path":%q,"ranges":[{"begin":1,"end":2},{"begin":9,"end":10}]},
+		{"path":%q},
+		{"path":%q},
+		{"path":%q}
+	]}`, a, missing, b, a)
+	if err := tools.JsonSchemaExam(call); err != nil {
+		t.Fatalf("schema exam failed: %v", err)
+	}
+	out := tools.ReadFiles(context.Background(), &tools.AgentInput{ToolCall: call})
+	if out.Error != nil {
+		t.Fatalf("unexpected error: %v", out.Error)
+	}
+	content := out.Content
+	for _, want := range []string{
+		fmt.Sprintf("## %s:\n1|line1\n2|line2\n...\n9|line9\n10|line10\n", a),
+		fmt.Sprintf("## %s:\nerror:", missing),
+		fmt.Sprintf("## %s:\n1|line1\n2|line2\n3|line3\n4|line4\n...content after", b),
+		"...skipped, line budget exhausted...",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("output missing %q, got:\n%s", want, content)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"bergo/agent"
+	"bergo/berio"
+	"bergo/config"
+	"bergo/tools"
+	"bergo/utils"
+)
+
+func TestReviewReport(t *testing.T) {
+	report := utils.NewReviewReport("staged changes")
+	report.Add(&utils.ReviewFinding{File: "b.go", StartLine: 3, EndLine: 3, Severity: utils.SEVERITY_MINOR, Category: "style", Message: "rename it"})
+	report.Add(&utils.ReviewFinding{File: "a.go", StartLine: 10, EndLine: 12, Severity: utils.SEVERITY_BLOCKER, Category: "bug", Message: "nil pointer\nwhen x is empty", Suggestion: "check x"})
+	if _, added := report.Add(&utils.ReviewFinding{File: "b.go", StartLine: 3, Severity: utils.SEVERITY_MINOR, Category: "style", Message: "rename it"}); added {
+		t.Error("duplicated finding should not be added")
+	}
+	findings := report.Findings()
+	if len(findings) != 2 || findings[0].File != "a.go" || findings[0].Title() != "[blocker] a.go:10-12 (bug)" || findings[0].Simple() != "nil pointer" {
+		t.Fatalf("unexpected findings: %+v", findings)
+	}
+	if report.Summary() != "1 blocker, 1 minor" {
+		t.Errorf("unexpected summary: %s", report.Summary())
+	}
+	if len(report.Blocking(utils.SEVERITY_BLOCKER)) != 1 || len(report.Blocking(utils.SEVERITY_NIT)) != 2 {
+		t.Error("unexpected blocking findings")
+	}
+	md := report.Markdown()
+	if !strings.Contains(md, "## 1. `a.go:10-12` blocker · bug") || !strings.Contains(md, "## 2. `b.go:3` minor · style") {
+		t.Errorf("unexpected markdown:\n%s", md)
+	}
+
+	data, err := report.SARIF("v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sarif := struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct {
+						Id string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleId    string `json:"ruleId"`
+				Level     string `json:"level"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							Uri string `json:"uri"`
+						} `json:"artifactLocation"`
+						Region struct {
+							StartLine int `json:"startLine"`
+							EndLine   int `json:"endLine"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}{}
+	if err := json.Unmarshal(data, &sarif); err != nil {
+		t.Fatal(err)
+	}
+	run := sarif.Runs[0]
+	if sarif.Version != "2.1.0" || len(run.Tool.Driver.Rules) != 2 || len(run.Results) != 2 {
+		t.Fatalf("unexpected sarif: %s", data)
+	}
+	result := run.Results[0]
+	location := result.Locations[0].PhysicalLocation
+	if result.RuleId != "bergo/bug" || result.Level != "error" || location.ArtifactLocation.Uri != "a.go" || location.Region.EndLine != 12 || run.Results[1].Level != "note" {
+		t.Errorf("unexpected sarif result: %s", data)
+	}
+}
+
+func TestReportFinding(t *testing.T) {
+	if out := callMcpTool(t, tools.TOOL_REPORT_FINDING, `{"file":"a.go","start_line":1,"severity":"major","category":"bug","message":"x"}`, nil); out.Error == nil {
+		t.Error("report_finding should fail without a review")
+	}
+	report := utils.NewReviewReport("test")
+	tools.StartReview(report)
+	defer tools.FinishReview()
+	if out := callMcpTool(t, tools.TOOL_REPORT_FINDING, `{"file":"a.go","start_line":1,"severity":"critical","category":"bug","message":"x"}`, nil); out.Error == nil {
+		t.Error("unknown severity should be rejected")
+	}
+	if out := callMcpTool(t, tools.TOOL_REPORT_FINDING, `{"file":"a.go","start_line":5,"end_line":2,"severity":"major","category":"bug","message":"x"}`, nil); out.Error == nil {
+		t.Error("end_line before start_line should be rejected")
+	}
+	if out := callMcpTool(t, tools.TOOL_REPORT_FINDING, `{"file":"../a.go","start_line":1,"severity":"major","category":"bug","message":"x"}`, nil); out.Error == nil {
+		t.Error("file outside the workspace should be rejected")
+	}
+	out := callMcpTool(t, tools.TOOL_REPORT_FINDING, `{"file":"./pkg/a.go","start_line":4,"severity":"major","category":"concurrency","message":" data race ","suggestion":"add a lock"}`, nil)
+	if out.Error != nil || out.Content != "finding 1 recorded" {
+		t.Fatalf("unexpected output: %+v", out)
+	}
+	findings := report.Findings()
+	if len(findings) != 1 || findings[0].File != "pkg/a.go" || findings[0].EndLine != 4 || findings[0].Message != "data race" {
+		t.Errorf("unexpected findings: %+v", findings[0])
+	}
+}
+
+func TestResolveReviewTarget(t *testing.T) {
+	t.Chdir(t.TempDir())
+	gitInRepo(t, "init", "-q", "-b", "main")
+	gitInRepo(t, "config", "user.name", "tester")
+	gitInRepo(t, "config", "user.email", "tester@example.com")
+	git := utils.NewGit(".")
+	os.WriteFile("a.txt", []byte("a\n"), 0644)
+	gitInRepo(t, "add", "a.txt")
+	// 没有提交时只能审查暂存区
+	target, err := tools.ResolveReviewTarget(git, "")
+	if err != nil || target.DiffArguments() != `{"staged":true}` || !strings.Contains(target.Stat, "a.txt") {
+		t.Fatalf("unexpected target: %+v %v", target, err)
+	}
+	gitInRepo(t, "commit", "-q", "-m", "init")
+	if _, err := tools.ResolveReviewTarget(git, ""); err == nil || !strings.Contains(err.Error(), "no changes") {
+		t.Errorf("clean tree should have nothing to review: %v", err)
+	}
+	os.Mkdir("dir", 0755)
+	os.WriteFile("dir/b.txt", []byte("b\n"), 0644)
+	os.WriteFile("a.txt", []byte("a2\n"), 0644)
+	gitInRepo(t, "add", "dir")
+	target, err = tools.ResolveReviewTarget(git, "dir")
+	if err != nil || target.DiffArguments() != `{"from":"HEAD","paths":["dir"]}` || strings.Contains(target.Stat, "a.txt") {
+		t.Errorf("unexpected path target: %+v %v", target, err)
+	}
+	target, err = tools.ResolveReviewTarget(git, "--staged")
+	if err != nil || target.Description != "staged changes" || strings.Contains(target.Stat, "a.txt") {
+		t.Errorf("unexpected staged target: %+v %v", target, err)
+	}
+	target, err = tools.ResolveReviewTarget(git, "main")
+	if err != nil || target.DiffArguments() != `{"from":"main"}` {
+		t.Errorf("unexpected ref target: %+v %v", target, err)
+	}
+	if _, err := tools.ResolveReviewTarget(git, "no-such-ref"); err == nil {
+		t.Error("unknown target should be rejected")
+	}
+}
+
+func TestParseReviewArgs(t *testing.T) {
+	opts, err := agent.ParseReviewArgs([]string{"bergo.toml", "--staged", "--format=sarif", "--output", "out.sarif"})
+	if err != nil || opts.Config != "bergo.toml" || opts.Target != "--staged" || opts.Format != agent.REVIEW_FORMAT_SARIF || opts.Output != "out.sarif" || opts.FailOn != utils.SEVERITY_BLOCKER {
+		t.Errorf("unexpected options: %+v %v", opts, err)
+	}
+	opts, err = agent.ParseReviewArgs([]string{"--fail-on", "major", "bergo.toml", "main"})
+	if err != nil || opts.Target != "main" || opts.FailOn != utils.SEVERITY_MAJOR || opts.Format != agent.REVIEW_FORMAT_MARKDOWN {
+		t.Errorf("unexpected options: %+v %v", opts, err)
+	}
+	for _, args := range [][]string{
+		{},
+		{"--staged"},
+		{"bergo.toml", "--format", "html"},
+		{"bergo.toml", "--fail-on", "critical"},
+		{"bergo.toml", "main", "dev"},
+		{"bergo.toml", "--output"},
+		{"bergo.toml", "--verbose", "x"},
+	} {
+		if _, err := agent.ParseReviewArgs(args); err == nil {
+			t.Errorf("%v should be rejected", args)
+		}
+	}
+}
+
+func TestRunReviewModelError(t *testing.T) {
+	config.GlobalConfig = &config.Config{
+		MainModel: "main",
+		Models:    []*config.ModelConfig{{Identifier: "main", Provider: "openai"}},
+	}
+	defer func() { config.GlobalConfig = nil }()
+
+	// 模型初始化失败时返回错误，bergo review以退出码2结束而不是panic
+	target := &tools.ReviewTarget{Description: "staged changes", Diff: &tools.GitDiffToolResult{Staged: true}}
+	if _, _, err := tools.RunReview(context.Background(), target, berio.NewNopOutput()); err == nil || !strings.Contains(err.Error(), "API key") {
+		t.Errorf("expected model init error, got %v", err)
+	}
+}
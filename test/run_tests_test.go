@@ -0,0 +1,246 @@
+package test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"bergo/llm"
+	"bergo/tools"
+	"bergo/utils"
+)
+
+func TestParseGoTestJSON(t *testing.T) {
+	out := strings.Join([]string{
+		`{"Action":"run","Package":"demo/a","Test":"TestOk"}`,
+		`{"Action":"pass","Package":"demo/a","Test":"TestOk"}`,
+		`{"Action":"pass","Package":"demo/a","Test":"TestTable/one"}`,
+		`{"Action":"pass","Package":"demo/a","Test":"TestTable/two"}`,
+		`{"Action":"pass","Package":"demo/a","Test":"TestTable"}`,
+		`{"Action":"run","Package":"demo/a","Test":"TestSum"}`,
+		`{"Action":"output","Package":"demo/a","Test":"TestSum/neg","Output":"=== RUN   TestSum/neg\n"}`,
+		`{"Action":"output","Package":"demo/a","Test":"TestSum/neg","Output":"    sum_test.go:12: want -1, got 1\n"}`,
+		`{"Action":"output","Package":"demo/a","Test":"TestSum/neg","Output":"--- FAIL: TestSum/neg (0.00s)\n"}`,
+		`{"Action":"fail","Package":"demo/a","Test":"TestSum/neg"}`,
+		`{"Action":"fail","Package":"demo/a","Test":"TestSum"}`,
+		`{"Action":"output","Package":"demo/a","Test":"TestPanic","Output":"panic: boom [recovered]\n"}`,
+		`{"Action":"output","Package":"demo/a","Test":"TestPanic","Output":"\t/usr/local/go/src/testing/testing.go:1632 +0x1d\n"}`,
+		`{"Action":"output","Package":"demo/a","Test":"TestPanic","Output":"\t/work/demo/a/panic_test.go:8 +0x2c\n"}`,
+		`{"Action":"fail","Package":"demo/a","Test":"TestPanic"}`,
+		`{"Action":"skip","Package":"demo/a","Test":"TestSkip"}`,
+		`{"Action":"fail","Package":"demo/a"}`,
+		`{"ImportPath":"demo/b [demo/b.test]","Action":"build-output","Output":"b/b.go:3:1: syntax error\n"}`,
+		`{"Action":"output","Package":"demo/b","Output":"FAIL\tdemo/b [build failed]\n"}`,
+		`{"Action":"fail","Package":"demo/b"}`,
+	}, "\n")
+	report := utils.ParseGoTestJSON(out)
+	// 父测试不重复计数
+	if report.Passed != 3 || report.Failed != 3 || report.Skipped != 1 {
+		t.Fatalf("unexpected counts: %+v", report)
+	}
+	sub := report.Failures[0]
+	if sub.Name != "TestSum/neg" || sub.Location() != "sum_test.go:12" || sub.Message != "want -1, got 1" || strings.Contains(sub.Output, "=== RUN") {
+		t.Errorf("unexpected subtest failure: %+v", sub)
+	}
+	panicked := report.Failures[1]
+	if panicked.Name != "TestPanic" || panicked.Location() != "/work/demo/a/panic_test.go:8" || panicked.Message != "panic: boom [recovered]" {
+		t.Errorf("unexpected panic failure: %+v", panicked)
+	}
+	build := report.Failures[2]
+	if build.Suite != "demo/b" || build.Name != "" || !strings.Contains(build.Output, "syntax error") {
+		t.Errorf("unexpected build failure: %+v", build)
+	}
+	summary := report.Format(2)
+	if !strings.Contains(summary, "passed: 3, failed: 3, skipped: 1") || !strings.Contains(summary, "1. demo/a TestSum/neg (sum_test.go:12)") || !strings.Contains(summary, "1 more failures are omitted") {
+		t.Errorf("unexpected summary:\n%s", summary)
+	}
+}
+
+func TestParseJUnitXML(t *testing.T) {
+	data := `<?xml version="1.0" encoding="utf-8"?>
+<testsuites><testsuite name="pytest" tests="4">
+<testcase classname="tests.test_math" name="test_add" file="tests/test_math.py" line="3" time="0.001"/>
+<testcase classname="tests.test_math.TestDiv" name="test_zero" file="tests/test_math.py" line="10" time="0.002"><failure message="assert 2 == 3">def test_zero(self):
+&gt;       assert div(4, 2) == 3
+E       assert 2 == 3
+
+tests/test_math.py:12: AssertionError</failure><system-out>dividing 4 by 2</system-out></testcase>
+<testcase classname="tests.test_math" name="test_skip" file="tests/test_math.py" line="20"><skipped message="not ready"/></testcase>
+<testcase classname="tests.test_io" name="test_read" file="tests/test_io.py" line="1"><error message="fixture 'db' not found"/></testcase>
+</testsuite></testsuites>`
+	report, err := utils.ParseJUnitXML([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Passed != 1 || report.Failed != 2 || report.Skipped != 1 {
+		t.Fatalf("unexpected counts: %+v", report)
+	}
+	f := report.Failures[0]
+	if f.Suite != "tests.test_math.TestDiv" || f.Location() != "tests/test_math.py:12" || f.Message != "assert 2 == 3" || !strings.Contains(f.Output, "dividing 4 by 2") {
+		t.Errorf("unexpected failure: %+v", f)
+	}
+	if report.Failures[1].Location() != "tests/test_io.py:1" || report.Failures[1].Message != "fixture 'db' not found" {
+		t.Errorf("unexpected error: %+v", report.Failures[1])
+	}
+	if _, err := utils.ParseJUnitXML([]byte("not xml")); err == nil {
+		t.Error("invalid xml should be rejected")
+	}
+}
+
+func TestParseJestJSON(t *testing.T) {
+	wd, _ := os.Getwd()
+	data := `{"numPassedTests":1,"testResults":[
+{"name":"` + wd + `/src/sum.test.ts","status":"failed","assertionResults":[
+{"fullName":"sum adds","status":"passed","failureMessages":[]},
+{"fullName":"sum handles negatives","status":"failed","location":{"line":8,"column":3},"failureMessages":["Error: \u001b[2mexpect(\u001b[22mreceived).toBe(expected)\n\nExpected: -1\nReceived: 1\n    at Object.<anonymous> (` + wd + `/src/sum.test.ts:10:17)\n    at node_modules/jest/run.js:1:1"]},
+{"fullName":"sum todo","status":"todo","failureMessages":[]}]},
+{"name":"` + wd + `/src/broken.test.ts","status":"failed","message":"SyntaxError: Unexpected token (3:4)","assertionResults":[]}]}`
+	report, err := utils.ParseJestJSON([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Passed != 1 || report.Failed != 2 || report.Skipped != 1 {
+		t.Fatalf("unexpected counts: %+v", report)
+	}
+	f := report.Failures[0]
+	if f.Name != "sum handles negatives" || f.Location() != "src/sum.test.ts:10" || f.Message != "Error: expect(received).toBe(expected)\n\nExpected: -1\nReceived: 1" {
+		t.Errorf("unexpected failure: %+v", f)
+	}
+	if report.Failures[1].Suite != "src/broken.test.ts" || report.Failures[1].Name != "" || !strings.HasPrefix(report.Failures[1].Message, "SyntaxError") {
+		t.Errorf("unexpected suite failure: %+v", report.Failures[1])
+	}
+}
+
+func TestParseCargoTest(t *testing.T) {
+	out := `     Running unittests src/lib.rs (target/debug/deps/demo-1234)
+
+running 4 tests
+test tests::adds ... ok
+test tests::ignored ... ignored
+test tests::subs ... FAILED
+test tests::old ... FAILED
+
+failures:
+
+---- tests::subs stdout ----
+thread 'tests::subs' panicked at src/lib.rs:20:9:
+assertion ` + "`left == right`" + ` failed
+  left: 1
+ right: 2
+note: run with ` + "`RUST_BACKTRACE=1`" + ` environment variable to display a backtrace
+
+---- tests::old stdout ----
+thread 'tests::old' panicked at 'boom', src/lib.rs:30:5
+
+
+failures:
+    tests::subs
+    tests::old
+
+test result: FAILED. 1 passed; 2 failed; 1 ignored; 0 measured; 0 filtered out`
+	report := utils.ParseCargoTest(out)
+	if report.Passed != 1 || report.Failed != 2 || report.Skipped != 1 {
+		t.Fatalf("unexpected counts: %+v", report)
+	}
+	f := report.Failures[0]
+	if f.Suite != "src/lib.rs" || f.Name != "tests::subs" || f.Location() != "src/lib.rs:20" || f.Message != "assertion `left == right` failed\n  left: 1\n right: 2" {
+		t.Errorf("unexpected failure: %+v", f)
+	}
+	if report.Failures[1].Location() != "src/lib.rs:30" || report.Failures[1].Message != "boom" {
+		t.Errorf("unexpected failure: %+v", report.Failures[1])
+	}
+	build := utils.ParseCargoTest("   Compiling demo v0.1.0\nerror[E0425]: cannot find value `x` in this scope\n --> src/lib.rs:2:5")
+	if build.Failed != 1 || !strings.HasPrefix(build.Failures[0].Output, "error[E0425]") {
+		t.Errorf("unexpected build failure: %+v", build.Failures)
+	}
+}
+
+func TestBuildTestCommand(t *testing.T) {
+	failures := []*utils.TestFailure{
+		{Suite: "demo/a", Name: "TestSum/neg"},
+		{Suite: "demo/a", Name: "TestPanic"},
+		{Suite: "demo/c", Name: "TestSum"},
+	}
+	cmd, err := tools.BuildTestCommand(&tools.RunTestsToolResult{Framework: tools.TEST_FRAMEWORK_GO, Target: "./..."}, failures, "")
+	if err != nil || cmd != `go test -json -run '^(TestSum|TestPanic)$' 'demo/a' 'demo/c'` {
+		t.Errorf("unexpected go command: %s %v", cmd, err)
+	}
+	cmd, _ = tools.BuildTestCommand(&tools.RunTestsToolResult{Framework: tools.TEST_FRAMEWORK_GO}, append(failures, &utils.TestFailure{Suite: "demo/b"}), "")
+	if cmd != `go test -json 'demo/a' 'demo/c' 'demo/b'` {
+		t.Errorf("build failure should rerun the whole package: %s", cmd)
+	}
+	// 非JSON输出中解析出的编译失败没有包名，重跑上次的目标
+	cmd, _ = tools.BuildTestCommand(&tools.RunTestsToolResult{Framework: tools.TEST_FRAMEWORK_GO, Target: "./cmd/..."}, []*utils.TestFailure{{Message: "build failed"}}, "")
+	if cmd != `go test -json './cmd/...'` {
+		t.Errorf("build failure without package should rerun the target: %s", cmd)
+	}
+	cmd, _ = tools.BuildTestCommand(&tools.RunTestsToolResult{Framework: tools.TEST_FRAMEWORK_JEST}, []*utils.TestFailure{{File: "src/a.test.ts", Name: "a (b)"}}, "/tmp/r.json")
+	if !strings.HasSuffix(cmd, `--outputFile='/tmp/r.json' -t '^(a \(b\))$' 'src/a.test.ts'`) {
+		t.Errorf("unexpected jest command: %s", cmd)
+	}
+	cmd, _ = tools.BuildTestCommand(&tools.RunTestsToolResult{Framework: tools.TEST_FRAMEWORK_CARGO, Target: "demo"}, []*utils.TestFailure{{Name: "tests::subs"}}, "")
+	if cmd != `cargo test -p 'demo' -- --exact 'tests::subs'` {
+		t.Errorf("unexpected cargo command: %s", cmd)
+	}
+	if _, err := tools.BuildTestCommand(&tools.RunTestsToolResult{Framework: "mocha"}, nil, ""); err == nil {
+		t.Error("unknown framework should be rejected")
+	}
+}
+
+func TestDetectTestFramework(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if _, err := tools.DetectTestFramework(); err == nil {
+		t.Error("empty workspace should not be detected")
+	}
+	os.WriteFile("pyproject.toml", []byte("[tool.pytest.ini_options]\n"), 0644)
+	if framework, _ := tools.DetectTestFramework(); framework != tools.TEST_FRAMEWORK_PYTEST {
+		t.Errorf("expected pytest, got %s", framework)
+	}
+	os.WriteFile("package.json", []byte(`{"devDependencies":{"vitest":"^1.0.0"}}`), 0644)
+	if framework, _ := tools.DetectTestFramework(); framework != tools.TEST_FRAMEWORK_VITEST {
+		t.Errorf("expected vitest, got %s", framework)
+	}
+}
+
+func TestRunTestsGo(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.WriteFile("go.mod", []byte("module demo\n\ngo 1.21\n"), 0644)
+	os.WriteFile("demo_test.go", []byte(`package demo
+
+import "testing"
+
+func TestOk(t *testing.T) {}
+
+func TestBad(t *testing.T) {
+	t.Errorf("want %d, got %d", 1, 2)
+}
+`), 0644)
+	input := &fakeInput{choice: "Skip"}
+	if out := callMcpTool(t, tools.TOOL_RUN_TESTS, `{}`, input); out.Error == nil {
+		t.Error("skipped run should return an error")
+	}
+	out := callMcpTool(t, tools.TOOL_RUN_TESTS, `{}`, &fakeInput{})
+	if out.Error != nil {
+		t.Fatal(out.Error)
+	}
+	if !strings.Contains(out.Content, "passed: 1, failed: 1, skipped: 0") || !strings.Contains(out.Content, "demo TestBad (demo_test.go:8)") || !strings.Contains(out.Content, "message: want 1, got 2") {
+		t.Fatalf("unexpected summary:\n%s", out.Content)
+	}
+	out = callMcpTool(t, tools.TOOL_RUN_TESTS, `{"rerun_failed":true}`, &fakeInput{})
+	if out.Error != nil || !strings.Contains(out.Content, `-run '^(TestBad)$'`) || !strings.Contains(out.Content, "passed: 0, failed: 1") {
+		t.Errorf("unexpected rerun:\n%+v", out)
+	}
+
+	// 用户中断时终止测试命令，返回中断而不是解析失败
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	call := &llm.ToolCall{}
+	call.Function.Name = tools.TOOL_RUN_TESTS
+	call.Function.Arguments = `{}`
+	headless := &tools.AgentInput{ToolCall: call}
+	headless.SetHeadless()
+	if out := tools.RunTests(ctx, headless); out.InterruptErr == nil || !strings.Contains(out.InterruptErr.Error(), "interrupted") {
+		t.Errorf("canceled run should be interrupted: %+v", out)
+	}
+}
@@ -0,0 +1,58 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"bergo/utils"
+	"bergo/utils/cli"
+)
+
+func TestSteerQueue(t *testing.T) {
+	cli.PushSteer("  use the v2 api  ")
+	cli.PushSteer("   ")
+	cli.PushSteer("skip the docs")
+	msgs := cli.PopSteers()
+	if len(msgs) != 2 || msgs[0] != "use the v2 api" || msgs[1] != "skip the docs" {
+		t.Fatalf("unexpected steers: %q", msgs)
+	}
+	if msgs := cli.PopSteers(); len(msgs) != 0 {
+		t.Errorf("queue should be empty after pop: %q", msgs)
+	}
+}
+
+func TestSteerTimeline(t *testing.T) {
+	timeline := &utils.Timeline{}
+	timeline.AddUserInput(&utils.Query{UserInput: "refactor"})
+	timeline.AddLLMResponse("working", "", "", nil, "")
+	timeline.AddSteer("use the v2 api")
+	for i, item := range timeline.Items {
+		timeline.Items[i] = item.ToSerializable().ToTimelineItem()
+	}
+	chats := timeline.GetChatContext(false)
+	if len(chats) != 3 {
+		t.Fatalf("expected 3 chats, got %d", len(chats))
+	}
+	last := chats[2]
+	if last.Role != "user" || !strings.Contains(last.Message, "<user_input>") || !strings.Contains(last.Message, "use the v2 api") {
+		t.Errorf("unexpected steer chat: %+v", last)
+	}
+	history := timeline.ToBriefHistory()
+	if len(history) != 3 || !strings.Contains(history[2].Title(), "Steer") {
+		t.Errorf("steer should be a separate history item: %d", len(history))
+	}
+}
+
+func TestPauseSteerCapture(t *testing.T) {
+	// 没有开始读取时暂停和恢复都不会启动窗口，恢复函数可以重复调用
+	resume := cli.PauseSteerCapture()
+	inner := cli.PauseSteerCapture()
+	inner()
+	inner()
+	resume()
+	cli.StopSteerCapture()
+	cli.PushSteer("keep me")
+	if msgs := cli.PopSteers(); len(msgs) != 1 || msgs[0] != "keep me" {
+		t.Errorf("stopping capture should keep queued steers: %q", msgs)
+	}
+}
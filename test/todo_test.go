@@ -0,0 +1,61 @@
+package test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"bergo/utils"
+)
+
+func TestTodoListTimeline(t *testing.T) {
+	timeline := &utils.Timeline{}
+	timeline.AddUserInput(&utils.Query{UserInput: "do it"})
+	timeline.AddTodoList(&utils.TodoList{Items: []*utils.TodoItem{
+		{Id: "1", Content: "read code", Status: utils.TODO_DONE},
+		{Id: "2", Content: "write code", Status: utils.TODO_IN_PROGRESS},
+	}})
+	timeline.AddLLMResponse("ok", "", "", nil, "")
+	timeline.AddCompact()
+
+	// 序列化后再还原
+	for i, item := range timeline.Items {
+		timeline.Items[i] = item.ToSerializable().ToTimelineItem()
+	}
+	list := timeline.GetTodoList()
+	if list == nil || len(list.Items) != 2 || list.Items[1].Status != utils.TODO_IN_PROGRESS {
+		t.Fatalf("todo list not restored: %+v", list)
+	}
+
+	chats := timeline.GetChatContext(false)
+	if len(chats) != 1 {
+		t.Fatalf("expected only compact message, got %d chats", len(chats))
+	}
+	if !strings.Contains(chats[0].Message, "- [x] 1. read code\n- [ ] 2. write code (in progress)\n") {
+		t.Errorf("todo list not kept after compact: %s", chats[0].Message)
+	}
+}
+
+func TestUpdateMementoTodo(t *testing.T) {
+	wd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(wd)
+
+	list := &utils.TodoList{Items: []*utils.TodoItem{{Id: "1", Content: "new task", Status: utils.TODO_PENDING}}}
+	os.WriteFile(".bergo.memento", []byte("# previous\nsummary\n# todo\n- old task\n# info\nkeep me\n"), 0644)
+	if err := utils.UpdateMementoTodo(list); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := os.ReadFile(".bergo.memento")
+	expected := "# previous\nsummary\n# todo\n- [ ] 1. new task\n# info\nkeep me\n"
+	if string(content) != expected {
+		t.Errorf("unexpected memento:\n%s", content)
+	}
+
+	os.WriteFile(".bergo.memento", []byte("# previous\nsummary\n"), 0644)
+	utils.UpdateMementoTodo(list)
+	content, _ = os.ReadFile(".bergo.memento")
+	if string(content) != "# previous\nsummary\n# todo\n- [ ] 1. new task\n" {
+		t.Errorf("todo section not appended:\n%s", content)
+	}
+}
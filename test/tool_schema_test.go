@@ -0,0 +1,78 @@
+package test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"bergo/llm"
+	"bergo/tools"
+)
+
+func TestToolPropertyKeywords(t *testing.T) {
+	min := 1.0
+	params := llm.ToolParameters{
+		Type: "object",
+		Properties: map[string]llm.ToolProperty{
+			"level": {Type: "string", Enum: []interface{}{"low", "high"}, Default: "low"},
+			"count": {Type: "integer", Minimum: &min},
+			"target": {OneOf: []*llm.ToolProperty{
+				{Type: "string"},
+				{Type: "object", Properties: map[string]llm.ToolProperty{"path": {Type: "string"}}, Required: []string{"path"}, AdditionalProperties: false},
+			}},
+			"extra": {Raw: json.RawMessage(`{"type":"string","pattern":"^x"}`)},
+		},
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"enum":["low","high"]`, `"default":"low"`, `"minimum":1`, `"oneOf":[`, `"additionalProperties":false`, `"pattern":"^x"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("%s not found in %s", want, data)
+		}
+	}
+	if strings.Contains(string(data), `"required":null`) {
+		t.Errorf("empty required should be omitted: %s", data)
+	}
+
+	// anthropic的input_schema和openai的parameters使用相同的定义
+	tool, _ := json.Marshal(llm.AnthropicTool{Name: "t", InputSchema: params})
+	if !strings.Contains(string(tool), `"input_schema":{"type":"object","properties":`) || !strings.Contains(string(tool), `"enum":["low","high"]`) {
+		t.Errorf("unexpected anthropic tool: %s", tool)
+	}
+}
+
+func TestToolParametersRaw(t *testing.T) {
+	raw := json.RawMessage(`{"$schema":"http://json-schema.org/draft-07/schema#","properties":{"a":{"type":"integer"},"b":{"type":"integer"}},"anyOf":[{"required":["a"]},{"required":["b"]}]}`)
+	params := llm.ToolParameters{Raw: raw}
+	full, err := params.JSONSchema()
+	if err != nil || string(full) != string(raw) {
+		t.Errorf("validation should use the raw schema, got %s %v", full, err)
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := map[string]interface{}{}
+	json.Unmarshal(data, &sent)
+	if sent["type"] != "object" || sent["anyOf"] != nil || sent["$schema"] != nil || sent["properties"] == nil {
+		t.Errorf("unexpected schema sent to model: %s", data)
+	}
+	if _, err := json.Marshal(llm.ToolParameters{Raw: json.RawMessage(`{`)}); err == nil {
+		t.Error("invalid raw schema should fail")
+	}
+}
+
+func TestToolSchemaEnumValidation(t *testing.T) {
+	call := &llm.ToolCall{}
+	call.Function.Name = tools.TOOL_TODO_WRITE
+	call.Function.Arguments = `{"todos":[{"content":"a","status":"finished"}]}`
+	if err := tools.JsonSchemaExam(call); err == nil {
+		t.Error("status out of enum should be rejected")
+	}
+	call.Function.Arguments = `{"todos":[{"content":"a","status":"done"}]}`
+	if err := tools.JsonSchemaExam(call); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,75 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"bergo/utils"
+)
+
+func TestVerifierRun(t *testing.T) {
+	var started []string
+	verifier := utils.Verifier{
+		Commands: []string{"true", "echo broken && exit 2", "echo unreachable"},
+		Timeout:  10 * time.Second,
+	}
+	failure := verifier.Run(context.Background(), func(command string) {
+		started = append(started, command)
+	})
+	if failure == nil {
+		t.Fatal("expected failure")
+	}
+	if failure.Command != "echo broken && exit 2" {
+		t.Errorf("unexpected failed command: %s", failure.Command)
+	}
+	if !strings.Contains(failure.Output, "broken") {
+		t.Errorf("unexpected output: %s", failure.Output)
+	}
+	if len(started) != 2 {
+		t.Errorf("commands after the failure should not run, started: %v", started)
+	}
+
+	verifier.Commands = []string{"true", ""}
+	if failure := verifier.Run(context.Background(), nil); failure != nil {
+		t.Errorf("expected pass, got %v", failure)
+	}
+}
+
+func TestShellRunCommandTimeout(t *testing.T) {
+	shell := &utils.Shell{}
+	// sleep不是最后一条命令，bash会fork出子进程，超时后需要终止整个进程组
+	start := time.Now()
+	output, err := shell.RunCommand(context.Background(), "echo started; sleep 5; echo done", time.Second)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 4*time.Second {
+		t.Errorf("timeout did not stop the command, took %v", elapsed)
+	}
+	if output != "started" {
+		t.Errorf("unexpected output: %q", output)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+	start = time.Now()
+	if _, err := shell.RunCommand(ctx, "sleep 5; echo done", time.Minute); err == nil || !strings.Contains(err.Error(), "interrupted") {
+		t.Errorf("expected interrupted, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 4*time.Second {
+		t.Errorf("cancel did not stop the command, took %v", elapsed)
+	}
+}
+
+func TestTailLines(t *testing.T) {
+	content := "1\n2\n3\n4\n5"
+	if got := utils.TailLines(content, 10); got != content {
+		t.Errorf("short content should be unchanged, got %q", got)
+	}
+	got := utils.TailLines(content, 2)
+	if !strings.HasSuffix(got, "4\n5") || !strings.Contains(got, "3 lines omitted") {
+		t.Errorf("unexpected tail: %q", got)
+	}
+}
@@ -0,0 +1,124 @@
+package tools
+
+import (
+	"bergo/berio"
+	"bergo/config"
+	"bergo/llm"
+	"bergo/locales"
+	"bergo/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	TOOL_ASK_USER = "ask_user"
+)
+
+type AskUserToolResult struct {
+	Question      string   `json:"question"`
+	Options       []string `json:"options"`
+	AllowFreeText bool     `json:"allow_free_text"`
+}
+
+// AskUser 向用户提问，用户的回答作为工具结果返回，agentic循环不会中断
+// 无法交互时（子任务或者没有输入源）返回配置的默认回答
+func AskUser(ctx context.Context, input *AgentInput) *AgentOutput {
+	stub := AskUserToolResult{}
+	json.Unmarshal([]byte(input.ToolCall.Function.Arguments), &stub)
+	question := strings.TrimSpace(stub.Question)
+	if question == "" {
+		return &AgentOutput{
+			Error: fmt.Errorf("question is empty"),
+		}
+	}
+
+	if input.isTask || input.Input == nil {
+		return defaultAnswer(input, "no interactive user in this context")
+	}
+
+	var options []string
+	for _, option := range stub.Options {
+		if option = strings.TrimSpace(option); option != "" {
+			options = append(options, option)
+		}
+	}
+	otherOption := locales.Sprintf("Other (type your own answer)")
+	answer := ""
+	if len(options) > 0 {
+		if stub.AllowFreeText {
+			options = append(options, otherOption)
+		}
+		answer = input.Input.Select(question, options)
+		if answer == "" {
+			return defaultAnswer(input, "user did not choose an option")
+		}
+	}
+	if len(options) == 0 || answer == otherOption {
+		if input.Output != nil {
+			input.Output.OnSystemMsg(utils.InfoMessageStyle("❓ "+question), berio.MsgTypeDump)
+		}
+		text, err := input.Input.Read()
+		if err != nil {
+			return defaultAnswer(input, fmt.Sprintf("failed to read answer: %v", err))
+		}
+		answer = strings.TrimSpace(text)
+		if answer == "" {
+			return defaultAnswer(input, "user gave an empty answer")
+		}
+	}
+	return &AgentOutput{
+		Content:  fmt.Sprintf("user answered: %s", answer),
+		ToolCall: input.ToolCall,
+	}
+}
+
+func defaultAnswer(input *AgentInput, reason string) *AgentOutput {
+	return &AgentOutput{
+		Content:  fmt.Sprintf("%s, default answer: %s", reason, config.GlobalConfig.AskUserDefaultAnswer),
+		ToolCall: input.ToolCall,
+	}
+}
+
+func AskUserSchema() *llm.ToolSchema {
+	return &llm.ToolSchema{
+		Type: "function",
+		Function: llm.ToolFunctionDefinition{
+			Name:        TOOL_ASK_USER,
+			Description: "ask_user是用来在任务进行中向用户提问的工具，用户的回答会作为工具结果返回，任务可以继续进行。当用户的需求有歧义、存在多个合理方案需要用户决定，或者缺少继续任务必须的信息时使用，不要为了可以自己判断的小事打扰用户。提供options时用户从中选择，否则用户自由输入回答。",
+			Parameters: llm.ToolParameters{
+				Type: "object",
+				Properties: map[string]llm.ToolProperty{
+					"question": {
+						Type:        "string",
+						Description: "要问用户的问题，简洁明确",
+					},
+					"options": {
+						Type:        "array",
+						Description: "可选的答案列表，如果省略则让用户自由输入",
+						Items: &llm.ToolProperty{
+							Type: "string",
+						},
+					},
+					"allow_free_text": {
+						Type:        "boolean",
+						Description: "提供options时，是否允许用户不选择选项而自由输入回答",
+					},
+				},
+				Required: []string{"question"},
+			},
+		},
+	}
+}
+
+var AskUserToolDesc = &ToolDesc{
+	Name:   TOOL_ASK_USER,
+	Intent: locales.Sprintf("Bergo is asking you a question"),
+	Schema: AskUserSchema(),
+	OutputFunc: func(call *llm.ToolCall, content string) string {
+		stub := &AskUserToolResult{}
+		json.Unmarshal([]byte(call.Function.Arguments), stub)
+		return utils.InfoMessageStyle(fmt.Sprintf("❓ %s\n%s", stub.Question, content))
+	},
+}
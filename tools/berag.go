@@ -10,8 +10,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -19,14 +23,35 @@ const (
 	TOOL_BERAG          = "berag"
 	TOOL_BERAG_EXTRACT  = "berag_extract"
 	TOOL_EXTRACT_RESULT = "extract_result"
+
+	// 提供给berag子agent的缓存总结数量上限
+	BERAG_CACHE_HINT_COUNT = 30
+	// 每条缓存总结在提示中保留的字符数
+	BERAG_CACHE_HINT_LENGTH = 200
+)
+
+var (
+	beragCacheOnce sync.Once
+	beragCache     *utils.BeragCache
 )
 
+// GetBeragCache 工作区的berag提取结果缓存，配置中关闭缓存时返回nil
+func GetBeragCache() *utils.BeragCache {
+	if config.GlobalConfig == nil || config.GlobalConfig.BeragCacheMaxEntries < 0 {
+		return nil
+	}
+	beragCacheOnce.Do(func() {
+		beragCache = utils.NewBeragCache(utils.BERAG_CACHE_FILE, config.GlobalConfig.BeragCacheMaxEntries, config.GlobalConfig.BeragCacheMaxSize*1024*1024)
+	})
+	return beragCache
+}
+
 type BeragToolResult struct {
 	Content string `json:"content"`
 }
 
-var BeragToolScope = []string{TOOL_BERAG_EXTRACT, TOOL_READ_FILE, TOOL_STOP_LOOP, TOOL_SHELL_CMD}
-var BeragExtractToolScope = []string{TOOL_READ_FILE, TOOL_EXTRACT_RESULT}
+var BeragToolScope = []string{TOOL_BERAG_EXTRACT, TOOL_SEARCH_CODE, TOOL_READ_FILE, TOOL_READ_FILES, TOOL_STOP_LOOP, TOOL_SHELL_CMD}
+var BeragExtractToolScope = []string{TOOL_READ_FILE, TOOL_READ_FILES, TOOL_EXTRACT_RESULT}
 
 func BeragToolScheme() *llm.ToolSchema {
 	return &llm.ToolSchema{
@@ -124,28 +149,136 @@ func ExtractResultToolScheme() *llm.ToolSchema {
 }
 
 type ExtractItem struct {
-	Path    string
-	Target  string
-	Content string
+	Path      string
+	Target    string
+	Content   string
+	StartLine int // 片段在文件中的起止行号，从1开始，包含EndLine
+	EndLine   int
+	Source    string // 提取出该片段的berag_extract目标，合并时同一个目标只计一次
 }
 
 func (e *ExtractItem) String() string {
 	return fmt.Sprintf("<extract_item>## %s\n%s</extract_item>", e.Target, e.Content)
 }
 
+// MergeExtractItems 按文件合并重叠和相邻的片段并去掉重复的部分，结果按文件和行号排序。
+// 总行数超过lineBudget时优先保留被更多子agent选中的片段，票数按不同的Source计算，没有Source的片段各计一票。
+// 返回值中的omitted为因预算被省略的范围
+func MergeExtractItems(items []*ExtractItem, lineBudget int) ([]*ExtractItem, []string) {
+	type mergedItem struct {
+		item    *ExtractItem
+		lines   map[int]string
+		sources map[string]bool
+		votes   int
+	}
+	byPath := map[string][]*ExtractItem{}
+	for _, item := range items {
+		if item.StartLine < 1 || item.EndLine < item.StartLine {
+			continue
+		}
+		path := filepath.Clean(item.Path)
+		byPath[path] = append(byPath[path], item)
+	}
+	var merged []*mergedItem
+	for path, list := range byPath {
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].StartLine < list[j].StartLine
+		})
+		var cur *mergedItem
+		for _, item := range list {
+			if cur == nil || item.StartLine > cur.item.EndLine+1 {
+				cur = &mergedItem{item: &ExtractItem{Path: path, StartLine: item.StartLine, EndLine: item.EndLine}, lines: map[int]string{}, sources: map[string]bool{}}
+				merged = append(merged, cur)
+			}
+			cur.item.EndLine = max(cur.item.EndLine, item.EndLine)
+			if item.Source == "" || !cur.sources[item.Source] {
+				cur.votes++
+			}
+			if item.Source != "" {
+				cur.sources[item.Source] = true
+			}
+			for i, line := range strings.SplitAfter(item.Content, "\n") {
+				if line == "" {
+					continue
+				}
+				if _, ok := cur.lines[item.StartLine+i]; !ok {
+					cur.lines[item.StartLine+i] = line
+				}
+			}
+		}
+	}
+	// 选中次数多的片段优先占用预算
+	sort.SliceStable(merged, func(i, j int) bool {
+		if merged[i].votes != merged[j].votes {
+			return merged[i].votes > merged[j].votes
+		}
+		if merged[i].item.Path != merged[j].item.Path {
+			return merged[i].item.Path < merged[j].item.Path
+		}
+		return merged[i].item.StartLine < merged[j].item.StartLine
+	})
+	var result []*ExtractItem
+	var omitted []string
+	used := 0
+	for _, m := range merged {
+		item := m.item
+		count := item.EndLine - item.StartLine + 1
+		if lineBudget > 0 && used+count > lineBudget {
+			remain := lineBudget - used
+			if remain <= 0 {
+				omitted = append(omitted, fmt.Sprintf("%s:%d-%d", item.Path, item.StartLine, item.EndLine))
+				continue
+			}
+			omitted = append(omitted, fmt.Sprintf("%s:%d-%d", item.Path, item.StartLine+remain, item.EndLine))
+			item.EndLine = item.StartLine + remain - 1
+			count = remain
+		}
+		used += count
+		content := bytes.NewBufferString("")
+		for line := item.StartLine; line <= item.EndLine; line++ {
+			content.WriteString(m.lines[line])
+		}
+		item.Content = content.String()
+		item.Target = fmt.Sprintf("%s:%d-%d", item.Path, item.StartLine, item.EndLine)
+		result = append(result, item)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Path != result[j].Path {
+			return result[i].Path < result[j].Path
+		}
+		return result[i].StartLine < result[j].StartLine
+	})
+	return result, omitted
+}
+
 func Berag(ctx context.Context, input *AgentInput) *AgentOutput {
 	chats := input.TaskChats
 	RemoveLastAssistantChatToolCall(chats)
 	q := utils.Query{}
 	stub := &BeragToolResult{}
 	json.Unmarshal([]byte(input.ToolCall.Function.Arguments), stub)
-	q.SetUserInput(stub.Content)
+	query := beragQuery(chats, stub.Content)
+	keywords := utils.BeragKeywords(query)
+	userInput := stub.Content
+	if candidates := beragCandidates(query); candidates != "" {
+		userInput += "\n" + candidates
+	}
+	if cache := GetBeragCache(); cache != nil {
+		if hints := cache.Hints(keywords, BERAG_CACHE_HINT_COUNT); len(hints) > 0 {
+			userInput += "\n" + beragCacheHint(hints)
+		}
+	}
+	q.SetUserInput(userInput)
 	q.SetMode(prompt.MODE_BERAG)
 	chats = append(chats, &llm.ChatItem{
 		Role:    "user",
 		Message: q.Build(),
 	})
-	shared := &SharedExtract{}
+	shared := &SharedExtract{
+		Keywords:    keywords,
+		TokenBudget: config.GlobalConfig.BeragTokenBudget,
+		CostBudget:  config.GlobalConfig.BeragCostBudget,
+	}
 	id := NewTaskID()
 	task := &Task{
 		ID:              id,
@@ -153,6 +286,8 @@ func Berag(ctx context.Context, input *AgentInput) *AgentOutput {
 		ToolScope:       BeragToolScope,
 		Mode:            prompt.MODE_BERAG,
 		ParallelToolUse: true,
+		MaxConcurrency:  config.GlobalConfig.BeragConcurrency,
+		ToolTimeout:     time.Duration(config.GlobalConfig.BeragExtractTimeout) * time.Second,
 		shared:          shared,
 		Model:           config.GlobalConfig.BeragModel,
 		output:          input.Output,
@@ -176,17 +311,39 @@ func Berag(ctx context.Context, input *AgentInput) *AgentOutput {
 
 	answer := task.Run(ctx, input)
 	close(done)
-	if answer.Error != nil {
+	if cache := GetBeragCache(); cache != nil {
+		cache.Flush()
+	}
+	// 预算用完时返回已经提取到的内容
+	stopped := ""
+	if errors.Is(answer.Error, ErrBudgetExhausted) {
+		stopped = answer.Error.Error()
+	} else if answer.Error != nil {
 		answer.InterruptErr = answer.Error
 		return answer
 	}
 
-	list := shared.GetAll()
+	list, omitted := MergeExtractItems(shared.GetAll(), config.GlobalConfig.BeragLineBudget)
 	usage := shared.GetUsage()
-	input.Output.OnSystemMsg(fmt.Sprintf("berag found %d items\ntoken usage: %v", len(list), usage.String()), berio.MsgTypeText)
+	msg := fmt.Sprintf("berag found %d items\ntoken usage: %v", len(list), usage.String())
+	if len(omitted) > 0 {
+		msg += fmt.Sprintf("\n%d ranges omitted by line budget", len(omitted))
+	}
+	if stopped != "" {
+		msg += "\nberag stopped early: " + stopped
+	}
+	input.Output.OnSystemMsg(msg, berio.MsgTypeText)
 
 	buff := bytes.NewBufferString(utils.NewTagContent(answer.Content, "summary").WholeContent)
 	buff.WriteString("\n")
+	if stopped != "" {
+		buff.WriteString(utils.NewTagContent(fmt.Sprintf("berag在搜索完成前停止（%s），下面只是停止前提取到的内容，可能不完整，需要时可以缩小范围后再次调用berag或者直接读取文件", stopped), "note").WholeContent)
+		buff.WriteString("\n")
+	}
+	if len(omitted) > 0 {
+		buff.WriteString(utils.NewTagContent(fmt.Sprintf("提取的内容超过了%d行的预算，以下范围没有返回，需要时可以用read_file读取：\n%s", config.GlobalConfig.BeragLineBudget, strings.Join(omitted, "\n")), "note").WholeContent)
+		buff.WriteString("\n")
+	}
 	for _, item := range list {
 		buff.WriteString(item.String())
 		buff.WriteString("\n")
@@ -198,11 +355,32 @@ func BeragExtract(ctx context.Context, input *AgentInput) *AgentOutput {
 	stub := &BeragExtractToolResult{}
 	json.Unmarshal([]byte(input.ToolCall.Function.Arguments), stub)
 	target := strings.TrimSpace(stub.FilePath)
+	var keywords []string
+	if input.TasKShared != nil {
+		keywords = input.TasKShared.Keywords
+	}
+	userInput := "目标文件: " + target
+	// 文件内容没有变化并且查询相近时直接复用之前的提取结果，否则把之前的总结作为提示
+	cache := GetBeragCache()
+	hash := utils.FileHash(target)
+	if cache != nil && hash != "" {
+		cached, hint := cache.Lookup(target, hash, keywords)
+		if cached != nil {
+			result := &ExtractResultToolResult{Summary: cached.Summary}
+			for _, item := range cached.Items {
+				result.ExtractItems = append(result.ExtractItems, ToolExtractItem{Path: cached.Path, StartLine: item.StartLine, EndLine: item.EndLine})
+			}
+			return extractResultOutput(input, input.TasKShared, target, result)
+		}
+		if hint != nil && hint.Summary != "" {
+			userInput += "\n该文件在上次提取后没有变化，之前对它的总结如下，可以参考：\n" + hint.Summary
+		}
+	}
 	var chats []*llm.ChatItem
 	chats = append(chats, input.TaskChats...)
 	q := utils.Query{}
 	q.SetMode(prompt.MODE_BERAG_EXTRACT)
-	q.SetUserInput("目标文件: " + target)
+	q.SetUserInput(userInput)
 	RemoveLastAssistantChatToolCall(chats)
 	chats = append(chats, &llm.ChatItem{
 		Role:    "user",
@@ -220,12 +398,27 @@ func BeragExtract(ctx context.Context, input *AgentInput) *AgentOutput {
 		output:          input.Output,
 	}
 	answer := task.Run(ctx, input)
+	if errors.Is(answer.Error, ErrBudgetExhausted) {
+		return &AgentOutput{Content: fmt.Sprintf("skipped: %v", answer.Error), ToolCall: input.ToolCall}
+	}
 	if answer.Error != nil {
 		answer.InterruptErr = answer.Error
 		return answer
 	}
 	result := &ExtractResultToolResult{}
 	json.Unmarshal([]byte(answer.ToolCall.Function.Arguments), result)
+	output := extractResultOutput(input, task.shared, target, result)
+	if cache != nil && hash != "" && output.Error == nil {
+		if entry := beragCacheEntry(target, hash, keywords, result); entry != nil {
+			cache.Put(entry)
+		}
+	}
+	return output
+}
+
+// extractResultOutput 读取提取结果中的片段，记录到shared中并返回给berag子agent
+func extractResultOutput(input *AgentInput, shared *SharedExtract, target string, result *ExtractResultToolResult) *AgentOutput {
+	source := target
 	buff := bytes.NewBufferString("")
 	for _, item := range result.ExtractItems {
 		path := strings.TrimSpace(item.Path)
@@ -245,6 +438,7 @@ func BeragExtract(ctx context.Context, input *AgentInput) *AgentOutput {
 			}
 			content = strings.Join(lines, "")
 			target = path
+			start, end = 1, int64(len(lines))
 		} else {
 			lines, err := ReadFile.ReadFileTruncated(int(start), int(end))
 			if err != nil {
@@ -252,14 +446,21 @@ func BeragExtract(ctx context.Context, input *AgentInput) *AgentOutput {
 			}
 			content = strings.Join(lines, "")
 			target = fmt.Sprintf("%s:%d-%d", path, start, end)
+			start = max(start, 1)
+			end = start + int64(len(lines)) - 1
 		}
 
 		exItem := &ExtractItem{
-			Path:    path,
-			Content: content,
-			Target:  target,
+			Path:      path,
+			Content:   content,
+			Target:    target,
+			StartLine: int(start),
+			EndLine:   int(end),
+			Source:    source,
+		}
+		if shared != nil {
+			shared.Add(exItem)
 		}
-		task.shared.Add(exItem)
 		buff.WriteString(exItem.String())
 		buff.WriteString("\n")
 	}
@@ -275,6 +476,51 @@ func BeragExtract(ctx context.Context, input *AgentInput) *AgentOutput {
 	return &AgentOutput{Content: buff.String(), ToolCall: input.ToolCall}
 }
 
+// beragCacheEntry 把提取结果转换为缓存条目，片段来自其他文件时无法判断是否失效，不缓存
+func beragCacheEntry(target string, hash string, keywords []string, result *ExtractResultToolResult) *utils.BeragCacheEntry {
+	entry := &utils.BeragCacheEntry{Path: target, Hash: hash, Keywords: keywords, Summary: result.Summary}
+	for _, item := range result.ExtractItems {
+		if filepath.Clean(strings.TrimSpace(item.Path)) != filepath.Clean(target) {
+			return nil
+		}
+		entry.Items = append(entry.Items, utils.BeragCacheItem{StartLine: item.StartLine, EndLine: item.EndLine})
+	}
+	return entry
+}
+
+// beragQuery berag的查询内容，没有补充说明时使用最近一次用户输入
+func beragQuery(chats []*llm.ChatItem, content string) string {
+	if strings.TrimSpace(content) != "" {
+		return content
+	}
+	for i := len(chats) - 1; i >= 0; i-- {
+		if chats[i].Role != "user" {
+			continue
+		}
+		message := chats[i].Message
+		start := strings.Index(message, "<user_input>")
+		end := strings.Index(message, "</user_input>")
+		if start >= 0 && end > start {
+			return message[start+len("<user_input>") : end]
+		}
+	}
+	return ""
+}
+
+// beragCacheHint 告诉berag子agent哪些文件之前提取过并且内容没有变化
+func beragCacheHint(hints []*utils.BeragCacheEntry) string {
+	buf := bytes.NewBufferString("<cached_summaries>\n以下文件在之前的berag中提取过并且内容没有变化，这是对它们的总结，可以据此判断需要提取哪些文件。对这些文件调用berag_extract时会优先复用之前的结果\n")
+	for _, hint := range hints {
+		summary := strings.Join(strings.Fields(hint.Summary), " ")
+		if runes := []rune(summary); len(runes) > BERAG_CACHE_HINT_LENGTH {
+			summary = string(runes[:BERAG_CACHE_HINT_LENGTH]) + "..."
+		}
+		buf.WriteString(fmt.Sprintf("- %s: %s\n", hint.Path, summary))
+	}
+	buf.WriteString("</cached_summaries>")
+	return buf.String()
+}
+
 func ExtractResult(ctx context.Context, input *AgentInput) *AgentOutput {
 	return &AgentOutput{ToolCall: input.ToolCall}
 }
@@ -0,0 +1,208 @@
+package tools
+
+import (
+	"bergo/config"
+	"bergo/llm"
+	"bergo/locales"
+	"bergo/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var customToolNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// 配置文件中声明的工具
+var customTools = map[string]*config.CustomToolConfig{}
+
+// RegisterCustomTools 把配置文件中声明的工具注册到ToolsMap和ToolFuncMap，返回注册成功的工具名
+func RegisterCustomTools(confs []*config.CustomToolConfig) ([]string, []error) {
+	UnregisterCustomTools()
+	var names []string
+	var errs []error
+	for _, conf := range confs {
+		if err := registerCustomTool(conf); err != nil {
+			errs = append(errs, fmt.Errorf("tool %s: %w", conf.Name, err))
+			continue
+		}
+		names = append(names, conf.Name)
+	}
+	return names, errs
+}
+
+func registerCustomTool(conf *config.CustomToolConfig) error {
+	if !customToolNameRegex.MatchString(conf.Name) || strings.HasPrefix(conf.Name, MCP_TOOL_PREFIX) {
+		return fmt.Errorf("invalid tool name")
+	}
+	if _, ok := ToolsMap[conf.Name]; ok {
+		return fmt.Errorf("conflicts with an existing tool")
+	}
+	if conf.Command == "" {
+		return fmt.Errorf("command is required")
+	}
+	tmpl, err := template.New(conf.Name).Parse(conf.Command)
+	if err != nil {
+		return fmt.Errorf("parse command: %w", err)
+	}
+	params := llm.ToolParameters{Type: "object"}
+	if strings.TrimSpace(conf.Parameters) != "" {
+		params.Raw = json.RawMessage(conf.Parameters)
+	}
+	desc := &ToolDesc{
+		Name:   conf.Name,
+		Intent: locales.Sprintf("Bergo is running tool %s", conf.Name),
+		Schema: &llm.ToolSchema{
+			Type: "function",
+			Function: llm.ToolFunctionDefinition{
+				Name:        conf.Name,
+				Description: conf.Description,
+				Parameters:  params,
+			},
+		},
+		OutputFunc: func(call *llm.ToolCall, content string) string {
+			return utils.InfoMessageStyle(locales.Sprintf("tool %s executed", call.Function.Name))
+		},
+	}
+	if desc.Schema.Function.Description == "" {
+		desc.Schema.Function.Description = "run " + conf.Command
+	}
+	if desc.Validator, err = compileMcpSchema(nil, &desc.Schema.Function.Parameters); err != nil {
+		return fmt.Errorf("compile parameters: %w", err)
+	}
+	ToolsMap[conf.Name] = desc
+	ToolFuncMap[conf.Name] = customToolFunc(conf, tmpl, schemaPropertyNames(params))
+	customTools[conf.Name] = conf
+	return nil
+}
+
+// UnregisterCustomTools 移除所有配置文件中声明的工具
+func UnregisterCustomTools() {
+	for name := range customTools {
+		delete(ToolsMap, name)
+		delete(ToolFuncMap, name)
+	}
+	customTools = map[string]*config.CustomToolConfig{}
+}
+
+// CustomToolNames 所有已注册的配置文件工具名，按名称排序
+func CustomToolNames() []string {
+	var names []string
+	for name := range customTools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsUntrustedExternalTool 没有配置trust的MCP工具和配置文件工具，主agent调用它们前会询问用户，
+// 子agent中调用时不会询问，需要在启动子agent前确认
+func IsUntrustedExternalTool(name string) bool {
+	if conf, ok := customTools[name]; ok {
+		return !conf.Trust
+	}
+	return strings.HasPrefix(name, MCP_TOOL_PREFIX) && !mcpTrustedTools[name]
+}
+
+// CustomToolModes 可以使用该工具的模式，不是配置文件中声明的工具或者没有限制时返回nil
+func CustomToolModes(name string) []string {
+	if conf, ok := customTools[name]; ok {
+		return conf.Modes
+	}
+	return nil
+}
+
+func schemaPropertyNames(params llm.ToolParameters) []string {
+	schema := struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}{}
+	data, err := params.JSONSchema()
+	if err != nil || json.Unmarshal(data, &schema) != nil {
+		return nil
+	}
+	var names []string
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	return names
+}
+
+// RenderCustomCommand 把参数填入命令模板，参数值会被转义为shell字符串，没有传的参数为空
+func RenderCustomCommand(tmpl *template.Template, properties []string, arguments string) (string, error) {
+	args := map[string]interface{}{}
+	decoder := json.NewDecoder(strings.NewReader(arguments))
+	decoder.UseNumber()
+	if err := decoder.Decode(&args); err != nil && strings.TrimSpace(arguments) != "" {
+		return "", err
+	}
+	data := map[string]string{}
+	for _, name := range properties {
+		data[name] = ""
+	}
+	for name, value := range args {
+		data[name] = shellValue(value)
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func shellValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return utils.ShellQuote(v)
+	case json.Number, bool:
+		return fmt.Sprint(v)
+	default:
+		data, _ := json.Marshal(v)
+		return utils.ShellQuote(string(data))
+	}
+}
+
+func customToolFunc(conf *config.CustomToolConfig, tmpl *template.Template, properties []string) func(ctx context.Context, input *AgentInput) *AgentOutput {
+	return func(ctx context.Context, input *AgentInput) *AgentOutput {
+		arguments := input.ToolCall.Function.Arguments
+		command, err := RenderCustomCommand(tmpl, properties, arguments)
+		if err != nil {
+			return &AgentOutput{Error: fmt.Errorf("render command: %w", err)}
+		}
+		if !conf.Trust && !input.isTask && input.Input != nil && !input.AllowMap[conf.Name] {
+			res := input.Input.Select(locales.Sprintf("Are you sure to run the command: %s", command), []string{locales.Sprintf("Yes"), locales.Sprintf("Always Yes"), locales.Sprintf("Skip")})
+			if res == locales.Sprintf("Skip") {
+				return &AgentOutput{
+					Error: fmt.Errorf("User choose to skip"),
+				}
+			}
+			if res == locales.Sprintf("Always Yes") {
+				input.AllowMap[conf.Name] = true
+			}
+		}
+		stdin := ""
+		if conf.Stdin {
+			stdin = arguments
+		}
+		shell := utils.Shell{IsTask: true}
+		stdout, stderr, err := shell.RunWithStdin(ctx, command, stdin, time.Duration(conf.Timeout)*time.Second)
+		output := stdout
+		if stderr != "" {
+			output = strings.TrimSpace(output + "\nstderr:\n" + stderr)
+		}
+		output = utils.TailLines(output, conf.MaxOutputLines)
+		if err != nil {
+			return &AgentOutput{Error: fmt.Errorf("command %s failed: %v\n%s", command, err, output)}
+		}
+		return &AgentOutput{
+			Content:  fmt.Sprintf("result:\n%s", output),
+			ToolCall: input.ToolCall,
+		}
+	}
+}
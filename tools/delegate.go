@@ -0,0 +1,364 @@
+package tools
+
+import (
+	"bergo/berio"
+	"bergo/config"
+	"bergo/llm"
+	"bergo/locales"
+	"bergo/prompt"
+	"bergo/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TOOL_DELEGATE = "delegate"
+
+	DELEGATE_CONTEXT_FRESH = "fresh"
+	DELEGATE_CONTEXT_FORK  = "fork"
+
+	delegatePending = "pending"
+	delegateRunning = "running"
+	delegateDone    = "done"
+	delegateFailed  = "failed"
+)
+
+// 各模式下子agent默认可用的工具
+var DelegateToolScopes = map[string][]string{
+	prompt.MODE_AGENT:   {TOOL_READ_FILE, TOOL_READ_FILES, TOOL_EDIT_DIFF, TOOL_EDIT_WHOLE, TOOL_REMOVE, TOOL_SHELL_CMD, TOOL_RUN_TESTS, TOOL_BERAG, TOOL_SEARCH_CODE, TOOL_GIT_STATUS, TOOL_GIT_DIFF, TOOL_GIT_LOG, TOOL_GIT_BLAME, TOOL_STOP_LOOP},
+	prompt.MODE_VIEW:    {TOOL_READ_FILE, TOOL_READ_FILES, TOOL_SHELL_CMD, TOOL_BERAG, TOOL_SEARCH_CODE, TOOL_GIT_STATUS, TOOL_GIT_DIFF, TOOL_GIT_LOG, TOOL_GIT_BLAME, TOOL_STOP_LOOP},
+	prompt.MODE_PLANNER: {TOOL_READ_FILE, TOOL_READ_FILES, TOOL_SHELL_CMD, TOOL_BERAG, TOOL_SEARCH_CODE, TOOL_GIT_STATUS, TOOL_GIT_DIFF, TOOL_GIT_LOG, TOOL_GIT_BLAME, TOOL_STOP_LOOP},
+}
+
+// 子agent不能使用的工具，避免递归委派或者和主agent抢占用户交互、memento
+var delegateForbiddenTools = []string{TOOL_DELEGATE, TOOL_ASK_USER, TOOL_TODO_WRITE, TOOL_BERAG_EXTRACT, TOOL_EXTRACT_RESULT, TOOL_GIT_COMMIT}
+
+// 会改动工作区的工具，子agent使用这些工具前需要用户确认
+var delegateWriteTools = []string{TOOL_EDIT_DIFF, TOOL_EDIT_WHOLE, TOOL_REMOVE, TOOL_SHELL_CMD, TOOL_RUN_TESTS}
+
+type DelegateSubtask struct {
+	Task    string   `json:"task"`
+	Context string   `json:"context"`
+	Mode    string   `json:"mode"`
+	Tools   []string `json:"tools"`
+	Model   string   `json:"model"`
+}
+
+type DelegateToolResult struct {
+	Subtasks []*DelegateSubtask `json:"subtasks"`
+}
+
+type delegateState struct {
+	sub      *DelegateSubtask
+	id       string
+	scope    []string
+	status   string
+	summary  string
+	err      error
+	modified []string
+	usage    llm.TokenUsage
+}
+
+// resolve 补全默认值并检查子任务参数
+func (s *DelegateSubtask) resolve() ([]string, error) {
+	s.Task = strings.TrimSpace(s.Task)
+	if s.Task == "" {
+		return nil, fmt.Errorf("task is empty")
+	}
+	if s.Context == "" {
+		s.Context = DELEGATE_CONTEXT_FRESH
+	}
+	if s.Context != DELEGATE_CONTEXT_FRESH && s.Context != DELEGATE_CONTEXT_FORK {
+		return nil, fmt.Errorf("context must be %s or %s", DELEGATE_CONTEXT_FRESH, DELEGATE_CONTEXT_FORK)
+	}
+	if s.Mode == "" {
+		s.Mode = prompt.MODE_AGENT
+	}
+	defaults, ok := DelegateToolScopes[s.Mode]
+	if !ok {
+		return nil, fmt.Errorf("unsupported mode %s", s.Mode)
+	}
+	if s.Model == "" {
+		s.Model = config.GlobalConfig.MainModel
+	}
+	if config.GlobalConfig.GetModelConfig(s.Model) == nil {
+		return nil, fmt.Errorf("model %s not found", s.Model)
+	}
+	if len(s.Tools) == 0 {
+		return defaults, nil
+	}
+	var scope []string
+	for _, name := range s.Tools {
+		if slices.Contains(delegateForbiddenTools, name) || ToolFuncMap[name] == nil {
+			return nil, fmt.Errorf("tool %s is not available for sub agents", name)
+		}
+		// 只读模式下不允许编辑文件，可以运行命令和测试
+		if s.Mode != prompt.MODE_AGENT && slices.Contains(delegateWriteTools, name) && name != TOOL_SHELL_CMD && name != TOOL_RUN_TESTS {
+			return nil, fmt.Errorf("tool %s is not allowed in %s mode", name, s.Mode)
+		}
+		if !slices.Contains(scope, name) {
+			scope = append(scope, name)
+		}
+	}
+	if !slices.Contains(scope, TOOL_STOP_LOOP) {
+		scope = append(scope, TOOL_STOP_LOOP)
+	}
+	return scope, nil
+}
+
+// Delegate 启动一个或多个子agent并发完成独立的子任务，返回每个子任务的总结
+func Delegate(ctx context.Context, input *AgentInput) *AgentOutput {
+	stub := &DelegateToolResult{}
+	json.Unmarshal([]byte(input.ToolCall.Function.Arguments), stub)
+	if len(stub.Subtasks) == 0 {
+		return &AgentOutput{Error: fmt.Errorf("subtasks is empty")}
+	}
+
+	states := make([]*delegateState, 0, len(stub.Subtasks))
+	needConfirm := false
+	for i, sub := range stub.Subtasks {
+		if sub == nil {
+			return &AgentOutput{Error: fmt.Errorf("subtask %d is empty", i+1)}
+		}
+		scope, err := sub.resolve()
+		if err != nil {
+			return &AgentOutput{Error: fmt.Errorf("subtask %d: %v", i+1, err)}
+		}
+		for _, name := range scope {
+			if slices.Contains(delegateWriteTools, name) || IsUntrustedExternalTool(name) {
+				needConfirm = true
+			}
+		}
+		states = append(states, &delegateState{sub: sub, id: NewTaskID(), scope: scope, status: delegatePending})
+	}
+
+	// 子agent运行时不会再询问用户，可以改动工作区时先确认一次
+	if needConfirm && !input.isTask && !input.AllowMap[TOOL_DELEGATE] {
+		var tasks []string
+		for i, state := range states {
+			tasks = append(tasks, fmt.Sprintf("%d. [%s] %s", i+1, state.sub.Mode, state.sub.Task))
+		}
+		res := input.Input.Select(locales.Sprintf("Sub agents may edit files and run commands without asking, start them?\n%s", strings.Join(tasks, "\n")), []string{locales.Sprintf("Yes"), locales.Sprintf("Always Yes"), locales.Sprintf("Skip")})
+		if res == locales.Sprintf("Skip") {
+			return &AgentOutput{Error: fmt.Errorf("user choose not to delegate")}
+		}
+		if res == locales.Sprintf("Always Yes") {
+			input.AllowMap[TOOL_DELEGATE] = true
+		}
+	}
+
+	shared := &SharedExtract{}
+	mu := sync.Mutex{}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(1 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				mu.Lock()
+				progress := delegateProgressInfo(shared, states)
+				mu.Unlock()
+				input.Output.UpdateTail(utils.InfoMessageStyle(progress))
+			}
+		}
+	}()
+
+	concurrency := max(config.GlobalConfig.DelegateConcurrency, 1)
+	sem := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+	for _, state := range states {
+		wg.Add(1)
+		go func(state *delegateState) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			mu.Lock()
+			state.status = delegateRunning
+			mu.Unlock()
+
+			task := newDelegateTask(state, input, shared)
+			answer := task.Run(ctx, input)
+
+			mu.Lock()
+			defer mu.Unlock()
+			state.modified = task.ModifiedFiles()
+			if progress := shared.GetTaskProgress(state.id); progress != nil {
+				state.usage = progress.TokenUsage
+			}
+			if answer.Error != nil || answer.InterruptErr != nil {
+				state.status = delegateFailed
+				state.err = answer.Error
+				if state.err == nil {
+					state.err = answer.InterruptErr
+				}
+				return
+			}
+			state.status = delegateDone
+			state.summary = answer.Content
+		}(state)
+	}
+	wg.Wait()
+	close(done)
+
+	if ctx.Err() != nil {
+		return &AgentOutput{InterruptErr: fmt.Errorf("user interrupted")}
+	}
+
+	buff := bytes.NewBufferString("")
+	var report []string
+	modifiedPath := ""
+	for i, state := range states {
+		buff.WriteString(fmt.Sprintf("<subtask index=\"%d\" status=\"%s\">\ntask: %s\n", i+1, state.status, state.sub.Task))
+		if state.err != nil {
+			buff.WriteString(fmt.Sprintf("error: %v\n", state.err))
+		} else {
+			buff.WriteString(fmt.Sprintf("summary:\n%s\n", state.summary))
+		}
+		if len(state.modified) > 0 {
+			buff.WriteString(fmt.Sprintf("modified files: %s\n", strings.Join(state.modified, ", ")))
+			if modifiedPath == "" {
+				modifiedPath = state.modified[0]
+			}
+		}
+		buff.WriteString("</subtask>\n")
+		report = append(report, locales.Sprintf("SubAgent[%d] %s (%s), token usage: %v", i+1, state.status, state.sub.Model, state.usage.String()))
+	}
+	usage := shared.GetUsage()
+	report = append(report, locales.Sprintf("delegate total token usage: %v", usage.String()))
+	input.Output.OnSystemMsg(strings.Join(report, "\n"), berio.MsgTypeText)
+
+	return &AgentOutput{
+		Content:      buff.String(),
+		ToolCall:     input.ToolCall,
+		ModifiedPath: modifiedPath,
+	}
+}
+
+func newDelegateTask(state *delegateState, input *AgentInput, shared *SharedExtract) *Task {
+	var chats []*llm.ChatItem
+	if state.sub.Context == DELEGATE_CONTEXT_FORK {
+		chats = append(chats, input.TaskChats...)
+		RemoveLastAssistantChatToolCall(chats)
+	}
+	q := utils.Query{}
+	q.SetMode(state.sub.Mode)
+	q.SetUserInput(prompt.GetModePrompt(prompt.MODE_DELEGATE) + "\n" + state.sub.Task)
+	chats = append(chats, &llm.ChatItem{
+		Role:    "user",
+		Message: q.Build(),
+	})
+	sessionId := ""
+	if input.Timeline != nil {
+		sessionId = input.Timeline.SessionId
+	}
+	return &Task{
+		ID:              state.id,
+		Context:         chats,
+		ToolScope:       state.scope,
+		Mode:            state.sub.Mode,
+		ParallelToolUse: false,
+		shared:          shared,
+		Model:           state.sub.Model,
+		sessionId:       sessionId,
+		output:          input.Output,
+	}
+}
+
+func delegateProgressInfo(shared *SharedExtract, states []*delegateState) string {
+	usage := shared.GetUsage()
+	buf := bytes.NewBufferString(locales.Sprintf("delegate running... total usage %v", usage.String()))
+	for idx, state := range states {
+		task := []rune(state.sub.Task)
+		if len(task) > 40 {
+			task = append(task[:40], []rune("...")...)
+		}
+		buf.WriteString(fmt.Sprintf("\nSubAgent[%d] %s %s", idx+1, state.status, string(task)))
+		progress := shared.GetTaskProgress(state.id)
+		if progress == nil {
+			continue
+		}
+		buf.WriteString(fmt.Sprintf(" | %s", progress.TokenUsage.String()))
+		if state.status == delegateRunning {
+			for _, toolCall := range progress.ToolCalls {
+				if desc := ToolsMap[toolCall]; desc != nil && desc.Intent != "" {
+					buf.WriteString(fmt.Sprintf("\n    %s", desc.Intent))
+				}
+			}
+		}
+	}
+	return buf.String()
+}
+
+func DelegateSchema() *llm.ToolSchema {
+	return &llm.ToolSchema{
+		Type: "function",
+		Function: llm.ToolFunctionDefinition{
+			Name:        TOOL_DELEGATE,
+			Description: "delegate用来把独立的子任务委派给SubAgent完成，比如\"给package X写单元测试\"。可以一次提交多个子任务，它们会并发运行，每个子任务完成后返回总结和修改过的文件。子任务之间应该互不依赖，也不要修改相同的文件。SubAgent无法向用户提问，任务描述需要包含足够的信息，或者使用fork继承你当前的上下文。简单的任务自己完成即可，不需要委派。",
+			Parameters: llm.ToolParameters{
+				Type: "object",
+				Properties: map[string]llm.ToolProperty{
+					"subtasks": {
+						Type:        "array",
+						Description: "子任务列表",
+						Items: &llm.ToolProperty{
+							Type: "object",
+							Properties: map[string]llm.ToolProperty{
+								"task": {
+									Type:        "string",
+									Description: "子任务的详细描述，包括目标、相关文件和完成标准",
+								},
+								"context": {
+									Type:        "string",
+									Description: "fresh表示从空白上下文开始，fork表示继承你当前的上下文，默认fresh",
+								},
+								"mode": {
+									Type:        "string",
+									Description: "SubAgent的模式，agent可以编辑文件，view和planner只能收集信息，默认agent",
+								},
+								"tools": {
+									Type:        "array",
+									Description: "SubAgent可以使用的工具列表，如果省略则使用模式对应的默认工具",
+									Items: &llm.ToolProperty{
+										Type: "string",
+									},
+								},
+								"model": {
+									Type:        "string",
+									Description: "SubAgent使用的模型identifier，如果省略则使用主模型",
+								},
+							},
+						},
+					},
+				},
+				Required: []string{"subtasks"},
+			},
+		},
+	}
+}
+
+var DelegateToolDesc = &ToolDesc{
+	Name:   TOOL_DELEGATE,
+	Intent: locales.Sprintf("Bergo is delegating subtasks"),
+	Schema: DelegateSchema(),
+	OutputFunc: func(call *llm.ToolCall, content string) string {
+		stub := &DelegateToolResult{}
+		json.Unmarshal([]byte(call.Function.Arguments), stub)
+		var tasks []string
+		for i, sub := range stub.Subtasks {
+			if sub != nil {
+				tasks = append(tasks, fmt.Sprintf("%d. %s", i+1, sub.Task))
+			}
+		}
+		return utils.InfoMessageStyle(locales.Sprintf("delegated %d subtasks\n%s", len(tasks), strings.Join(tasks, "\n")))
+	},
+}
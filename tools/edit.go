@@ -6,6 +6,7 @@ import (
 	"bergo/utils"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -95,6 +96,21 @@ func checkSyntax(path string) error {
 	return nil
 }
 
+// formatFile 编辑成功后运行配置的格式化命令，并记录文件最新的内容哈希
+// 返回需要附加在工具结果中告知模型的信息
+func formatFile(ctx context.Context, path string) string {
+	defer utils.RecordFileHash(path)
+	formatter := &utils.Formatter{Path: path}
+	formatted, err := formatter.Format(ctx)
+	if err != nil {
+		return fmt.Sprintf("\nwarning: %v", err)
+	}
+	if formatted {
+		return fmt.Sprintf("\n%s has been reformatted by `%s`, read it again before the next edit_diff", path, formatter.Command())
+	}
+	return ""
+}
+
 func EditDiff(ctx context.Context, input *AgentInput) *AgentOutput {
 	stub := EditDiffToolResult{}
 	json.Unmarshal([]byte(input.ToolCall.Function.Arguments), &stub)
@@ -114,6 +130,10 @@ func EditDiff(ctx context.Context, input *AgentInput) *AgentOutput {
 	replace := stub.Replace
 	err = edit.EditByDiff(search, replace)
 	if err != nil {
+		recorded := utils.GetRecordedFileHash(path)
+		if errors.Is(err, utils.ErrEditNoMatch) && recorded != "" && recorded != utils.FileHash(path) {
+			err = fmt.Errorf("%w, %s has been changed since bergo last wrote it, read it again", err, path)
+		}
 		return &AgentOutput{
 			Error: fmt.Errorf("failed to edit %s because: %s", path, err.Error()),
 		}
@@ -125,8 +145,9 @@ func EditDiff(ctx context.Context, input *AgentInput) *AgentOutput {
 		}
 	}
 	return &AgentOutput{
-		Content:  fmt.Sprintf("%s edited successfully", path),
-		ToolCall: input.ToolCall,
+		Content:      fmt.Sprintf("%s edited successfully", path) + formatFile(ctx, path),
+		ToolCall:     input.ToolCall,
+		ModifiedPath: path,
 	}
 }
 
@@ -159,8 +180,9 @@ func EditWhole(ctx context.Context, input *AgentInput) *AgentOutput {
 		}
 	}
 	return &AgentOutput{
-		Content:  fmt.Sprintf("%s edited successfully", path),
-		ToolCall: input.ToolCall,
+		Content:      fmt.Sprintf("%s edited successfully", path) + formatFile(ctx, path),
+		ToolCall:     input.ToolCall,
+		ModifiedPath: path,
 	}
 }
 
@@ -0,0 +1,443 @@
+package tools
+
+import (
+	"bergo/berio"
+	"bergo/llm"
+	"bergo/locales"
+	"bergo/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	TOOL_GIT_STATUS = "git_status"
+	TOOL_GIT_DIFF   = "git_diff"
+	TOOL_GIT_LOG    = "git_log"
+	TOOL_GIT_BLAME  = "git_blame"
+	TOOL_GIT_COMMIT = "git_commit"
+
+	// git工具输出的最大行数，超过时截断
+	GIT_MAX_OUTPUT_LINE = 2000
+	GIT_LOG_DEFAULT     = 20
+	GIT_LOG_MAX         = 200
+)
+
+func newGit() (*utils.Git, error) {
+	git := utils.NewGit(".")
+	if !git.IsRepo() {
+		return nil, fmt.Errorf("the workspace is not in a git repository")
+	}
+	return git, nil
+}
+
+// checkGitRef ref不能以-开头，避免被当作参数
+func checkGitRef(ref string) error {
+	if strings.HasPrefix(ref, "-") || strings.ContainsAny(ref, " \t\n") {
+		return fmt.Errorf("invalid git ref %q", ref)
+	}
+	return nil
+}
+
+func truncateGitOutput(out string, hint string) string {
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	if len(lines) <= GIT_MAX_OUTPUT_LINE {
+		return strings.Join(lines, "\n")
+	}
+	return strings.Join(lines[:GIT_MAX_OUTPUT_LINE], "\n") + fmt.Sprintf("\n...%d lines are truncated, %s...", len(lines)-GIT_MAX_OUTPUT_LINE, hint)
+}
+
+var gitStatusNames = map[byte]string{
+	'M': "modified",
+	'A': "added",
+	'D': "deleted",
+	'R': "renamed",
+	'C': "copied",
+	'T': "typechange",
+}
+
+func isGitConflict(file *utils.GitFileStatus) bool {
+	return file.Index == 'U' || file.Worktree == 'U' || (file.Index == 'A' && file.Worktree == 'A') || (file.Index == 'D' && file.Worktree == 'D')
+}
+
+// FormatGitStatus 把git status按暂存、未暂存、未跟踪和冲突分组
+func FormatGitStatus(status *utils.GitStatus) string {
+	var staged, unstaged, untracked, conflicts []string
+	for _, file := range status.Files {
+		path := file.Path
+		if file.OrigPath != "" {
+			path = file.OrigPath + " -> " + file.Path
+		}
+		switch {
+		case file.Index == '?':
+			untracked = append(untracked, "  "+path)
+		case isGitConflict(file):
+			conflicts = append(conflicts, "  "+path)
+		default:
+			if name, ok := gitStatusNames[file.Index]; ok {
+				staged = append(staged, fmt.Sprintf("  %s: %s", name, path))
+			}
+			if name, ok := gitStatusNames[file.Worktree]; ok {
+				unstaged = append(unstaged, fmt.Sprintf("  %s: %s", name, file.Path))
+			}
+		}
+	}
+	result := []string{"branch: " + status.Branch}
+	if status.Upstream != "" {
+		result[0] += fmt.Sprintf(" (upstream %s, ahead %d, behind %d)", status.Upstream, status.Ahead, status.Behind)
+	}
+	for _, group := range []struct {
+		title string
+		files []string
+	}{{"conflicts", conflicts}, {"staged", staged}, {"unstaged", unstaged}, {"untracked", untracked}} {
+		if len(group.files) > 0 {
+			result = append(result, group.title+":")
+			result = append(result, group.files...)
+		}
+	}
+	if len(status.Files) == 0 {
+		result = append(result, "working tree clean")
+	}
+	return strings.Join(result, "\n")
+}
+
+func GitStatus(ctx context.Context, input *AgentInput) *AgentOutput {
+	git, err := newGit()
+	if err != nil {
+		return &AgentOutput{Error: err}
+	}
+	status, err := git.Status()
+	if err != nil {
+		return &AgentOutput{Error: err}
+	}
+	return &AgentOutput{
+		Content:  truncateGitOutput(FormatGitStatus(status), "use git_diff with paths to check the files"),
+		ToolCall: input.ToolCall,
+	}
+}
+
+type GitDiffToolResult struct {
+	Staged bool     `json:"staged"`
+	From   string   `json:"from"`
+	To     string   `json:"to"`
+	Paths  []string `json:"paths"`
+	Stat   bool     `json:"stat"`
+}
+
+// GitDiffArgs 根据参数生成git diff的参数
+func GitDiffArgs(stub *GitDiffToolResult) ([]string, error) {
+	args := []string{"diff", "--no-ext-diff", "--no-textconv"}
+	if stub.Stat {
+		args = append(args, "--stat")
+	}
+	if stub.Staged {
+		args = append(args, "--cached")
+	}
+	if stub.To != "" && stub.From == "" {
+		return nil, fmt.Errorf("from is required when to is set")
+	}
+	for _, ref := range []string{stub.From, stub.To} {
+		if ref == "" {
+			continue
+		}
+		if err := checkGitRef(ref); err != nil {
+			return nil, err
+		}
+		args = append(args, ref)
+	}
+	args = append(args, "--")
+	return append(args, stub.Paths...), nil
+}
+
+func GitDiff(ctx context.Context, input *AgentInput) *AgentOutput {
+	stub := &GitDiffToolResult{}
+	json.Unmarshal([]byte(input.ToolCall.Function.Arguments), stub)
+	args, err := GitDiffArgs(stub)
+	if err != nil {
+		return &AgentOutput{Error: err}
+	}
+	git, err := newGit()
+	if err != nil {
+		return &AgentOutput{Error: err}
+	}
+	out, err := git.Run(args...)
+	if err != nil {
+		return &AgentOutput{Error: err}
+	}
+	if strings.TrimSpace(out) == "" {
+		out = "no differences"
+	}
+	return &AgentOutput{
+		Content:  truncateGitOutput(out, "use stat or paths to narrow the diff"),
+		ToolCall: input.ToolCall,
+	}
+}
+
+type GitLogToolResult struct {
+	Ref      string `json:"ref"`
+	Path     string `json:"path"`
+	MaxCount int    `json:"max_count"`
+}
+
+func GitLog(ctx context.Context, input *AgentInput) *AgentOutput {
+	stub := &GitLogToolResult{}
+	json.Unmarshal([]byte(input.ToolCall.Function.Arguments), stub)
+	if stub.MaxCount <= 0 {
+		stub.MaxCount = GIT_LOG_DEFAULT
+	}
+	if stub.MaxCount > GIT_LOG_MAX {
+		stub.MaxCount = GIT_LOG_MAX
+	}
+	args := []string{"log", fmt.Sprintf("--max-count=%d", stub.MaxCount), "--date=short", "--pretty=format:%h %ad %an: %s"}
+	if stub.Ref != "" {
+		if err := checkGitRef(stub.Ref); err != nil {
+			return &AgentOutput{Error: err}
+		}
+		args = append(args, stub.Ref)
+	}
+	if stub.Path != "" {
+		args = append(args, "--follow", "--", stub.Path)
+	}
+	git, err := newGit()
+	if err != nil {
+		return &AgentOutput{Error: err}
+	}
+	out, err := git.Run(args...)
+	if err != nil {
+		return &AgentOutput{Error: err}
+	}
+	if strings.TrimSpace(out) == "" {
+		out = "no commits found"
+	}
+	return &AgentOutput{
+		Content:  out,
+		ToolCall: input.ToolCall,
+	}
+}
+
+type GitBlameToolResult struct {
+	Path      string `json:"path"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+}
+
+func GitBlame(ctx context.Context, input *AgentInput) *AgentOutput {
+	stub := &GitBlameToolResult{}
+	json.Unmarshal([]byte(input.ToolCall.Function.Arguments), stub)
+	if stub.EndLine > 0 && stub.StartLine > stub.EndLine {
+		return &AgentOutput{Error: fmt.Errorf("start_line %d is after end_line %d", stub.StartLine, stub.EndLine)}
+	}
+	git, err := newGit()
+	if err != nil {
+		return &AgentOutput{Error: err}
+	}
+	lines, err := git.Blame(stub.Path, stub.StartLine, stub.EndLine)
+	if err != nil {
+		return &AgentOutput{Error: err}
+	}
+	result := make([]string, 0, len(lines))
+	for _, line := range lines {
+		result = append(result, fmt.Sprintf("%d | %s %s %s (%s) | %s", line.Line, line.Hash[:8], line.Time.Format("2006-01-02"), line.Author, line.Summary, line.Content))
+	}
+	return &AgentOutput{
+		Content:  truncateGitOutput(strings.Join(result, "\n"), "use start_line and end_line to narrow the range"),
+		ToolCall: input.ToolCall,
+	}
+}
+
+type GitCommitToolResult struct {
+	Message string   `json:"message"`
+	Paths   []string `json:"paths"`
+}
+
+// GitCommit 提交前必须由用户确认，用户可以修改提交信息，无法和用户交互时拒绝提交
+func GitCommit(ctx context.Context, input *AgentInput) *AgentOutput {
+	stub := &GitCommitToolResult{}
+	json.Unmarshal([]byte(input.ToolCall.Function.Arguments), stub)
+	message := strings.TrimSpace(stub.Message)
+	if message == "" {
+		return &AgentOutput{Error: fmt.Errorf("commit message is empty")}
+	}
+	if input.isTask || input.Input == nil {
+		return &AgentOutput{Error: fmt.Errorf("git_commit requires confirmation from the user, ask the user to commit instead")}
+	}
+	git, err := newGit()
+	if err != nil {
+		return &AgentOutput{Error: err}
+	}
+	// 记录暂存前的暂存区，用户取消时恢复，保留用户原来只暂存了部分改动的文件
+	tree, err := git.Run("write-tree")
+	if err != nil {
+		return &AgentOutput{Error: err}
+	}
+	tree = strings.TrimSpace(tree)
+	if len(stub.Paths) > 0 {
+		if _, err := git.Run(append([]string{"add", "-A", "--"}, stub.Paths...)...); err != nil {
+			return &AgentOutput{Error: err}
+		}
+	}
+	stat, err := git.Run("diff", "--cached", "--stat")
+	if err != nil {
+		return &AgentOutput{Error: err}
+	}
+	if strings.TrimSpace(stat) == "" {
+		return &AgentOutput{Error: fmt.Errorf("nothing staged to commit, pass paths to stage the files")}
+	}
+	unstage := func() {
+		if len(stub.Paths) > 0 {
+			git.Run(append([]string{"restore", "--staged", "--source=" + tree, "--"}, stub.Paths...)...)
+		}
+	}
+	res := input.Input.Select(locales.Sprintf("Commit these changes?\n%s\nmessage:\n%s", strings.TrimRight(stat, "\n"), message), []string{locales.Sprintf("Yes"), locales.Sprintf("Edit message"), locales.Sprintf("Skip")})
+	switch res {
+	case locales.Sprintf("Skip"):
+		unstage()
+		return &AgentOutput{Error: fmt.Errorf("user choose not to commit")}
+	case locales.Sprintf("Edit message"):
+		if input.Output != nil {
+			input.Output.OnSystemMsg(utils.InfoMessageStyle(locales.Sprintf("Enter the commit message:")), berio.MsgTypeDump)
+		}
+		text, err := input.Input.Read()
+		if err != nil || strings.TrimSpace(text) == "" {
+			unstage()
+			return &AgentOutput{Error: fmt.Errorf("user did not give a commit message")}
+		}
+		message = strings.TrimSpace(text)
+	}
+	head, err := git.Commit(message)
+	if err != nil {
+		// 提交被钩子等拒绝时同样恢复暂存区
+		unstage()
+		return &AgentOutput{Error: err}
+	}
+	return &AgentOutput{
+		Content:  fmt.Sprintf("committed: %s", head),
+		ToolCall: input.ToolCall,
+	}
+}
+
+func GitStatusSchema() *llm.ToolSchema {
+	return &llm.ToolSchema{
+		Type: "function",
+		Function: llm.ToolFunctionDefinition{
+			Name:        TOOL_GIT_STATUS,
+			Description: "git_status用来查看用户git仓库的状态，返回当前分支、与上游的差距，以及按冲突、已暂存、未暂存、未跟踪分组的文件，文件路径相对工作区，可以直接传给其他git工具。不要用shell_cmd运行git status",
+			Parameters: llm.ToolParameters{
+				Type:       "object",
+				Properties: map[string]llm.ToolProperty{},
+			},
+		},
+	}
+}
+
+func GitDiffSchema() *llm.ToolSchema {
+	return &llm.ToolSchema{
+		Type: "function",
+		Function: llm.ToolFunctionDefinition{
+			Name:        TOOL_GIT_DIFF,
+			Description: "git_diff用来查看用户git仓库中的改动。默认查看未暂存的改动，staged为true时查看已暂存的改动，指定from和to时比较两个ref。改动较多时先用stat查看概况，再用paths查看具体文件",
+			Parameters: llm.ToolParameters{
+				Type: "object",
+				Properties: map[string]llm.ToolProperty{
+					"staged": {Type: "boolean", Description: "是否查看已暂存的改动"},
+					"from":   {Type: "string", Description: "比较的起点，可以是commit、分支或tag，只指定from时与工作区比较"},
+					"to":     {Type: "string", Description: "比较的终点，需要同时指定from"},
+					"paths":  {Type: "array", Description: "只查看这些文件或目录的改动", Items: &llm.ToolProperty{Type: "string"}},
+					"stat":   {Type: "boolean", Description: "只返回每个文件改动的行数统计"},
+				},
+			},
+		},
+	}
+}
+
+func GitLogSchema() *llm.ToolSchema {
+	maxCount := float64(GIT_LOG_MAX)
+	minCount := float64(1)
+	return &llm.ToolSchema{
+		Type: "function",
+		Function: llm.ToolFunctionDefinition{
+			Name:        TOOL_GIT_LOG,
+			Description: "git_log用来查看用户git仓库的提交历史，每行一个提交，格式为 hash 日期 作者: 标题",
+			Parameters: llm.ToolParameters{
+				Type: "object",
+				Properties: map[string]llm.ToolProperty{
+					"ref":       {Type: "string", Description: "从哪个commit、分支或tag开始查看，默认为HEAD"},
+					"path":      {Type: "string", Description: "只查看修改过该文件的提交，会跟踪重命名"},
+					"max_count": {Type: "integer", Description: "最多返回的提交数量", Default: GIT_LOG_DEFAULT, Minimum: &minCount, Maximum: &maxCount},
+				},
+			},
+		},
+	}
+}
+
+func GitBlameSchema() *llm.ToolSchema {
+	minLine := float64(1)
+	return &llm.ToolSchema{
+		Type: "function",
+		Function: llm.ToolFunctionDefinition{
+			Name:        TOOL_GIT_BLAME,
+			Description: "git_blame用来查看文件中每一行最后是由哪个提交修改的，每行格式为 行号 | hash 日期 作者 (提交标题) | 内容。请指定行范围，避免输出整个文件",
+			Parameters: llm.ToolParameters{
+				Type: "object",
+				Properties: map[string]llm.ToolProperty{
+					"path":       {Type: "string", Description: "文件路径"},
+					"start_line": {Type: "integer", Description: "起始行号，从1开始", Minimum: &minLine},
+					"end_line":   {Type: "integer", Description: "结束行号，包含该行，省略时到文件末尾", Minimum: &minLine},
+				},
+				Required: []string{"path"},
+			},
+		},
+	}
+}
+
+func GitCommitSchema() *llm.ToolSchema {
+	return &llm.ToolSchema{
+		Type: "function",
+		Function: llm.ToolFunctionDefinition{
+			Name:        TOOL_GIT_COMMIT,
+			Description: "git_commit用来在用户的git仓库中提交改动，提交前用户会确认并可以修改提交信息。只有用户要求提交时才使用。提交信息第一行是简短的标题，需要时空一行后写详细说明",
+			Parameters: llm.ToolParameters{
+				Type: "object",
+				Properties: map[string]llm.ToolProperty{
+					"message": {Type: "string", Description: "提交信息"},
+					"paths":   {Type: "array", Description: "提交前要暂存的文件或目录，省略时只提交已经暂存的改动", Items: &llm.ToolProperty{Type: "string"}},
+				},
+				Required: []string{"message"},
+			},
+		},
+	}
+}
+
+var GitStatusToolDesc = &ToolDesc{
+	Name:   TOOL_GIT_STATUS,
+	Intent: locales.Sprintf("Bergo is checking git status"),
+	Schema: GitStatusSchema(),
+}
+
+var GitDiffToolDesc = &ToolDesc{
+	Name:   TOOL_GIT_DIFF,
+	Intent: locales.Sprintf("Bergo is checking git diff"),
+	Schema: GitDiffSchema(),
+}
+
+var GitLogToolDesc = &ToolDesc{
+	Name:   TOOL_GIT_LOG,
+	Intent: locales.Sprintf("Bergo is checking git log"),
+	Schema: GitLogSchema(),
+}
+
+var GitBlameToolDesc = &ToolDesc{
+	Name:   TOOL_GIT_BLAME,
+	Intent: locales.Sprintf("Bergo is checking git blame"),
+	Schema: GitBlameSchema(),
+}
+
+var GitCommitToolDesc = &ToolDesc{
+	Name:   TOOL_GIT_COMMIT,
+	Intent: locales.Sprintf("Bergo is committing changes"),
+	Schema: GitCommitSchema(),
+	OutputFunc: func(call *llm.ToolCall, content string) string {
+		return utils.InfoMessageStyle(content)
+	},
+}
@@ -0,0 +1,71 @@
+package tools
+
+import (
+	"bergo/berio"
+	"bergo/config"
+	"bergo/llm"
+	"bergo/locales"
+	"bergo/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// RunHooks 执行配置的hook，执行失败时提示用户但不影响流程
+func RunHooks(ctx context.Context, output berio.BerOutput, input *utils.HookInput) *utils.HookResult {
+	if config.GlobalConfig == nil || len(config.GlobalConfig.Hooks) == 0 {
+		return &utils.HookResult{}
+	}
+	result := utils.RunHooks(ctx, config.GlobalConfig.Hooks, input)
+	for _, err := range result.Errors {
+		output.OnSystemMsg(locales.Sprintf("%s hook error: %v", input.Event, err), berio.MsgTypeWarning)
+	}
+	return result
+}
+
+// CallToolWithHooks 调用工具前后分别执行PreToolUse和PostToolUse hook，主agent和子agent共用，
+// 被hook阻止时不调用工具，返回阻止的原因给模型
+func CallToolWithHooks(ctx context.Context, output berio.BerOutput, sessionId string, mode string, call *llm.ToolCall,
+	callTool func(ctx context.Context, call *llm.ToolCall) (*AgentOutput, error)) (*AgentOutput, error) {
+	result := RunHooks(ctx, output, &utils.HookInput{
+		Event:     utils.HOOK_PRE_TOOL_USE,
+		SessionId: sessionId,
+		Mode:      mode,
+		ToolName:  call.Function.Name,
+		ToolInput: hookToolInput(call),
+	})
+	if result.Block {
+		output.OnSystemMsg(locales.Sprintf("tool [%s] blocked by hook: %v", call.Function.Name, result.Reason), berio.MsgTypeWarning)
+		return &AgentOutput{
+			Content:  fmt.Sprintf("tool call blocked by hook: %s", result.Reason),
+			ToolCall: call,
+		}, nil
+	}
+	if len(result.ToolInput) > 0 {
+		call.Function.Arguments = string(result.ToolInput)
+	}
+	answer, err := callTool(ctx, call)
+	if err != nil || answer == nil || answer.Error != nil || answer.InterruptErr != nil {
+		return answer, err
+	}
+	result = RunHooks(ctx, output, &utils.HookInput{
+		Event:      utils.HOOK_POST_TOOL_USE,
+		SessionId:  sessionId,
+		Mode:       mode,
+		ToolName:   call.Function.Name,
+		ToolInput:  hookToolInput(call),
+		ToolOutput: answer.Content,
+	})
+	if len(result.Feedback) > 0 {
+		answer.Content += "\n<hook_feedback>\n" + result.JoinedFeedback() + "\n</hook_feedback>"
+	}
+	return answer, nil
+}
+
+// hookToolInput 工具参数不是合法的JSON时不传给hook
+func hookToolInput(call *llm.ToolCall) json.RawMessage {
+	if !json.Valid([]byte(call.Function.Arguments)) {
+		return nil
+	}
+	return json.RawMessage(call.Function.Arguments)
+}
@@ -0,0 +1,405 @@
+package tools
+
+import (
+	"bergo/llm"
+	"bergo/locales"
+	"bergo/mcp"
+	"bergo/utils"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/kaptinlin/jsonschema"
+)
+
+const (
+	MCP_TOOL_PREFIX = "mcp__"
+	// 工具名的最大长度，大部分模型提供商限制为64
+	MCP_TOOL_NAME_MAX = 64
+	// 在工具描述中列出的resources和prompts的最大数量
+	MCP_LIST_MAX = 50
+)
+
+var mcpNameRegex = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// 每个服务器注册的工具名
+var mcpTools = map[string][]string{}
+
+// 配置了trust的服务器注册的工具，调用时不需要确认
+var mcpTrustedTools = map[string]bool{}
+
+// McpToolName 生成提供给模型的工具名，格式为 mcp__<server>__<tool>
+func McpToolName(server string, tool string) string {
+	name := MCP_TOOL_PREFIX + mcpNameRegex.ReplaceAllString(server, "_") + "__" + mcpNameRegex.ReplaceAllString(tool, "_")
+	if len(name) > MCP_TOOL_NAME_MAX {
+		name = name[:MCP_TOOL_NAME_MAX]
+	}
+	return name
+}
+
+// RegisterMcpTools 把服务器提供的工具注册到ToolsMap和ToolFuncMap，返回注册成功的工具名
+// 服务器开启了resources或prompts时，额外注册读取resource和获取prompt的工具
+func RegisterMcpTools(client *mcp.Client) ([]string, []error) {
+	UnregisterMcpTools(client.Name)
+	var names []string
+	var errs []error
+	register := func(desc *ToolDesc, rawSchema json.RawMessage, handler func(ctx context.Context, input *AgentInput) *AgentOutput) {
+		if _, ok := ToolsMap[desc.Name]; ok {
+			errs = append(errs, fmt.Errorf("mcp tool %s conflicts with an existing tool", desc.Name))
+			return
+		}
+		validator, err := compileMcpSchema(rawSchema, &desc.Schema.Function.Parameters)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("mcp tool %s: %w", desc.Name, err))
+			return
+		}
+		desc.Validator = validator
+		ToolsMap[desc.Name] = desc
+		ToolFuncMap[desc.Name] = handler
+		if client.Conf.Trust {
+			mcpTrustedTools[desc.Name] = true
+		}
+		names = append(names, desc.Name)
+	}
+
+	for _, tool := range client.Tools {
+		name := McpToolName(client.Name, tool.Name)
+		desc := &ToolDesc{
+			Name:       name,
+			Intent:     locales.Sprintf("Bergo is calling %s on MCP server %s", tool.Name, client.Name),
+			Schema:     mcpToolSchema(name, fmt.Sprintf("[MCP server %s] %s", client.Name, tool.Description), convertMcpSchema(tool.InputSchema)),
+			OutputFunc: mcpOutputFunc,
+		}
+		register(desc, tool.InputSchema, mcpCallTool(client, tool.Name))
+	}
+	if len(client.Resources) > 0 {
+		name := McpToolName(client.Name, "read_resource")
+		params := llm.ToolParameters{
+			Type: "object",
+			Properties: map[string]llm.ToolProperty{
+				"uri": {Type: "string", Description: "要读取的resource的uri"},
+			},
+			Required: []string{"uri"},
+		}
+		desc := &ToolDesc{
+			Name:       name,
+			Intent:     locales.Sprintf("Bergo is reading a resource from MCP server %s", client.Name),
+			Schema:     mcpToolSchema(name, mcpResourcesDescription(client), params),
+			OutputFunc: mcpOutputFunc,
+		}
+		register(desc, nil, mcpReadResource(client))
+	}
+	if len(client.Prompts) > 0 {
+		name := McpToolName(client.Name, "get_prompt")
+		params := llm.ToolParameters{
+			Type: "object",
+			Properties: map[string]llm.ToolProperty{
+				"name":      {Type: "string", Description: "prompt的名称"},
+				"arguments": {Type: "object", Description: "prompt的参数，key为参数名，value为字符串"},
+			},
+			Required: []string{"name"},
+		}
+		desc := &ToolDesc{
+			Name:       name,
+			Intent:     locales.Sprintf("Bergo is getting a prompt from MCP server %s", client.Name),
+			Schema:     mcpToolSchema(name, mcpPromptsDescription(client), params),
+			OutputFunc: mcpOutputFunc,
+		}
+		register(desc, nil, mcpGetPrompt(client))
+	}
+	mcpTools[client.Name] = names
+	return names, errs
+}
+
+// UnregisterMcpTools 移除服务器注册的工具，返回被移除的工具名
+func UnregisterMcpTools(server string) []string {
+	names := mcpTools[server]
+	for _, name := range names {
+		delete(ToolsMap, name)
+		delete(ToolFuncMap, name)
+		delete(mcpTrustedTools, name)
+	}
+	delete(mcpTools, server)
+	return names
+}
+
+// McpToolNames 所有已注册的MCP工具名，按名称排序
+func McpToolNames() []string {
+	var names []string
+	for _, serverTools := range mcpTools {
+		names = append(names, serverTools...)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func mcpToolSchema(name string, description string, params llm.ToolParameters) *llm.ToolSchema {
+	return &llm.ToolSchema{
+		Type: "function",
+		Function: llm.ToolFunctionDefinition{
+			Name:        name,
+			Description: description,
+			Parameters:  params,
+		},
+	}
+}
+
+// compileMcpSchema 优先使用服务器提供的原始schema，同时作为Raw发送给模型，无法编译时退回到转换后的schema
+func compileMcpSchema(raw json.RawMessage, params *llm.ToolParameters) (*jsonschema.Schema, error) {
+	if len(raw) > 0 {
+		if validator, err := jsonschema.NewCompiler().Compile(raw); err == nil {
+			params.Raw = raw
+			return validator, nil
+		}
+	}
+	data, err := params.JSONSchema()
+	if err != nil {
+		return nil, err
+	}
+	return jsonschema.NewCompiler().Compile(data)
+}
+
+// convertMcpSchema 把服务器提供的JSON Schema转换为ToolParameters，不支持的关键字会被忽略
+func convertMcpSchema(raw json.RawMessage) llm.ToolParameters {
+	params := llm.ToolParameters{Type: "object", Properties: map[string]llm.ToolProperty{}}
+	schema := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return params
+	}
+	if props, ok := schema["properties"].(map[string]interface{}); ok {
+		for name, prop := range props {
+			if propMap, ok := prop.(map[string]interface{}); ok {
+				params.Properties[name] = convertMcpProperty(propMap)
+			}
+		}
+	}
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, r := range required {
+			if name, ok := r.(string); ok {
+				params.Required = append(params.Required, name)
+			}
+		}
+	}
+	return params
+}
+
+func convertMcpProperty(schema map[string]interface{}) llm.ToolProperty {
+	prop := llm.ToolProperty{}
+	switch t := schema["type"].(type) {
+	case string:
+		prop.Type = t
+	case []interface{}:
+		// ["string", "null"] 这类可空类型取第一个非null的类型
+		for _, item := range t {
+			if s, ok := item.(string); ok && s != "null" {
+				prop.Type = s
+				break
+			}
+		}
+	}
+	if prop.Type == "" {
+		prop.Type = "string"
+	}
+	prop.Description, _ = schema["description"].(string)
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		item := convertMcpProperty(items)
+		prop.Items = &item
+	}
+	if props, ok := schema["properties"].(map[string]interface{}); ok {
+		prop.Properties = map[string]llm.ToolProperty{}
+		for name, p := range props {
+			if pMap, ok := p.(map[string]interface{}); ok {
+				prop.Properties[name] = convertMcpProperty(pMap)
+			}
+		}
+	}
+	return prop
+}
+
+func mcpResourcesDescription(client *mcp.Client) string {
+	buf := strings.Builder{}
+	buf.WriteString(fmt.Sprintf("[MCP server %s] 读取MCP服务器提供的resource，可用的resource如下：\n", client.Name))
+	for i, resource := range client.Resources {
+		if i >= MCP_LIST_MAX {
+			buf.WriteString(fmt.Sprintf("...还有%d个resource\n", len(client.Resources)-MCP_LIST_MAX))
+			break
+		}
+		buf.WriteString(fmt.Sprintf("- %s (%s) %s\n", resource.Uri, resource.Name, resource.Description))
+	}
+	return buf.String()
+}
+
+func mcpPromptsDescription(client *mcp.Client) string {
+	buf := strings.Builder{}
+	buf.WriteString(fmt.Sprintf("[MCP server %s] 获取MCP服务器提供的prompt模板，可用的prompt如下：\n", client.Name))
+	for i, prompt := range client.Prompts {
+		if i >= MCP_LIST_MAX {
+			buf.WriteString(fmt.Sprintf("...还有%d个prompt\n", len(client.Prompts)-MCP_LIST_MAX))
+			break
+		}
+		var args []string
+		for _, arg := range prompt.Arguments {
+			if arg.Required {
+				args = append(args, arg.Name+"(必填)")
+			} else {
+				args = append(args, arg.Name)
+			}
+		}
+		buf.WriteString(fmt.Sprintf("- %s: %s 参数: [%s]\n", prompt.Name, prompt.Description, strings.Join(args, ", ")))
+	}
+	return buf.String()
+}
+
+// mcpAllowed 调用会产生副作用的MCP工具前询问用户，服务器配置了trust或者用户选择了总是允许时跳过
+func mcpAllowed(input *AgentInput, client *mcp.Client) bool {
+	name := input.ToolCall.Function.Name
+	if client.Conf.Trust || input.isTask || input.Input == nil || input.AllowMap[name] {
+		return true
+	}
+	res := input.Input.Select(locales.Sprintf("Allow MCP server %s to run %s?\n%s", client.Name, name, input.ToolCall.Function.Arguments), []string{locales.Sprintf("Yes"), locales.Sprintf("Always Yes"), locales.Sprintf("Skip")})
+	if res == locales.Sprintf("Always Yes") && input.AllowMap != nil {
+		input.AllowMap[name] = true
+	}
+	return res == locales.Sprintf("Yes") || res == locales.Sprintf("Always Yes")
+}
+
+func mcpCallTool(client *mcp.Client, toolName string) func(ctx context.Context, input *AgentInput) *AgentOutput {
+	return func(ctx context.Context, input *AgentInput) *AgentOutput {
+		if !mcpAllowed(input, client) {
+			return &AgentOutput{Error: fmt.Errorf("user refused to run %s", input.ToolCall.Function.Name)}
+		}
+		result, err := client.CallTool(ctx, toolName, json.RawMessage(input.ToolCall.Function.Arguments))
+		if err != nil {
+			return &AgentOutput{Error: fmt.Errorf("mcp server %s: %w", client.Name, err)}
+		}
+		content := renderMcpContents(result.Content)
+		if result.IsError {
+			return &AgentOutput{Error: fmt.Errorf("%s", content)}
+		}
+		return &AgentOutput{
+			Content:  content,
+			ToolCall: input.ToolCall,
+		}
+	}
+}
+
+func mcpReadResource(client *mcp.Client) func(ctx context.Context, input *AgentInput) *AgentOutput {
+	return func(ctx context.Context, input *AgentInput) *AgentOutput {
+		stub := struct {
+			Uri string `json:"uri"`
+		}{}
+		json.Unmarshal([]byte(input.ToolCall.Function.Arguments), &stub)
+		contents, err := client.ReadResource(ctx, stub.Uri)
+		if err != nil {
+			return &AgentOutput{Error: fmt.Errorf("mcp server %s: %w", client.Name, err)}
+		}
+		var parts []string
+		for _, content := range contents {
+			parts = append(parts, renderMcpResource(content))
+		}
+		return &AgentOutput{
+			Content:  strings.Join(parts, "\n"),
+			ToolCall: input.ToolCall,
+		}
+	}
+}
+
+func mcpGetPrompt(client *mcp.Client) func(ctx context.Context, input *AgentInput) *AgentOutput {
+	return func(ctx context.Context, input *AgentInput) *AgentOutput {
+		stub := struct {
+			Name      string            `json:"name"`
+			Arguments map[string]string `json:"arguments"`
+		}{}
+		if err := json.Unmarshal([]byte(input.ToolCall.Function.Arguments), &stub); err != nil {
+			return &AgentOutput{Error: fmt.Errorf("arguments must be an object of strings: %w", err)}
+		}
+		result, err := client.GetPrompt(ctx, stub.Name, stub.Arguments)
+		if err != nil {
+			return &AgentOutput{Error: fmt.Errorf("mcp server %s: %w", client.Name, err)}
+		}
+		buf := strings.Builder{}
+		if result.Description != "" {
+			buf.WriteString(result.Description + "\n")
+		}
+		for _, msg := range result.Messages {
+			if msg.Content == nil {
+				continue
+			}
+			buf.WriteString(fmt.Sprintf("[%s]\n%s\n", msg.Role, renderMcpContents([]*mcp.Content{msg.Content})))
+		}
+		return &AgentOutput{
+			Content:  buf.String(),
+			ToolCall: input.ToolCall,
+		}
+	}
+}
+
+// renderMcpContents 把工具结果转换为文本，图片保存到磁盘，可以用read_img查看
+func renderMcpContents(contents []*mcp.Content) string {
+	var parts []string
+	for _, content := range contents {
+		switch content.Type {
+		case "text":
+			parts = append(parts, content.Text)
+		case "image":
+			path, err := saveMcpImage(content.Data, content.MimeType)
+			if err != nil {
+				parts = append(parts, fmt.Sprintf("[image: save failed: %v]", err))
+			} else {
+				parts = append(parts, fmt.Sprintf("[image saved to %s, use read_img to view it]", path))
+			}
+		case "resource":
+			if content.Resource != nil {
+				parts = append(parts, renderMcpResource(content.Resource))
+			}
+		default:
+			parts = append(parts, fmt.Sprintf("[%s content omitted]", content.Type))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func renderMcpResource(resource *mcp.ResourceContent) string {
+	if resource.Text != "" || resource.Blob == "" {
+		return fmt.Sprintf("--- %s ---\n%s", resource.Uri, resource.Text)
+	}
+	return fmt.Sprintf("--- %s ---\n[binary content, %s]", resource.Uri, resource.MimeType)
+}
+
+func saveMcpImage(data string, mimeType string) (string, error) {
+	content, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return "", err
+	}
+	ext := ".png"
+	switch mimeType {
+	case "image/jpeg":
+		ext = ".jpg"
+	case "image/gif":
+		ext = ".gif"
+	case "image/webp":
+		ext = ".webp"
+	}
+	hash := sha256.Sum256(content)
+	path := filepath.Join(utils.GetWorkspaceStorePath(), "mcp_images", hex.EncodeToString(hash[:])[:16]+ext)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, content, 0644)
+}
+
+func mcpOutputFunc(call *llm.ToolCall, content string) string {
+	lines := strings.Split(strings.TrimSpace(content), "\n")
+	if len(lines) > 10 {
+		lines = append(lines[:10], fmt.Sprintf("...(%d more lines)", len(lines)-10))
+	}
+	return utils.InfoMessageStyle(locales.Sprintf("%s returned:\n%s", call.Function.Name, strings.Join(lines, "\n")))
+}
@@ -0,0 +1,172 @@
+package tools
+
+import (
+	"bergo/berio"
+	"bergo/config"
+	"bergo/llm"
+	"bergo/locales"
+	"bergo/prompt"
+	"bergo/utils"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// 提供给子agent的目录文件列表的最大长度
+	OVERVIEW_MAX_FILE_LIST = 200
+)
+
+var OverviewToolScope = []string{TOOL_READ_FILE, TOOL_READ_FILES, TOOL_SEARCH_CODE, TOOL_STOP_LOOP}
+
+// OverviewResult /init刷新概览的结果，Failed中的目录保留旧的章节，下次/init时重试
+type OverviewResult struct {
+	Updated []string
+	Removed []string
+	Failed  []string
+	Usage   llm.TokenUsage
+}
+
+// GenerateOverview 为新增或内容有变化的目录重新生成概览章节，再更新项目整体的章节，保存到prompt.OVERVIEW_FILE。
+// 目录的子agent并发运行，使用berag的并发数和预算，预算用完或者用户中断时保存已经生成的章节
+func GenerateOverview(ctx context.Context, output berio.BerOutput, force bool) (*OverviewResult, error) {
+	index := GetCodeIndex()
+	if _, err := index.Update(); err != nil {
+		return nil, fmt.Errorf("update code index failed: %v", err)
+	}
+	dirs := index.Dirs(utils.OVERVIEW_DIR_DEPTH)
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("no source file found in the workspace")
+	}
+	overview := utils.LoadOverview(prompt.OVERVIEW_FILE)
+	plan := overview.Plan(dirs, force)
+	result := &OverviewResult{}
+	if plan.Empty() {
+		return result, nil
+	}
+	shared := &SharedExtract{
+		TokenBudget: config.GlobalConfig.BeragTokenBudget,
+		CostBudget:  config.GlobalConfig.BeragCostBudget,
+	}
+
+	var finished atomic.Int64
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(1 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				usage := shared.GetUsage()
+				output.UpdateTail(utils.InfoMessageStyle(locales.Sprintf("generating overview: %d/%d directories, total usage %v", finished.Load(), len(plan.Dirs), usage.String())))
+			}
+		}
+	}()
+
+	mu := sync.Mutex{}
+	sem := make(chan struct{}, max(config.GlobalConfig.BeragConcurrency, 1))
+	wg := sync.WaitGroup{}
+	for _, dir := range plan.Dirs {
+		wg.Add(1)
+		go func(dir *utils.IndexedDir) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			defer finished.Add(1)
+			content, err := runOverviewTask(ctx, output, shared, config.GlobalConfig.BeragModel, prompt.GetOverviewDirPrompt(dir.Path, overviewFileList(dir.Files)))
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				result.Failed = append(result.Failed, fmt.Sprintf("%s: %v", dir.Path, err))
+				return
+			}
+			overview.Set(dir.Path, dir.Hash, content)
+			result.Updated = append(result.Updated, dir.Path)
+		}(dir)
+	}
+	wg.Wait()
+	// 用户中断时只保存已经生成的目录章节，删除目录和项目章节留给下次/init
+	if ctx.Err() == nil {
+		for _, key := range plan.Removed {
+			overview.Remove(key)
+		}
+		result.Removed = plan.Removed
+	}
+
+	// 有目录没有生成成功时保留旧的项目章节，下次/init时一起重试
+	if ctx.Err() == nil && len(result.Failed) == 0 && (plan.Project || len(result.Updated) > 0) {
+		var rootFiles, sections []string
+		for _, dir := range dirs {
+			if dir.Path == "." {
+				rootFiles = dir.Files
+			}
+		}
+		for _, section := range overview.Sections {
+			if section.Key != utils.OVERVIEW_PROJECT_SECTION {
+				sections = append(sections, section.Content)
+			}
+		}
+		content, err := runOverviewTask(ctx, output, shared, config.GlobalConfig.MainModel, prompt.GetOverviewProjectPrompt(overviewFileList(rootFiles), sections))
+		if err != nil {
+			result.Failed = append(result.Failed, fmt.Sprintf("%s: %v", utils.OVERVIEW_PROJECT_SECTION, err))
+		} else {
+			overview.Set(utils.OVERVIEW_PROJECT_SECTION, plan.ProjectHash, content)
+			result.Updated = append(result.Updated, utils.OVERVIEW_PROJECT_SECTION)
+		}
+	}
+	close(done)
+	sort.Strings(result.Updated)
+	sort.Strings(result.Failed)
+	result.Usage = shared.GetUsage()
+	if len(result.Updated) > 0 || len(result.Removed) > 0 {
+		if err := overview.Save(prompt.OVERVIEW_FILE); err != nil {
+			return result, fmt.Errorf("save overview failed: %v", err)
+		}
+		prompt.ReloadOverview()
+	}
+	if ctx.Err() != nil {
+		return result, fmt.Errorf("user interrupted")
+	}
+	return result, nil
+}
+
+func runOverviewTask(ctx context.Context, output berio.BerOutput, shared *SharedExtract, model string, userInput string) (string, error) {
+	q := utils.Query{}
+	q.SetMode(prompt.MODE_OVERVIEW)
+	q.SetUserInput(userInput)
+	task := &Task{
+		ToolScope:       OverviewToolScope,
+		ID:              NewTaskID(),
+		Context:         []*llm.ChatItem{{Role: "user", Message: q.Build()}},
+		Mode:            prompt.MODE_OVERVIEW,
+		ParallelToolUse: true,
+		shared:          shared,
+		Model:           model,
+		output:          output,
+	}
+	answer := task.Run(ctx, &AgentInput{Output: output})
+	if answer.InterruptErr != nil {
+		return "", answer.InterruptErr
+	}
+	if answer.Error != nil {
+		return "", answer.Error
+	}
+	if strings.TrimSpace(answer.Content) == "" {
+		return "", fmt.Errorf("the overview is empty")
+	}
+	return answer.Content, nil
+}
+
+// overviewFileList 文件太多时只列出前面的部分
+func overviewFileList(files []string) []string {
+	if len(files) <= OVERVIEW_MAX_FILE_LIST {
+		return files
+	}
+	return append(files[:OVERVIEW_MAX_FILE_LIST:OVERVIEW_MAX_FILE_LIST], fmt.Sprintf("...%d more files", len(files)-OVERVIEW_MAX_FILE_LIST))
+}
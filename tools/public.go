@@ -6,6 +6,7 @@ import (
 	"bergo/utils"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 
@@ -26,11 +27,17 @@ type AgentInput struct {
 	TasKShared *SharedExtract
 }
 
+// SetHeadless 标记为无人值守调用，工具不会弹出确认，也不会读取终端输入
+func (input *AgentInput) SetHeadless() {
+	input.isTask = true
+}
+
 type AgentOutput struct {
 	ToolCall     *llm.ToolCall
 	Content      string
 	ImgPath      string // 图片文件路径，存储到 timeline 时只保存路径
 	Rendered     string
+	ModifiedPath string // 工具成功修改（编辑或删除）的文件路径
 	Stats        utils.Stat
 	Error        error //出错，应该返回给llm
 	InterruptErr error //出现错误终止整个tool use流程
@@ -56,10 +63,22 @@ var ToolsMap = map[string]*ToolDesc{
 	TOOL_SHELL_CMD:      ShellCmdToolDesc,
 	TOOL_STOP_LOOP:      StopLoopToolDesc,
 	TOOL_READ_FILE:      ReadFileToolDesc,
+	TOOL_READ_FILES:     ReadFilesToolDesc,
+	TOOL_TODO_WRITE:     TodoWriteToolDesc,
+	TOOL_ASK_USER:       AskUserToolDesc,
+	TOOL_DELEGATE:       DelegateToolDesc,
 	TOOL_READ_IMG:       ReadImgToolDesc,
 	TOOL_BERAG:          BeragToolDesc,
 	TOOL_BERAG_EXTRACT:  BeragExtractToolDesc,
 	TOOL_EXTRACT_RESULT: ExtractResultToolDesc,
+	TOOL_GIT_STATUS:     GitStatusToolDesc,
+	TOOL_GIT_DIFF:       GitDiffToolDesc,
+	TOOL_GIT_LOG:        GitLogToolDesc,
+	TOOL_GIT_BLAME:      GitBlameToolDesc,
+	TOOL_GIT_COMMIT:     GitCommitToolDesc,
+	TOOL_REPORT_FINDING: ReportFindingToolDesc,
+	TOOL_RUN_TESTS:      RunTestsToolDesc,
+	TOOL_SEARCH_CODE:    SearchCodeToolDesc,
 }
 
 var ToolFuncMap = map[string]func(ctx context.Context, input *AgentInput) *AgentOutput{}
@@ -68,7 +87,7 @@ func init() {
 	for _, tool := range ToolsMap {
 		if tool.Schema != nil {
 			comp := jsonschema.NewCompiler()
-			jsonSchema, err := json.Marshal(tool.Schema.Function.Parameters)
+			jsonSchema, err := tool.Schema.Function.Parameters.JSONSchema()
 			if err != nil {
 				panic(fmt.Errorf("marshal json schema failed: %w", err))
 			}
@@ -86,9 +105,21 @@ func init() {
 	ToolFuncMap[TOOL_SHELL_CMD] = ShellCommand
 	ToolFuncMap[TOOL_STOP_LOOP] = StopLoop
 	ToolFuncMap[TOOL_READ_FILE] = ReadFile
+	ToolFuncMap[TOOL_READ_FILES] = ReadFiles
+	ToolFuncMap[TOOL_TODO_WRITE] = TodoWrite
+	ToolFuncMap[TOOL_ASK_USER] = AskUser
+	ToolFuncMap[TOOL_DELEGATE] = Delegate
 	ToolFuncMap[TOOL_BERAG] = Berag
 	ToolFuncMap[TOOL_BERAG_EXTRACT] = BeragExtract
 	ToolFuncMap[TOOL_EXTRACT_RESULT] = ExtractResult
+	ToolFuncMap[TOOL_GIT_STATUS] = GitStatus
+	ToolFuncMap[TOOL_GIT_DIFF] = GitDiff
+	ToolFuncMap[TOOL_GIT_LOG] = GitLog
+	ToolFuncMap[TOOL_GIT_BLAME] = GitBlame
+	ToolFuncMap[TOOL_GIT_COMMIT] = GitCommit
+	ToolFuncMap[TOOL_REPORT_FINDING] = ReportFinding
+	ToolFuncMap[TOOL_RUN_TESTS] = RunTests
+	ToolFuncMap[TOOL_SEARCH_CODE] = SearchCode
 }
 
 func JsonSchemaExam(toolCall *llm.ToolCall) error {
@@ -130,8 +161,14 @@ type SharedExtract struct {
 	Related      map[string][]*ExtractItem
 	Total        llm.TokenUsage
 	TaskProgress map[string]*TaskProgress // 每个 task 的进度信息
+	Keywords     []string                 // berag查询的关键词，用于匹配缓存的提取结果
+	Cost         float64                  // 按模型价格计算的累计费用
+	TokenBudget  int                      // token预算，0表示不限制
+	CostBudget   float64                  // 费用预算，0表示不限制
 }
 
+var ErrBudgetExhausted = errors.New("budget exhausted")
+
 // UpdateTaskProgress 更新指定 task 的进度信息（只保留最新一轮）
 func (s *SharedExtract) UpdateTaskProgress(taskID string, response string, toolCalls []*llm.ToolCall, usage llm.TokenUsage) {
 	s.Lock()
@@ -208,6 +245,27 @@ func (s *SharedExtract) UsageUpdate(usage llm.TokenUsage) {
 	s.Total.TotalTokens += usage.TotalTokens
 	s.Total.CachedTokens += usage.CachedTokens
 }
+
+// CostUpdate 按每百万token的价格累计费用
+func (s *SharedExtract) CostUpdate(usage llm.TokenUsage, pricePerMilToken float64) {
+	s.Lock()
+	defer s.Unlock()
+	s.Cost += float64(usage.TotalTokens) * pricePerMilToken / 1000000
+}
+
+// BudgetExceeded token用量或费用达到预算时返回包装了ErrBudgetExhausted的错误
+func (s *SharedExtract) BudgetExceeded() error {
+	s.Lock()
+	defer s.Unlock()
+	if s.TokenBudget > 0 && s.Total.TotalTokens >= s.TokenBudget {
+		return fmt.Errorf("%w: token usage %d reached budget %d", ErrBudgetExhausted, s.Total.TotalTokens, s.TokenBudget)
+	}
+	if s.CostBudget > 0 && s.Cost >= s.CostBudget {
+		return fmt.Errorf("%w: cost %.4f reached budget %.4f", ErrBudgetExhausted, s.Cost, s.CostBudget)
+	}
+	return nil
+}
+
 func (s *SharedExtract) GetUsage() llm.TokenUsage {
 	s.Lock()
 	defer s.Unlock()
@@ -23,6 +23,7 @@ func ReadFile(ctx context.Context, input *AgentInput) *AgentOutput {
 		Path:        stub.Path,
 		LineBudget:  config.GlobalConfig.LineBudget,
 		WithLineNum: true,
+		Entry:       stub.Entry,
 	}
 
 	if stub.Begin == 0 && stub.End == 0 {
@@ -65,6 +66,7 @@ type ReadFileToolResult struct {
 	Path  string `json:"path"`
 	Begin int64  `json:"begin"`
 	End   int64  `json:"end"`
+	Entry string `json:"entry"`
 }
 
 func ReadFileSchema() *llm.ToolSchema {
@@ -72,7 +74,7 @@ func ReadFileSchema() *llm.ToolSchema {
 		Type: "function",
 		Function: llm.ToolFunctionDefinition{
 			Name:        TOOL_READ_FILE,
-			Description: "read_file是用来读取文本文件的一个工具，它会在每一行的开头添加行号。一轮响应应该只包含一次读取操作。如果文件太长，可能会遇到line_budget限制，可以使用begin和end参数来指定要读取的行范围。",
+			Description: "read_file是用来读取文本文件的一个工具，它会在每一行的开头添加行号。一轮响应应该只包含一次读取操作。如果文件太长，可能会遇到line_budget限制，可以使用begin和end参数来指定要读取的行范围。pdf会按页提取文字，ipynb会按cell展示代码和输出（图片输出会保存为文件，可以用read_img查看），zip/tar/tar.gz会列出其中的文件，配合entry参数可以读取压缩包内的单个文本文件。",
 			Parameters: llm.ToolParameters{
 				Type: "object",
 				Properties: map[string]llm.ToolProperty{
@@ -88,6 +90,10 @@ func ReadFileSchema() *llm.ToolSchema {
 						Type:        "integer",
 						Description: "结束行数，如果省略则读取到文件末尾",
 					},
+					"entry": {
+						Type:        "string",
+						Description: "压缩包内要读取的文件路径，仅在path为zip/tar/tar.gz时使用，如果省略则列出压缩包内容",
+					},
 				},
 				Required: []string{"path"},
 			},
@@ -106,6 +112,10 @@ var ReadFileToolDesc = &ToolDesc{
 		if stub.Begin > 0 || stub.End > 0 {
 			scope = fmt.Sprintf("lines %d to %d", stub.Begin, stub.End)
 		}
-		return utils.InfoMessageStyle(locales.Sprintf("read %s, %s", stub.Path, scope))
+		path := stub.Path
+		if stub.Entry != "" {
+			path = fmt.Sprintf("%s:%s", stub.Path, stub.Entry)
+		}
+		return utils.InfoMessageStyle(locales.Sprintf("read %s, %s", path, scope))
 	},
 }
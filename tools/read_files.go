@@ -0,0 +1,169 @@
+package tools
+
+import (
+	"bergo/config"
+	"bergo/llm"
+	"bergo/locales"
+	"bergo/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	TOOL_READ_FILES = "read_files"
+)
+
+type ReadFilesRange struct {
+	Begin int64 `json:"begin"`
+	End   int64 `json:"end"`
+}
+
+type ReadFilesItem struct {
+	Path   string           `json:"path"`
+	Ranges []ReadFilesRange `json:"ranges"`
+}
+
+type ReadFilesToolResult struct {
+	Files []ReadFilesItem `json:"files"`
+}
+
+// ReadFiles 一次读取多个文件的多个行范围，所有文件共享同一个 line_budget
+func ReadFiles(ctx context.Context, input *AgentInput) *AgentOutput {
+	stub := ReadFilesToolResult{}
+	json.Unmarshal([]byte(input.ToolCall.Function.Arguments), &stub)
+	if len(stub.Files) == 0 {
+		return &AgentOutput{
+			Error:    fmt.Errorf("files is empty, please check your tool call"),
+			ToolCall: input.ToolCall,
+		}
+	}
+
+	budget := config.GlobalConfig.LineBudget
+	var content []string
+	for _, item := range stub.Files {
+		content = append(content, fmt.Sprintf("## %s:\n", item.Path))
+		if budget <= 0 {
+			content = append(content, "...skipped, line budget exhausted...\n")
+			continue
+		}
+		lines, used, err := readFileRanges(item, budget)
+		if err != nil {
+			content = append(content, fmt.Sprintf("error: %v\n", err))
+			continue
+		}
+		budget -= used
+		content = append(content, lines...)
+		if budget <= 0 {
+			content = append(content, fmt.Sprintf("...content after these lines are truncated, line budget %d exhausted...\n", config.GlobalConfig.LineBudget))
+		}
+	}
+	return &AgentOutput{
+		Content:  strings.Join(content, ""),
+		ToolCall: input.ToolCall,
+	}
+}
+
+// readFileRanges 读取单个文件的所有范围，读取的行数不超过budget，不同范围之间用...分隔
+// 返回的第二个值是实际读取的行数，分隔符不计入
+func readFileRanges(item ReadFilesItem, budget int) ([]string, int, error) {
+	cf := utils.ReadFile{
+		Path:        item.Path,
+		WithLineNum: true,
+	}
+	ranges := item.Ranges
+	if len(ranges) == 0 {
+		ranges = []ReadFilesRange{{}}
+	}
+	var result []string
+	used := 0
+	for i, r := range ranges {
+		if r.Begin < 0 || r.End < 0 || (r.End > 0 && r.Begin > r.End) {
+			return nil, 0, fmt.Errorf("invalid range, begin %d end %d", r.Begin, r.End)
+		}
+		lines, err := cf.ReadFileTruncated(int(r.Begin), int(r.End))
+		if err != nil {
+			return nil, 0, err
+		}
+		if i > 0 {
+			result = append(result, "...\n")
+		}
+		if used+len(lines) > budget {
+			result = append(result, lines[:budget-used]...)
+			return result, budget, nil
+		}
+		result = append(result, lines...)
+		used += len(lines)
+	}
+	return result, used, nil
+}
+
+func ReadFilesSchema() *llm.ToolSchema {
+	return &llm.ToolSchema{
+		Type: "function",
+		Function: llm.ToolFunctionDefinition{
+			Name:        TOOL_READ_FILES,
+			Description: "read_files可以在一次调用中读取多个文本文件，每个文件可以指定多个行范围，输出格式与read_file相同，每一行的开头有行号。所有文件共享line_budget限制，超出部分会被截断，单个文件读取失败不影响其他文件。需要同时查看多个相关文件时，优先使用它而不是多次调用read_file。",
+			Parameters: llm.ToolParameters{
+				Type: "object",
+				Properties: map[string]llm.ToolProperty{
+					"files": {
+						Type:        "array",
+						Description: "要读取的文件列表",
+						Items: &llm.ToolProperty{
+							Type: "object",
+							Properties: map[string]llm.ToolProperty{
+								"path": {
+									Type:        "string",
+									Description: "文件路径",
+								},
+								"ranges": {
+									Type:        "array",
+									Description: "要读取的行范围列表，如果省略则读取整个文件",
+									Items: &llm.ToolProperty{
+										Type: "object",
+										Properties: map[string]llm.ToolProperty{
+											"begin": {
+												Type:        "integer",
+												Description: "起始行数，如果省略则从文件开头开始读取",
+											},
+											"end": {
+												Type:        "integer",
+												Description: "结束行数，如果省略则读取到文件末尾",
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+				Required: []string{"files"},
+			},
+		},
+	}
+}
+
+var ReadFilesToolDesc = &ToolDesc{
+	Name:   TOOL_READ_FILES,
+	Intent: locales.Sprintf("Bergo is reading files"),
+	Schema: ReadFilesSchema(),
+	OutputFunc: func(call *llm.ToolCall, content string) string {
+		stub := &ReadFilesToolResult{}
+		json.Unmarshal([]byte(call.Function.Arguments), stub)
+		var scopes []string
+		for _, item := range stub.Files {
+			if len(item.Ranges) == 0 {
+				scopes = append(scopes, locales.Sprintf("%s, whole file", item.Path))
+				continue
+			}
+			var ranges []string
+			for _, r := range item.Ranges {
+				ranges = append(ranges, fmt.Sprintf("%d-%d", r.Begin, r.End))
+			}
+			scopes = append(scopes, locales.Sprintf("%s, lines %s", item.Path, strings.Join(ranges, ",")))
+		}
+		return utils.InfoMessageStyle(locales.Sprintf("read %d files\n%s", len(stub.Files), strings.Join(scopes, "\n")))
+	},
+}
@@ -44,8 +44,9 @@ func Remove(ctx context.Context, input *AgentInput) *AgentOutput {
 		}
 	}
 	return &AgentOutput{
-		Content:  fmt.Sprintf("%s removed successfully", path),
-		ToolCall: input.ToolCall,
+		Content:      fmt.Sprintf("%s removed successfully", path),
+		ToolCall:     input.ToolCall,
+		ModifiedPath: path,
 	}
 }
 
@@ -0,0 +1,252 @@
+package tools
+
+import (
+	"bergo/berio"
+	"bergo/config"
+	"bergo/llm"
+	"bergo/locales"
+	"bergo/prompt"
+	"bergo/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	TOOL_REPORT_FINDING = "report_finding"
+)
+
+// 非交互审查时子agent可用的工具，不包含会改动工作区或执行命令的工具
+var ReviewToolScope = []string{TOOL_READ_FILE, TOOL_READ_FILES, TOOL_BERAG, TOOL_SEARCH_CODE, TOOL_GIT_STATUS, TOOL_GIT_DIFF, TOOL_GIT_LOG, TOOL_GIT_BLAME, TOOL_REPORT_FINDING, TOOL_STOP_LOOP}
+
+// 正在进行的审查，report_finding把问题记录到这里
+var (
+	reviewMu     sync.Mutex
+	activeReview *utils.ReviewReport
+)
+
+func StartReview(report *utils.ReviewReport) {
+	reviewMu.Lock()
+	defer reviewMu.Unlock()
+	activeReview = report
+}
+
+// FinishReview 结束审查并返回结果，没有进行中的审查时返回nil
+func FinishReview() *utils.ReviewReport {
+	reviewMu.Lock()
+	defer reviewMu.Unlock()
+	report := activeReview
+	activeReview = nil
+	return report
+}
+
+func ReviewInProgress() bool {
+	reviewMu.Lock()
+	defer reviewMu.Unlock()
+	return activeReview != nil
+}
+
+// ReviewTarget 要审查的改动，Diff为读取改动时git_diff的参数
+type ReviewTarget struct {
+	Description string
+	Diff        *GitDiffToolResult
+	Stat        string
+}
+
+// DiffArguments 只包含有值的git_diff参数，提供给模型直接使用
+func (t *ReviewTarget) DiffArguments() string {
+	args := map[string]interface{}{}
+	if t.Diff.Staged {
+		args["staged"] = true
+	}
+	if t.Diff.From != "" {
+		args["from"] = t.Diff.From
+	}
+	if t.Diff.To != "" {
+		args["to"] = t.Diff.To
+	}
+	if len(t.Diff.Paths) > 0 {
+		args["paths"] = t.Diff.Paths
+	}
+	data, _ := json.Marshal(args)
+	return string(data)
+}
+
+// ResolveReviewTarget 解析/review的参数：为空时审查所有未提交的改动，--staged审查已暂存的改动，
+// 已存在的路径审查该路径下未提交的改动，其他的当作git ref，审查从该ref到工作区的改动
+func ResolveReviewTarget(git *utils.Git, arg string) (*ReviewTarget, error) {
+	arg = strings.TrimSpace(arg)
+	// 仓库还没有提交时没有HEAD，只能审查暂存区
+	_, headErr := git.Run("rev-parse", "--verify", "--quiet", "HEAD")
+	base := &GitDiffToolResult{From: "HEAD"}
+	if headErr != nil {
+		base = &GitDiffToolResult{Staged: true}
+	}
+	target := &ReviewTarget{}
+	switch {
+	case arg == "--staged" || arg == "--cached":
+		target.Description = "staged changes"
+		target.Diff = &GitDiffToolResult{Staged: true}
+	case arg == "":
+		target.Description = "uncommitted changes"
+		target.Diff = base
+	default:
+		if _, err := os.Stat(arg); err == nil {
+			target.Description = fmt.Sprintf("uncommitted changes in %s", arg)
+			target.Diff = base
+			target.Diff.Paths = []string{filepath.ToSlash(filepath.Clean(arg))}
+		} else {
+			target.Description = fmt.Sprintf("changes since %s", arg)
+			target.Diff = &GitDiffToolResult{From: arg}
+		}
+	}
+	stub := *target.Diff
+	stub.Stat = true
+	args, err := GitDiffArgs(&stub)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := git.Run(args...)
+	if err != nil {
+		return nil, fmt.Errorf("unknown review target %s: %v", arg, err)
+	}
+	if strings.TrimSpace(stat) == "" {
+		return nil, fmt.Errorf("no changes to review")
+	}
+	target.Stat = truncateGitOutput(stat, "use git_diff with stat to see all files")
+	return target, nil
+}
+
+// normalizeFindingPath 把路径转换为相对于工作区的路径
+func normalizeFindingPath(path string) (string, error) {
+	if filepath.IsAbs(path) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		if path, err = filepath.Rel(wd, path); err != nil {
+			return "", err
+		}
+	}
+	path = filepath.ToSlash(filepath.Clean(path))
+	if path == "." || strings.HasPrefix(path, "../") || path == ".." {
+		return "", fmt.Errorf("file %s is outside the workspace", path)
+	}
+	return path, nil
+}
+
+func ReportFinding(ctx context.Context, input *AgentInput) *AgentOutput {
+	reviewMu.Lock()
+	report := activeReview
+	reviewMu.Unlock()
+	if report == nil {
+		return &AgentOutput{Error: fmt.Errorf("no review in progress, report_finding can only be used when reviewing code")}
+	}
+	finding := &utils.ReviewFinding{}
+	if err := json.Unmarshal([]byte(input.ToolCall.Function.Arguments), finding); err != nil {
+		return &AgentOutput{Error: err}
+	}
+	path, err := normalizeFindingPath(finding.File)
+	if err != nil {
+		return &AgentOutput{Error: err}
+	}
+	finding.File = path
+	if finding.EndLine == 0 {
+		finding.EndLine = finding.StartLine
+	}
+	if finding.EndLine < finding.StartLine {
+		return &AgentOutput{Error: fmt.Errorf("end_line %d is before start_line %d", finding.EndLine, finding.StartLine)}
+	}
+	finding.Message = strings.TrimSpace(finding.Message)
+	finding.Suggestion = strings.TrimSpace(finding.Suggestion)
+	idx, added := report.Add(finding)
+	content := fmt.Sprintf("finding %d recorded", idx)
+	if !added {
+		content = fmt.Sprintf("the same finding is already recorded as %d", idx)
+	}
+	return &AgentOutput{
+		Content:  content,
+		ToolCall: input.ToolCall,
+	}
+}
+
+// RunReview 不经过主agent，直接用子agent审查目标中的改动，用于 bergo review
+func RunReview(ctx context.Context, target *ReviewTarget, output berio.BerOutput) (*utils.ReviewReport, string, error) {
+	report := utils.NewReviewReport(target.Description)
+	StartReview(report)
+	defer FinishReview()
+	q := utils.Query{}
+	q.SetMode(prompt.MODE_VIEW)
+	q.SetUserInput(prompt.GetReviewPrompt(target.Description, target.DiffArguments(), target.Stat, true))
+	// 无人值守时没有用户可以中断，使用berag的预算限制审查的用量
+	shared := &SharedExtract{
+		TokenBudget: config.GlobalConfig.BeragTokenBudget,
+		CostBudget:  config.GlobalConfig.BeragCostBudget,
+	}
+	task := &Task{
+		ToolScope: ReviewToolScope,
+		ID:        NewTaskID(),
+		Context:   []*llm.ChatItem{{Role: "user", Message: q.Build()}},
+		Mode:      prompt.MODE_VIEW,
+		// 顺序执行，和stop_loop同一轮调用的report_finding也能被记录
+		ParallelToolUse: false,
+		shared:          shared,
+		Model:           config.GlobalConfig.MainModel,
+		output:          output,
+	}
+	answer := task.Run(ctx, &AgentInput{Output: output})
+	if answer.InterruptErr != nil {
+		return report, "", answer.InterruptErr
+	}
+	if answer.Error != nil {
+		return report, "", answer.Error
+	}
+	return report, answer.Content, nil
+}
+
+func ReportFindingSchema() *llm.ToolSchema {
+	minLine := float64(1)
+	minMessage := 1
+	var severities, categories []interface{}
+	for _, severity := range utils.ReviewSeverities {
+		severities = append(severities, severity)
+	}
+	for _, category := range utils.ReviewCategories {
+		categories = append(categories, category)
+	}
+	return &llm.ToolSchema{
+		Type: "function",
+		Function: llm.ToolFunctionDefinition{
+			Name:        TOOL_REPORT_FINDING,
+			Description: "report_finding用来在代码审查时记录一个问题，每个问题调用一次。只能在审查代码时使用",
+			Parameters: llm.ToolParameters{
+				Type: "object",
+				Properties: map[string]llm.ToolProperty{
+					"file":       {Type: "string", Description: "问题所在的文件，相对于工作区的路径"},
+					"start_line": {Type: "integer", Description: "问题开始的行号，从1开始，使用改动后文件中的行号", Minimum: &minLine},
+					"end_line":   {Type: "integer", Description: "问题结束的行号，省略时与start_line相同", Minimum: &minLine},
+					"severity":   {Type: "string", Description: "严重程度：blocker 必须修复才能合并，major 应该修复，minor 可以改进，nit 细节", Enum: severities},
+					"category":   {Type: "string", Description: "问题的分类", Enum: categories},
+					"message":    {Type: "string", Description: "问题的描述，说明为什么这是个问题", MinLength: &minMessage},
+					"suggestion": {Type: "string", Description: "修改建议，可以包含示例代码"},
+				},
+				Required: []string{"file", "start_line", "severity", "category", "message"},
+			},
+		},
+	}
+}
+
+var ReportFindingToolDesc = &ToolDesc{
+	Name:   TOOL_REPORT_FINDING,
+	Intent: locales.Sprintf("Bergo is reporting a finding"),
+	Schema: ReportFindingSchema(),
+	OutputFunc: func(call *llm.ToolCall, content string) string {
+		finding := &utils.ReviewFinding{}
+		json.Unmarshal([]byte(call.Function.Arguments), finding)
+		return utils.InfoMessageStyle(fmt.Sprintf("%s %s", finding.Title(), finding.Simple()))
+	},
+}
@@ -0,0 +1,391 @@
+package tools
+
+import (
+	"bergo/llm"
+	"bergo/locales"
+	"bergo/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TOOL_RUN_TESTS = "run_tests"
+
+	TEST_FRAMEWORK_GO     = "go"
+	TEST_FRAMEWORK_PYTEST = "pytest"
+	TEST_FRAMEWORK_JEST   = "jest"
+	TEST_FRAMEWORK_VITEST = "vitest"
+	TEST_FRAMEWORK_CARGO  = "cargo"
+
+	// 默认超时秒数
+	RUN_TESTS_TIMEOUT = 600
+	// 摘要中最多列出的失败用例数
+	RUN_TESTS_MAX_FAILURES = 20
+	// 没有解析出测试结果时返回的输出行数
+	RUN_TESTS_OUTPUT_LINE = 50
+)
+
+var TestFrameworks = []string{TEST_FRAMEWORK_GO, TEST_FRAMEWORK_PYTEST, TEST_FRAMEWORK_JEST, TEST_FRAMEWORK_VITEST, TEST_FRAMEWORK_CARGO}
+
+// 每个框架最近一次运行的参数和结果，rerun_failed根据它只重跑失败的用例
+var (
+	testRunMu     sync.Mutex
+	lastTestRuns  = map[string]*testRun{}
+	lastTestFrame string
+)
+
+type testRun struct {
+	args   *RunTestsToolResult
+	report *utils.TestReport
+}
+
+type RunTestsToolResult struct {
+	Framework   string `json:"framework"`
+	Target      string `json:"target"`
+	Filter      string `json:"filter"`
+	RerunFailed bool   `json:"rerun_failed"`
+	Timeout     int    `json:"timeout"`
+}
+
+// DetectTestFramework 根据工作区中的项目文件判断测试框架
+func DetectTestFramework() (string, error) {
+	exists := func(name string) bool {
+		_, err := os.Stat(name)
+		return err == nil
+	}
+	if exists("go.mod") {
+		return TEST_FRAMEWORK_GO, nil
+	}
+	if exists("Cargo.toml") {
+		return TEST_FRAMEWORK_CARGO, nil
+	}
+	if data, err := os.ReadFile("package.json"); err == nil {
+		if strings.Contains(string(data), `"vitest"`) {
+			return TEST_FRAMEWORK_VITEST, nil
+		}
+		if strings.Contains(string(data), `"jest"`) {
+			return TEST_FRAMEWORK_JEST, nil
+		}
+	}
+	if exists("pytest.ini") || exists("conftest.py") || exists("tox.ini") || exists("setup.cfg") {
+		return TEST_FRAMEWORK_PYTEST, nil
+	}
+	if data, err := os.ReadFile("pyproject.toml"); err == nil && strings.Contains(string(data), "pytest") {
+		return TEST_FRAMEWORK_PYTEST, nil
+	}
+	return "", fmt.Errorf("can not detect the test framework, specify framework as one of %s", strings.Join(TestFrameworks, ", "))
+}
+
+// BuildTestCommand 生成测试命令，failures不为空时只运行这些失败的用例
+func BuildTestCommand(args *RunTestsToolResult, failures []*utils.TestFailure, reportFile string) (string, error) {
+	var parts []string
+	switch args.Framework {
+	case TEST_FRAMEWORK_GO:
+		parts = []string{"go", "test", "-json"}
+		pkgs := strings.Fields(args.Target)
+		run := args.Filter
+		if len(failures) > 0 {
+			var failedPkgs []string
+			failedPkgs, run = goRerunArgs(failures)
+			// 从非JSON输出解析出的编译失败没有包名，此时重跑上次的目标
+			if len(failedPkgs) > 0 {
+				pkgs = failedPkgs
+			}
+		}
+		if run != "" {
+			parts = append(parts, "-run", utils.ShellQuote(run))
+		}
+		if len(pkgs) == 0 {
+			pkgs = []string{"./..."}
+		}
+		for _, pkg := range pkgs {
+			parts = append(parts, utils.ShellQuote(pkg))
+		}
+	case TEST_FRAMEWORK_PYTEST:
+		python := "pytest"
+		if _, err := exec.LookPath("pytest"); err != nil {
+			python = "python3 -m pytest"
+		}
+		// xunit1格式才会包含用例的文件和行号
+		parts = []string{python, "-q", "-o", "junit_family=xunit1", "--junitxml=" + utils.ShellQuote(reportFile)}
+		if args.Filter != "" {
+			parts = append(parts, "-k", utils.ShellQuote(args.Filter))
+		}
+		// pytest自己记录了上次失败的用例
+		if len(failures) > 0 {
+			parts = append(parts, "--lf")
+		}
+		for _, target := range strings.Fields(args.Target) {
+			parts = append(parts, utils.ShellQuote(target))
+		}
+	case TEST_FRAMEWORK_JEST, TEST_FRAMEWORK_VITEST:
+		if args.Framework == TEST_FRAMEWORK_JEST {
+			parts = []string{"npx", "jest", "--json", "--testLocationInResults", "--outputFile=" + utils.ShellQuote(reportFile)}
+		} else {
+			parts = []string{"npx", "vitest", "run", "--reporter=json", "--outputFile=" + utils.ShellQuote(reportFile)}
+		}
+		targets := strings.Fields(args.Target)
+		filter := args.Filter
+		if len(failures) > 0 {
+			var failedFiles []string
+			failedFiles, filter = jsRerunArgs(failures)
+			if len(failedFiles) > 0 {
+				targets = failedFiles
+			}
+		}
+		if filter != "" {
+			parts = append(parts, "-t", utils.ShellQuote(filter))
+		}
+		for _, target := range targets {
+			parts = append(parts, utils.ShellQuote(target))
+		}
+	case TEST_FRAMEWORK_CARGO:
+		parts = []string{"cargo", "test"}
+		for _, pkg := range strings.Fields(args.Target) {
+			parts = append(parts, "-p", utils.ShellQuote(pkg))
+		}
+		if len(failures) > 0 {
+			parts = append(parts, "--", "--exact")
+			for _, f := range failures {
+				if f.Name != "" {
+					parts = append(parts, utils.ShellQuote(f.Name))
+				}
+			}
+		} else if args.Filter != "" {
+			parts = append(parts, utils.ShellQuote(args.Filter))
+		}
+	default:
+		return "", fmt.Errorf("unsupported test framework %s, use one of %s", args.Framework, strings.Join(TestFrameworks, ", "))
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// goRerunArgs 失败用例所在的包和匹配顶层测试函数的-run参数，包本身失败（例如编译错误）时重跑整个包
+func goRerunArgs(failures []*utils.TestFailure) ([]string, string) {
+	var pkgs, names []string
+	wholePkg := false
+	for _, f := range failures {
+		if f.Suite != "" && !slices.Contains(pkgs, f.Suite) {
+			pkgs = append(pkgs, f.Suite)
+		}
+		if f.Name == "" {
+			wholePkg = true
+			continue
+		}
+		name, _, _ := strings.Cut(f.Name, "/")
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	if wholePkg || len(names) == 0 {
+		return pkgs, ""
+	}
+	return pkgs, "^(" + strings.Join(names, "|") + ")$"
+}
+
+// jsRerunArgs 失败用例所在的文件和匹配用例全名的-t参数
+func jsRerunArgs(failures []*utils.TestFailure) ([]string, string) {
+	var files, names []string
+	wholeFile := false
+	for _, f := range failures {
+		if f.File != "" && !slices.Contains(files, f.File) {
+			files = append(files, f.File)
+		}
+		if f.Name == "" {
+			wholeFile = true
+			continue
+		}
+		names = append(names, regexp.QuoteMeta(f.Name))
+	}
+	if wholeFile || len(names) == 0 {
+		return files, ""
+	}
+	return files, "^(" + strings.Join(names, "|") + ")$"
+}
+
+// ParseTestOutput 根据框架解析命令输出或者报告文件
+func ParseTestOutput(framework string, output string, report []byte) (*utils.TestReport, error) {
+	var result *utils.TestReport
+	var err error
+	switch framework {
+	case TEST_FRAMEWORK_GO:
+		result = utils.ParseGoTestJSON(output)
+	case TEST_FRAMEWORK_CARGO:
+		result = utils.ParseCargoTest(output)
+	case TEST_FRAMEWORK_PYTEST:
+		result, err = utils.ParseJUnitXML(report)
+	case TEST_FRAMEWORK_JEST, TEST_FRAMEWORK_VITEST:
+		result, err = utils.ParseJestJSON(report)
+	default:
+		return nil, fmt.Errorf("unsupported test framework %s", framework)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Framework = framework
+	return result, nil
+}
+
+func RunTests(ctx context.Context, input *AgentInput) *AgentOutput {
+	stub := &RunTestsToolResult{}
+	json.Unmarshal([]byte(input.ToolCall.Function.Arguments), stub)
+	var failures []*utils.TestFailure
+	testRunMu.Lock()
+	if stub.RerunFailed {
+		if stub.Framework == "" {
+			stub.Framework = lastTestFrame
+		}
+		last := lastTestRuns[stub.Framework]
+		if last == nil {
+			testRunMu.Unlock()
+			return &AgentOutput{Error: fmt.Errorf("no previous test run to rerun, run the tests first")}
+		}
+		if len(last.report.Failures) == 0 {
+			testRunMu.Unlock()
+			return &AgentOutput{Content: "there are no failed tests in the last run", ToolCall: input.ToolCall}
+		}
+		failures = last.report.Failures
+		// 继承上次运行的参数，pytest和cargo重跑时仍然需要原来的范围
+		stub.Target = last.args.Target
+		stub.Filter = last.args.Filter
+	}
+	testRunMu.Unlock()
+	if stub.Framework == "" {
+		framework, err := DetectTestFramework()
+		if err != nil {
+			return &AgentOutput{Error: err}
+		}
+		stub.Framework = framework
+	}
+	if stub.Timeout <= 0 {
+		stub.Timeout = RUN_TESTS_TIMEOUT
+	}
+	// pytest、jest和vitest的结果写到报告文件中
+	reportFile := ""
+	if stub.Framework != TEST_FRAMEWORK_GO && stub.Framework != TEST_FRAMEWORK_CARGO {
+		ext := ".json"
+		if stub.Framework == TEST_FRAMEWORK_PYTEST {
+			ext = ".xml"
+		}
+		file, err := os.CreateTemp("", "bergo-tests-*"+ext)
+		if err != nil {
+			return &AgentOutput{Error: err}
+		}
+		file.Close()
+		reportFile = file.Name()
+		defer os.Remove(reportFile)
+	}
+	command, err := BuildTestCommand(stub, failures, reportFile)
+	if err != nil {
+		return &AgentOutput{Error: err}
+	}
+	if !input.AllowMap[TOOL_RUN_TESTS] && !input.isTask {
+		res := input.Input.Select(locales.Sprintf("Are you sure to run the tests: %s", command), []string{locales.Sprintf("Yes"), locales.Sprintf("Always Yes"), locales.Sprintf("Skip")})
+		if res == locales.Sprintf("Skip") {
+			return &AgentOutput{
+				Error: fmt.Errorf("User choose to skip"),
+			}
+		}
+		if res == locales.Sprintf("Always Yes") {
+			input.AllowMap[TOOL_RUN_TESTS] = true
+		}
+	}
+	shell := utils.Shell{IsTask: input.isTask}
+	// 有失败用例时命令非零退出是正常的，只有解析不出结果时才把输出返回给模型
+	output, runErr := shell.RunCommand(ctx, command, time.Duration(stub.Timeout)*time.Second)
+	if ctx.Err() == context.Canceled {
+		return &AgentOutput{InterruptErr: runErr}
+	}
+	var data []byte
+	if reportFile != "" {
+		data, _ = os.ReadFile(reportFile)
+	}
+	report, err := ParseTestOutput(stub.Framework, output, data)
+	if err != nil || (report.Passed+report.Failed+report.Skipped == 0 && runErr != nil) {
+		if runErr == nil {
+			runErr = err
+		}
+		return &AgentOutput{
+			Error:    fmt.Errorf("no test results found, `%s` failed: %v\n%s", command, runErr, utils.TailLines(output, RUN_TESTS_OUTPUT_LINE)),
+			ToolCall: input.ToolCall,
+		}
+	}
+	report.Command = command
+	testRunMu.Lock()
+	lastTestRuns[stub.Framework] = &testRun{args: stub, report: report}
+	lastTestFrame = stub.Framework
+	testRunMu.Unlock()
+	content := report.Format(RUN_TESTS_MAX_FAILURES)
+	if runErr != nil && report.Failed == 0 {
+		content += fmt.Sprintf("\nthe command exited with error: %v\n%s", runErr, utils.TailLines(output, RUN_TESTS_OUTPUT_LINE))
+	}
+	return &AgentOutput{
+		Content:  content,
+		ToolCall: input.ToolCall,
+	}
+}
+
+func RunTestsSchema() *llm.ToolSchema {
+	minTimeout := float64(1)
+	var frameworks []interface{}
+	for _, framework := range TestFrameworks {
+		frameworks = append(frameworks, framework)
+	}
+	return &llm.ToolSchema{
+		Type: "function",
+		Function: llm.ToolFunctionDefinition{
+			Name:        TOOL_RUN_TESTS,
+			Description: "run_tests用来运行项目的测试，返回通过和失败的数量，以及每个失败用例的名字、位置、断言消息和截断后的输出。运行测试时优先使用这个工具而不是shell_cmd。修复失败的用例后可以用rerun_failed只重跑上次失败的用例",
+			Parameters: llm.ToolParameters{
+				Type: "object",
+				Properties: map[string]llm.ToolProperty{
+					"framework": {
+						Type:        "string",
+						Description: "测试框架，省略时根据go.mod、Cargo.toml、package.json、pytest配置自动判断",
+						Enum:        frameworks,
+					},
+					"target": {
+						Type:        "string",
+						Description: "测试范围，多个用空格分隔。go为包路径（默认./...），pytest、jest、vitest为测试文件或目录，cargo为包名。省略时运行全部测试",
+					},
+					"filter": {
+						Type:        "string",
+						Description: "按用例名过滤。go为-run的正则，pytest为-k表达式，jest和vitest为-t的正则，cargo为名字中包含的字符串",
+					},
+					"rerun_failed": {
+						Type:        "boolean",
+						Description: "为true时只重跑上一次运行中失败的用例，沿用上次运行的target和filter",
+					},
+					"timeout": {
+						Type:        "integer",
+						Description: "超时秒数，默认600",
+						Minimum:     &minTimeout,
+					},
+				},
+			},
+		},
+	}
+}
+
+var RunTestsToolDesc = &ToolDesc{
+	Name:   TOOL_RUN_TESTS,
+	Intent: locales.Sprintf("Bergo is running tests"),
+	Schema: RunTestsSchema(),
+	OutputFunc: func(call *llm.ToolCall, content string) string {
+		for _, line := range strings.Split(content, "\n") {
+			if strings.HasPrefix(line, "passed: ") {
+				return utils.InfoMessageStyle(locales.Sprintf("tests executed: %s", line))
+			}
+		}
+		line, _, _ := strings.Cut(content, "\n")
+		return utils.InfoMessageStyle(line)
+	},
+}
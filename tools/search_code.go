@@ -0,0 +1,134 @@
+package tools
+
+import (
+	"bergo/llm"
+	"bergo/locales"
+	"bergo/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const (
+	TOOL_SEARCH_CODE = "search_code"
+
+	SEARCH_CODE_DEFAULT_LIMIT = 10
+	SEARCH_CODE_MAX_LIMIT     = 50
+	// berag开始前提供给子agent的候选文件数
+	BERAG_CANDIDATE_COUNT = 20
+)
+
+var (
+	codeIndexOnce sync.Once
+	codeIndex     *utils.CodeIndex
+)
+
+// GetCodeIndex 工作区的代码索引，每次检索前增量更新
+func GetCodeIndex() *utils.CodeIndex {
+	codeIndexOnce.Do(func() {
+		codeIndex = utils.NewCodeIndex(".", utils.CODE_INDEX_FILE)
+	})
+	return codeIndex
+}
+
+type SearchCodeToolResult struct {
+	Query string `json:"query"`
+	Path  string `json:"path"`
+	Limit int    `json:"limit"`
+}
+
+func SearchCode(ctx context.Context, input *AgentInput) *AgentOutput {
+	stub := &SearchCodeToolResult{}
+	json.Unmarshal([]byte(input.ToolCall.Function.Arguments), stub)
+	if strings.TrimSpace(stub.Query) == "" {
+		return &AgentOutput{Error: fmt.Errorf("query is empty")}
+	}
+	if stub.Limit <= 0 {
+		stub.Limit = SEARCH_CODE_DEFAULT_LIMIT
+	}
+	index := GetCodeIndex()
+	if _, err := index.Update(); err != nil {
+		return &AgentOutput{Error: fmt.Errorf("update code index failed: %v", err)}
+	}
+	results := index.Search(stub.Query, stub.Path, min(stub.Limit, SEARCH_CODE_MAX_LIMIT))
+	if len(results) == 0 {
+		return &AgentOutput{Content: "no matching files", ToolCall: input.ToolCall}
+	}
+	return &AgentOutput{Content: formatCodeSearchResults(results, true), ToolCall: input.ToolCall}
+}
+
+func formatCodeSearchResults(results []*utils.CodeSearchResult, withLines bool) string {
+	buf := bytes.NewBufferString("")
+	for i, result := range results {
+		buf.WriteString(fmt.Sprintf("%d. %s (score %.2f)\n", i+1, result.Path, result.Score))
+		if !withLines {
+			continue
+		}
+		for _, line := range result.Lines {
+			buf.WriteString(fmt.Sprintf("   %d: %s\n", line.Line, line.Text))
+		}
+	}
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+// beragCandidates 在berag子agent开始前根据查询检索候选文件，索引失败或没有结果时返回空
+func beragCandidates(query string) string {
+	if strings.TrimSpace(query) == "" {
+		return ""
+	}
+	index := GetCodeIndex()
+	if _, err := index.Update(); err != nil {
+		return ""
+	}
+	results := index.Search(query, "", BERAG_CANDIDATE_COUNT)
+	if len(results) == 0 {
+		return ""
+	}
+	return "<candidate_files>\n以下是根据关键词在本地代码索引中检索到的候选文件，按相关度从高到低排列，可以优先考虑用berag_extract提取它们，再决定是否需要继续寻找\n" + formatCodeSearchResults(results, false) + "\n</candidate_files>"
+}
+
+func SearchCodeSchema() *llm.ToolSchema {
+	minLimit := float64(1)
+	maxLimit := float64(SEARCH_CODE_MAX_LIMIT)
+	return &llm.ToolSchema{
+		Type: "function",
+		Function: llm.ToolFunctionDefinition{
+			Name:        TOOL_SEARCH_CODE,
+			Description: "search_code在本地代码索引中按关键词检索文件，使用BM25排序，会匹配标识符、注释和路径，驼峰和下划线命名会被拆开匹配。返回最相关的文件和包含关键词的行。适合在不知道代码位置时快速定位文件，比用shell_cmd运行grep更省上下文",
+			Parameters: llm.ToolParameters{
+				Type: "object",
+				Properties: map[string]llm.ToolProperty{
+					"query": {
+						Type:        "string",
+						Description: "要检索的关键词，可以是多个标识符或者自然语言描述，如 parse config file",
+					},
+					"path": {
+						Type:        "string",
+						Description: "只在该目录或文件下检索，省略时检索整个工作区",
+					},
+					"limit": {
+						Type:        "integer",
+						Description: "返回的文件数，默认10",
+						Minimum:     &minLimit,
+						Maximum:     &maxLimit,
+					},
+				},
+				Required: []string{"query"},
+			},
+		},
+	}
+}
+
+var SearchCodeToolDesc = &ToolDesc{
+	Name:   TOOL_SEARCH_CODE,
+	Intent: locales.Sprintf("Bergo is searching code"),
+	Schema: SearchCodeSchema(),
+	OutputFunc: func(call *llm.ToolCall, content string) string {
+		stub := &SearchCodeToolResult{}
+		json.Unmarshal([]byte(call.Function.Arguments), stub)
+		return utils.InfoMessageStyle(locales.Sprintf("code searched: %s", stub.Query))
+	},
+}
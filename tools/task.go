@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 var taskId atomic.Int64
@@ -28,10 +29,36 @@ type Task struct {
 	Mode            string
 	toolHandler     map[string]func(ctx context.Context, input *AgentInput) *AgentOutput
 	ParallelToolUse bool
+	MaxConcurrency  int           // 并行工具调用同时运行的数量上限，0表示不限制
+	ToolTimeout     time.Duration // 并行工具调用的单次超时时间，0表示不限制
 	shared          *SharedExtract
 	Model           string
+	sessionId       string // 执行hook时传给hook的会话id
 	output          berio.BerOutput
 	toolSchema      []*llm.ToolSchema
+	modifiedMu      sync.Mutex
+	modified        []string // 子任务修改过的文件
+}
+
+func (t *Task) addModified(path string) {
+	if path == "" {
+		return
+	}
+	t.modifiedMu.Lock()
+	defer t.modifiedMu.Unlock()
+	for _, p := range t.modified {
+		if p == path {
+			return
+		}
+	}
+	t.modified = append(t.modified, path)
+}
+
+// ModifiedFiles 返回子任务修改过的文件列表
+func (t *Task) ModifiedFiles() []string {
+	t.modifiedMu.Lock()
+	defer t.modifiedMu.Unlock()
+	return append([]string{}, t.modified...)
 }
 
 func (t *Task) GetChatContext() []*llm.ChatItem {
@@ -52,8 +79,12 @@ func (t *Task) GetChatContext() []*llm.ChatItem {
 
 func (t *Task) doParalleToolUse(ctx context.Context, call []*llm.ToolCall) ([]*AgentOutput, error) {
 	wg := sync.WaitGroup{}
-	isInterrupt := false
+	isInterrupt := atomic.Bool{}
 	preChats := t.GetChatContext()
+	var sem chan struct{}
+	if t.MaxConcurrency > 0 {
+		sem = make(chan struct{}, t.MaxConcurrency)
+	}
 	var results []*AgentOutput
 	for i, item := range call {
 		toolCall := item
@@ -76,15 +107,37 @@ func (t *Task) doParalleToolUse(ctx context.Context, call []*llm.ToolCall) ([]*A
 			wg.Add(1)
 			go func(i int) {
 				defer wg.Done()
-				output := handler(ctx, in)
+				if sem != nil {
+					sem <- struct{}{}
+					defer func() { <-sem }()
+				}
+				// 排队期间预算用完的调用不再执行
+				if err := t.shared.BudgetExceeded(); err != nil {
+					results[i].Content = fmt.Sprintf("skipped: %v", err)
+					return
+				}
+				callCtx, cancel := ctx, context.CancelFunc(func() {})
+				if t.ToolTimeout > 0 {
+					callCtx, cancel = context.WithTimeout(ctx, t.ToolTimeout)
+				}
+				output, _ := CallToolWithHooks(callCtx, t.output, t.sessionId, t.Mode, toolCall, func(ctx context.Context, call *llm.ToolCall) (*AgentOutput, error) {
+					return handler(ctx, in), nil
+				})
+				cancel()
+				// 单个调用超时不影响其他调用，只有用户中断才终止整个流程
+				if ctx.Err() == nil && callCtx.Err() == context.DeadlineExceeded {
+					results[i].Content = fmt.Sprintf("error: %s timed out after %v", toolCall.Function.Name, t.ToolTimeout)
+					return
+				}
 				if output.InterruptErr != nil {
 					output.Error = output.InterruptErr
-					isInterrupt = true
+					isInterrupt.Store(true)
 				}
 				if output.Error != nil {
 					results[i].Content = fmt.Sprintf("error: %v", output.Error)
 					return
 				}
+				t.addModified(output.ModifiedPath)
 				results[i].Content = output.Content
 			}(i)
 		} else {
@@ -93,12 +146,21 @@ func (t *Task) doParalleToolUse(ctx context.Context, call []*llm.ToolCall) ([]*A
 
 	}
 	wg.Wait()
-	if isInterrupt {
+	if isInterrupt.Load() {
 		return nil, fmt.Errorf("user interrupted")
 	}
 	return results, nil
 }
+
+// doToolUse 调用工具，和主agent一样在调用前后执行PreToolUse和PostToolUse hook
 func (t *Task) doToolUse(ctx context.Context, call *llm.ToolCall) (*AgentOutput, error) {
+	if _, ok := t.toolHandler[call.Function.Name]; !ok {
+		return nil, nil
+	}
+	return CallToolWithHooks(ctx, t.output, t.sessionId, t.Mode, call, t.callTool)
+}
+
+func (t *Task) callTool(ctx context.Context, call *llm.ToolCall) (*AgentOutput, error) {
 	err := JsonSchemaExam(call)
 	if err != nil {
 		return &AgentOutput{Content: fmt.Sprintf("error: %v", err), ToolCall: call}, nil
@@ -121,6 +183,7 @@ func (t *Task) doToolUse(ctx context.Context, call *llm.ToolCall) (*AgentOutput,
 		if answer.InterruptErr != nil {
 			return nil, answer.InterruptErr
 		}
+		t.addModified(answer.ModifiedPath)
 		return &AgentOutput{Content: answer.Content, ToolCall: call}, nil
 	}
 	return nil, nil
@@ -147,6 +210,11 @@ func (t *Task) Run(ctx context.Context, input *AgentInput) *AgentOutput {
 	t.initTools()
 	res := &AgentOutput{Error: fmt.Errorf("unkown error")}
 	modelConfig := config.GlobalConfig.GetModelConfig(t.Model)
+	// 预算已经用完时不会请求llm，由循环返回预算错误
+	if modelConfig == nil && t.shared.BudgetExceeded() == nil {
+		res.Error = fmt.Errorf("model %s not found", t.Model)
+		return res
+	}
 	toolCallAnswers := []*AgentOutput{}
 	toolCallRequests := []*llm.ToolCall{}
 
@@ -154,6 +222,11 @@ func (t *Task) Run(ctx context.Context, input *AgentInput) *AgentOutput {
 		if len(t.Context) == 0 {
 			break
 		}
+		// 预算用完后不再请求llm，由调用方决定如何处理已有的结果
+		if err := t.shared.BudgetExceeded(); err != nil {
+			res.Error = err
+			break
+		}
 		//请求llm
 		if t.Context[len(t.Context)-1].Role == "assistant" {
 			q := utils.Query{}
@@ -170,7 +243,8 @@ func (t *Task) Run(ctx context.Context, input *AgentInput) *AgentOutput {
 		reasoningContent := bytes.NewBuffer(nil)
 		streamer, err := utils.NewLlmStreamer(ctx, modelConfig, chatItems, t.toolSchema)
 		if err != nil {
-			panic(err)
+			res.Error = err
+			break
 		}
 		for streamer.Next() {
 			rc, c := streamer.Read()
@@ -181,10 +255,6 @@ func (t *Task) Run(ctx context.Context, input *AgentInput) *AgentOutput {
 			res.Error = streamer.Error()
 			break
 		}
-		if err != nil {
-			res.Error = err
-			break
-		}
 		toolCallRequests = streamer.ToolCalls()
 		toolCallAnswers = nil
 
@@ -196,6 +266,7 @@ func (t *Task) Run(ctx context.Context, input *AgentInput) *AgentOutput {
 			ToolCalls:        toolCallRequests,
 		})
 		t.shared.UsageUpdate(streamer.TokenUsage)
+		t.shared.CostUpdate(streamer.TokenUsage, modelConfig.PricePerMilToken)
 		// 更新当前 task 的进度信息（记录最新一轮的回复和工具调用）
 		t.shared.UpdateTaskProgress(t.ID, content.String(), toolCallRequests, streamer.TokenUsage)
 		//做tool use
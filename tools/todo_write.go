@@ -0,0 +1,129 @@
+package tools
+
+import (
+	"bergo/berio"
+	"bergo/llm"
+	"bergo/locales"
+	"bergo/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	TOOL_TODO_WRITE = "todo_write"
+)
+
+type TodoWriteToolResult struct {
+	Todos []*utils.TodoItem `json:"todos"`
+}
+
+// TodoWrite 用新的任务列表整体替换当前的todo list
+func TodoWrite(ctx context.Context, input *AgentInput) *AgentOutput {
+	stub := TodoWriteToolResult{}
+	json.Unmarshal([]byte(input.ToolCall.Function.Arguments), &stub)
+
+	list := &utils.TodoList{}
+	inProgress := 0
+	for i, item := range stub.Todos {
+		if item == nil {
+			continue
+		}
+		item.Content = strings.TrimSpace(item.Content)
+		if item.Content == "" {
+			return &AgentOutput{
+				Error: fmt.Errorf("content of todo %d is empty", i+1),
+			}
+		}
+		if item.Status == "" {
+			item.Status = utils.TODO_PENDING
+		}
+		if !utils.IsValidTodoStatus(item.Status) {
+			return &AgentOutput{
+				Error: fmt.Errorf("invalid status %q of todo %d, must be one of %s, %s, %s", item.Status, i+1, utils.TODO_PENDING, utils.TODO_IN_PROGRESS, utils.TODO_DONE),
+			}
+		}
+		if item.Status == utils.TODO_IN_PROGRESS {
+			inProgress++
+		}
+		if item.Id == "" {
+			item.Id = fmt.Sprintf("%d", i+1)
+		}
+		list.Items = append(list.Items, item)
+	}
+
+	if input.Timeline != nil {
+		input.Timeline.AddTodoList(list)
+	}
+	if input.Output != nil {
+		input.Output.OnSystemMsg(list, berio.MsgTypeTodoList)
+	}
+
+	done, total := list.Progress()
+	content := fmt.Sprintf("todo list updated, %d/%d done", done, total)
+	if inProgress > 1 {
+		content += fmt.Sprintf("\nwarning: %d items are in_progress, keep only one in progress at a time", inProgress)
+	}
+	if err := utils.UpdateMementoTodo(list); err != nil {
+		content += fmt.Sprintf("\nwarning: failed to sync todo list to memento file: %v", err)
+	}
+	return &AgentOutput{
+		Content:  content,
+		ToolCall: input.ToolCall,
+	}
+}
+
+func TodoWriteSchema() *llm.ToolSchema {
+	return &llm.ToolSchema{
+		Type: "function",
+		Function: llm.ToolFunctionDefinition{
+			Name:        TOOL_TODO_WRITE,
+			Description: "todo_write是用来维护任务清单的工具。每次调用都需要提交完整的任务列表，它会整体替换之前的列表，并展示给用户，同时自动同步到memento file的# todo部分。适合拆解多步骤的任务时使用：开始一个子任务前把它标记为in_progress，完成后立刻标记为done，同一时间只应该有一个in_progress的任务。简单的任务不需要使用它。",
+			Parameters: llm.ToolParameters{
+				Type: "object",
+				Properties: map[string]llm.ToolProperty{
+					"todos": {
+						Type:        "array",
+						Description: "完整的任务列表",
+						Items: &llm.ToolProperty{
+							Type: "object",
+							Properties: map[string]llm.ToolProperty{
+								"id": {
+									Type:        "string",
+									Description: "任务id，在列表中唯一，如果省略则使用序号",
+								},
+								"content": {
+									Type:        "string",
+									Description: "任务内容，简短描述要做的事",
+								},
+								"status": {
+									Type:        "string",
+									Description: "任务状态，省略时为pending",
+									Enum:        []interface{}{utils.TODO_PENDING, utils.TODO_IN_PROGRESS, utils.TODO_DONE},
+								},
+							},
+						},
+					},
+				},
+				Required: []string{"todos"},
+			},
+		},
+	}
+}
+
+var TodoWriteToolDesc = &ToolDesc{
+	Name:   TOOL_TODO_WRITE,
+	Intent: locales.Sprintf("Bergo is updating todo list"),
+	Schema: TodoWriteSchema(),
+	OutputFunc: func(call *llm.ToolCall, content string) string {
+		stub := &TodoWriteToolResult{}
+		json.Unmarshal([]byte(call.Function.Arguments), stub)
+		for _, item := range stub.Todos {
+			if item != nil && item.Status == utils.TODO_IN_PROGRESS {
+				return utils.InfoMessageStyle(locales.Sprintf("todo list updated, working on: %s", item.Content))
+			}
+		}
+		return utils.InfoMessageStyle(locales.Sprintf("todo list updated"))
+	},
+}
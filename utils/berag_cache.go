@@ -0,0 +1,284 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	BERAG_CACHE_FILE = ".bergo/berag_cache.json"
+	// 关键词重合度不低于该值时直接复用缓存的提取结果
+	BERAG_CACHE_REUSE_SCORE = 0.6
+)
+
+// 提取关键词时忽略的常见词
+var beragStopWords = []string{"the", "and", "for", "with", "from", "that", "this", "what", "how", "are", "is", "in", "of", "to", "on", "a", "an", "or", "be", "it", "find", "code", "file", "files"}
+
+var beragWordRegex = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*|\p{Han}+`)
+
+// BeragKeywords 从查询中提取关键词，英文标识符转为小写，中文按两个字切分，结果排序去重
+func BeragKeywords(query string) []string {
+	var keywords []string
+	for _, word := range beragWordRegex.FindAllString(query, -1) {
+		runes := []rune(word)
+		if len(runes) > 0 && runes[0] > 0x7f {
+			if len(runes) == 1 {
+				continue
+			}
+			for i := 0; i+1 < len(runes); i++ {
+				keywords = append(keywords, string(runes[i:i+2]))
+			}
+			continue
+		}
+		word = strings.ToLower(word)
+		if len(word) < 2 || slices.Contains(beragStopWords, word) {
+			continue
+		}
+		keywords = append(keywords, word)
+	}
+	sort.Strings(keywords)
+	return slices.Compact(keywords)
+}
+
+// KeywordScore 两组关键词的Jaccard相似度，任意一组为空时为0
+func KeywordScore(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	common := 0
+	for _, word := range a {
+		if slices.Contains(b, word) {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+// BeragCacheItem 提取出的片段，StartLine不小于EndLine时表示整个文件
+type BeragCacheItem struct {
+	StartLine int64 `json:"start_line"`
+	EndLine   int64 `json:"end_line"`
+}
+
+// BeragCacheEntry 一个文件在某次查询下的提取结果，Hash为提取时文件内容的哈希
+type BeragCacheEntry struct {
+	Path     string           `json:"path"`
+	Hash     string           `json:"hash"`
+	Keywords []string         `json:"keywords"`
+	Summary  string           `json:"summary"`
+	Items    []BeragCacheItem `json:"items"`
+	LastUsed int64            `json:"last_used"`
+}
+
+// BeragCache 保存在工作区中的berag提取结果，文件内容变化后对应的结果自动失效。
+// 条目数或者文件大小超过限制时淘汰最久没有使用的条目
+type BeragCache struct {
+	mu         sync.Mutex
+	path       string
+	maxEntries int
+	maxBytes   int
+	loaded     bool
+	dirty      bool // 内存中有还没有保存的改动，例如命中时更新的LastUsed
+	entries    []*BeragCacheEntry
+}
+
+func NewBeragCache(path string, maxEntries int, maxBytes int) *BeragCache {
+	return &BeragCache{path: path, maxEntries: maxEntries, maxBytes: maxBytes}
+}
+
+func (c *BeragCache) load() {
+	if c.loaded {
+		return
+	}
+	c.loaded = true
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return
+	}
+	// 文件损坏时当作空缓存
+	if json.Unmarshal(data, &c.entries) != nil {
+		c.entries = nil
+	}
+}
+
+func (c *BeragCache) save() error {
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// Flush 保存内存中还没有写入文件的改动，一次berag结束时调用，避免每次命中都重写整个文件
+func (c *BeragCache) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+	return c.save()
+}
+
+// dropStale 删除文件内容已经变化的条目
+func (c *BeragCache) dropStale(path string, hash string) {
+	c.entries = slices.DeleteFunc(c.entries, func(e *BeragCacheEntry) bool {
+		return e.Path == path && e.Hash != hash
+	})
+}
+
+// Lookup 查找文件当前内容下的提取结果。关键词足够接近时返回可以直接复用的结果，
+// 否则返回最近使用的结果，它的总结可以作为提示提供给子agent。改动只保存在内存中，由Put或者Flush写入文件
+func (c *BeragCache) Lookup(path string, hash string, keywords []string) (*BeragCacheEntry, *BeragCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	path = filepath.ToSlash(filepath.Clean(path))
+	count := len(c.entries)
+	c.dropStale(path, hash)
+	var best, recent *BeragCacheEntry
+	bestScore := 0.0
+	for _, e := range c.entries {
+		if e.Path != path {
+			continue
+		}
+		if score := KeywordScore(e.Keywords, keywords); score >= BERAG_CACHE_REUSE_SCORE && score > bestScore {
+			best, bestScore = e, score
+		}
+		if recent == nil || e.LastUsed > recent.LastUsed {
+			recent = e
+		}
+	}
+	if len(c.entries) != count {
+		c.dirty = true
+	}
+	if best != nil {
+		best.LastUsed = time.Now().Unix()
+		c.dirty = true
+		return best, nil
+	}
+	return nil, recent
+}
+
+// Put 记录提取结果，相同文件内容和关键词的旧结果会被替换，Flush时才写入文件
+func (c *BeragCache) Put(entry *BeragCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	entry.Path = filepath.ToSlash(filepath.Clean(entry.Path))
+	entry.LastUsed = time.Now().Unix()
+	c.dropStale(entry.Path, entry.Hash)
+	c.entries = slices.DeleteFunc(c.entries, func(e *BeragCacheEntry) bool {
+		return e.Path == entry.Path && slices.Equal(e.Keywords, entry.Keywords)
+	})
+	c.entries = append(c.entries, entry)
+	c.evict()
+	c.dirty = true
+}
+
+// evict 按最后使用时间淘汰条目，直到条目数和序列化后的大小都不超过限制。
+// 条目按使用时间从旧到新排列，时间相同时保持加入的顺序
+func (c *BeragCache) evict() {
+	sort.SliceStable(c.entries, func(i, j int) bool {
+		return c.entries[i].LastUsed < c.entries[j].LastUsed
+	})
+	size := 2
+	for i := len(c.entries) - 1; i >= 0; i-- {
+		data, _ := json.Marshal(c.entries[i])
+		size += len(data) + 1
+		kept := len(c.entries) - 1 - i
+		if (c.maxEntries > 0 && kept >= c.maxEntries) || (c.maxBytes > 0 && size > c.maxBytes) {
+			c.entries = c.entries[i+1:]
+			return
+		}
+	}
+}
+
+// Hints 返回内容没有变化并且和查询相关的文件的总结，每个文件只保留和查询最接近的一条，按相似度和使用时间排序
+func (c *BeragCache) Hints(keywords []string, limit int) []*BeragCacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	best := map[string]*BeragCacheEntry{}
+	hashes := map[string]string{}
+	count := len(c.entries)
+	c.entries = slices.DeleteFunc(c.entries, func(e *BeragCacheEntry) bool {
+		hash, ok := hashes[e.Path]
+		if !ok {
+			hash = FileHash(e.Path)
+			hashes[e.Path] = hash
+		}
+		return hash != e.Hash
+	})
+	if len(c.entries) != count {
+		c.dirty = true
+	}
+	for _, e := range c.entries {
+		// 和查询没有共同关键词的总结对本次查询没有帮助
+		if e.Summary == "" || KeywordScore(e.Keywords, keywords) <= 0 {
+			continue
+		}
+		if old := best[e.Path]; old == nil || KeywordScore(e.Keywords, keywords) > KeywordScore(old.Keywords, keywords) {
+			best[e.Path] = e
+		}
+	}
+	var hints []*BeragCacheEntry
+	for _, e := range best {
+		hints = append(hints, e)
+	}
+	sort.Slice(hints, func(i, j int) bool {
+		si, sj := KeywordScore(hints[i].Keywords, keywords), KeywordScore(hints[j].Keywords, keywords)
+		if si != sj {
+			return si > sj
+		}
+		if hints[i].LastUsed != hints[j].LastUsed {
+			return hints[i].LastUsed > hints[j].LastUsed
+		}
+		return hints[i].Path < hints[j].Path
+	})
+	if len(hints) > limit {
+		hints = hints[:limit]
+	}
+	return hints
+}
+
+// Stats 缓存的条目数和文件大小
+func (c *BeragCache) Stats() (int, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	var size int64
+	if info, err := os.Stat(c.path); err == nil {
+		size = info.Size()
+	}
+	return len(c.entries), size
+}
+
+func (c *BeragCache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+	c.loaded = true
+	c.dirty = false
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
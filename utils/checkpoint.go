@@ -235,3 +235,33 @@ func GetWorkspaceStorePath() string {
 	return path
 
 }
+
+// ChangedFiles 返回工作区相对于指定commit改动过的文件，路径相对于工作区
+func (c *Checkpoint) ChangedFiles(hash string) ([]string, error) {
+	shadowRepo := c.shadowRepoPath
+	c.changedotgitFileName()
+	defer c.revertotgitFileName()
+
+	// 暂存当前工作区，这样新建的文件也能比较
+	cmd := exec.Command("git", "add", "-A")
+	cmd.Dir = shadowRepo
+	cmd.Env = os.Environ()
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, locales.Errorf("fail to add all changes: %w, stderr output: %s", err, string(output))
+	}
+
+	cmd = exec.Command("git", "diff", "--cached", "--name-only", "-z", hash)
+	cmd.Dir = shadowRepo
+	cmd.Env = os.Environ()
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, locales.Errorf("fail to diff with commit: %w", err)
+	}
+	var files []string
+	for _, file := range strings.Split(string(out), "\x00") {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
@@ -24,6 +24,28 @@ var cmdsuggestions = []CompletionItem{
 	{Text: "/clear", Description: locales.Sprintf("clear everthing. start a new session")},
 	{Text: "/model", Description: locales.Sprintf("switch model")},
 	{Text: "/compact", Description: locales.Sprintf("compact the context")},
+	{Text: "/mcp", Description: locales.Sprintf("show mcp servers, /mcp enable|disable <server> to toggle one")},
+	{Text: "/commit", Description: locales.Sprintf("write a commit message for the staged changes")},
+	{Text: "/review", Description: locales.Sprintf("review changes, /review [ref|--staged|path]")},
+	{Text: "/berag-cache", Description: locales.Sprintf("show berag cache size, /berag-cache clear to clear it")},
+	{Text: "/init", Description: locales.Sprintf("generate or refresh the project overview, /init force to regenerate all sections")},
+}
+
+// RegisterCmdSuggestion 注册一个命令的补全提示，已存在的命令会被忽略
+func RegisterCmdSuggestion(cmd string, description string) {
+	for _, s := range cmdsuggestions {
+		if s.Text == cmd {
+			return
+		}
+	}
+	cmdsuggestions = append(cmdsuggestions, CompletionItem{Text: cmd, Description: description})
+}
+
+// GetCmdSuggestions 返回所有命令及其说明，包括自定义模式和自定义命令
+func GetCmdSuggestions() []CompletionItem {
+	items := make([]CompletionItem, len(cmdsuggestions))
+	copy(items, cmdsuggestions)
+	return items
 }
 
 func getCompletion(prefix string, whole string) string {
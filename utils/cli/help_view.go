@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"bergo/locales"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// HelpEntry 帮助页面中的一条说明
+type HelpEntry struct {
+	Name        string
+	Description string
+}
+
+// HelpSection 帮助页面中的一个分类，Key用于 /help <topic> 直接跳转
+type HelpSection struct {
+	Key     string
+	Title   string
+	Intro   string
+	Entries []HelpEntry
+}
+
+type HelpViewModel struct {
+	sections []*HelpSection
+	current  int
+	viewport viewport.Model
+	width    int
+	height   int
+}
+
+var (
+	helpTabStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("241")).PaddingLeft(1).PaddingRight(1)
+	helpActiveTabStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("170")).Background(lipgloss.Color("236")).Bold(true).PaddingLeft(1).PaddingRight(1)
+	helpNameStyle      = lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Dark: "#A78BFA", Light: "#7C3AED"}).Bold(true)
+	helpMutedStyle     = lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Dark: "#9CA3AF", Light: "#6B7280"})
+)
+
+func NewHelpView(sections []*HelpSection, current int) *HelpViewModel {
+	if current < 0 || current >= len(sections) {
+		current = 0
+	}
+	return &HelpViewModel{
+		sections: sections,
+		current:  current,
+		viewport: viewport.New(80, 20),
+		width:    80,
+		height:   24,
+	}
+}
+
+// FindHelpSection 根据topic查找分类，匹配Key或者标题的前缀，找不到时返回-1
+func FindHelpSection(sections []*HelpSection, topic string) int {
+	topic = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(topic), "/")))
+	if topic == "" {
+		return -1
+	}
+	for i, section := range sections {
+		if strings.ToLower(section.Key) == topic {
+			return i
+		}
+	}
+	for i, section := range sections {
+		if strings.HasPrefix(strings.ToLower(section.Key), topic) || strings.HasPrefix(strings.ToLower(section.Title), topic) {
+			return i
+		}
+	}
+	return -1
+}
+
+// RenderHelpSection 把分类渲染为文本，名称列对齐，说明按宽度折行
+func RenderHelpSection(section *HelpSection, width int) string {
+	var b strings.Builder
+	if section.Intro != "" {
+		b.WriteString(lipgloss.NewStyle().Width(width).Render(section.Intro))
+		b.WriteString("\n\n")
+	}
+	nameWidth := 0
+	for _, entry := range section.Entries {
+		nameWidth = max(nameWidth, lipgloss.Width(entry.Name))
+	}
+	nameWidth = min(nameWidth, width/3)
+	descWidth := max(width-nameWidth-4, 20)
+	for _, entry := range section.Entries {
+		name := helpNameStyle.Width(nameWidth).Render(entry.Name)
+		desc := lipgloss.NewStyle().Width(descWidth).Render(entry.Description)
+		b.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, "  ", name, "  ", desc))
+		b.WriteString("\n")
+	}
+	if len(section.Entries) == 0 && section.Intro == "" {
+		b.WriteString(helpMutedStyle.Render(locales.Sprintf("nothing here yet")))
+	}
+	return b.String()
+}
+
+func (m HelpViewModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *HelpViewModel) switchTo(index int) {
+	if len(m.sections) == 0 {
+		return
+	}
+	m.current = (index + len(m.sections)) % len(m.sections)
+	m.viewport.SetContent(RenderHelpSection(m.sections[m.current], m.viewport.Width))
+	m.viewport.GotoTop()
+}
+
+func (m HelpViewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.viewport.Width = max(msg.Width-2, 20)
+		m.viewport.Height = max(msg.Height-lipgloss.Height(m.tabsView())-3, 3)
+		m.switchTo(m.current)
+		return m, nil
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "esc", "q":
+			return m, tea.Quit
+		case "right", "l", "tab":
+			m.switchTo(m.current + 1)
+			return m, nil
+		case "left", "h", "shift+tab":
+			m.switchTo(m.current - 1)
+			return m, nil
+		}
+		if len(msg.Runes) == 1 && msg.Runes[0] >= '1' && msg.Runes[0] <= '9' {
+			if idx := int(msg.Runes[0] - '1'); idx < len(m.sections) {
+				m.switchTo(idx)
+			}
+			return m, nil
+		}
+	}
+	var cmd tea.Cmd
+	m.viewport, cmd = m.viewport.Update(msg)
+	return m, cmd
+}
+
+func (m HelpViewModel) tabsView() string {
+	var tabs []string
+	for i, section := range m.sections {
+		title := fmt.Sprintf("%d.%s", i+1, section.Title)
+		if i == m.current {
+			tabs = append(tabs, helpActiveTabStyle.Render(title))
+		} else {
+			tabs = append(tabs, helpTabStyle.Render(title))
+		}
+	}
+	return lipgloss.NewStyle().Width(m.width).Render(strings.Join(tabs, " "))
+}
+
+func (m HelpViewModel) View() string {
+	if len(m.sections) == 0 {
+		return ""
+	}
+	footer := helpMutedStyle.Render(locales.Sprintf("←/→ or Tab to switch section, 1-9 to jump, ↑/↓ PgUp/PgDn to scroll, ESC or q to quit"))
+	return lipgloss.JoinVertical(lipgloss.Left, m.tabsView(), "", m.viewport.View(), footer)
+}
+
+// Show 启动帮助页面，直到用户退出
+func (m *HelpViewModel) Show() {
+	if len(m.sections) == 0 {
+		return
+	}
+	m.switchTo(m.current)
+	p := tea.NewProgram(m, tea.WithAltScreen())
+	p.Run()
+}
@@ -52,18 +52,22 @@ func (m streamingWindow) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.viewport.Height = 0
 			return m, tea.Quit
 		}
+		// 任务执行中的输入作为引导消息，不传给viewport
+		if steerKey(msg.String(), msg.Runes) {
+			return m, nil
+		}
 	case tea.WindowSizeMsg:
 		m.height = msg.Height
 		if !m.ready {
 			m.ready = true
 			m.viewport.SetContent(m.content)
-			m.viewport.Height = min(m.height, countLines(m.content))
+			m.viewport.Height = min(max(m.height-1, 0), countLines(m.content))
 			m.viewport.GotoBottom()
 		}
 	case contentUpdateMsg:
 		m.content = msg.content
 		m.viewport.SetContent(m.content)
-		m.viewport.Height = min(m.height, countLines(m.content))
+		m.viewport.Height = min(max(m.height-1, 0), countLines(m.content))
 		m.viewport.GotoBottom()
 	case clearMsg:
 		m.viewport.SetContent("")
@@ -81,7 +85,8 @@ func (m streamingWindow) View() string {
 	if m.clear {
 		return ""
 	}
-	return m.viewport.View()
+	// 底部留一行给引导输入
+	return m.viewport.View() + "\n" + steerLine()
 }
 
 const ()
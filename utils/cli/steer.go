@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"bergo/locales"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// 任务执行过程中用户输入的引导消息，在下一次调用llm前注入
+var steer = struct {
+	sync.Mutex
+	queue []string
+	draft []rune
+}{}
+
+// PushSteer 把一条引导消息加入队列
+func PushSteer(msg string) {
+	msg = strings.TrimSpace(msg)
+	if msg == "" {
+		return
+	}
+	steer.Lock()
+	defer steer.Unlock()
+	steer.queue = append(steer.queue, msg)
+}
+
+// PopSteers 取出并清空队列中的所有引导消息
+func PopSteers() []string {
+	steer.Lock()
+	defer steer.Unlock()
+	msgs := steer.queue
+	steer.queue = nil
+	return msgs
+}
+
+// ClearSteer 清空队列和未发送的输入，任务结束时调用
+func ClearSteer() {
+	steer.Lock()
+	defer steer.Unlock()
+	steer.queue = nil
+	steer.draft = nil
+}
+
+// steerKey 处理流式输出窗口中的按键，返回是否消费了该按键
+func steerKey(key string, runes []rune) bool {
+	steer.Lock()
+	defer steer.Unlock()
+	switch key {
+	case "enter":
+		if msg := strings.TrimSpace(string(steer.draft)); msg != "" {
+			steer.queue = append(steer.queue, msg)
+		}
+		steer.draft = nil
+		return true
+	case "backspace":
+		if len(steer.draft) > 0 {
+			steer.draft = steer.draft[:len(steer.draft)-1]
+		}
+		return true
+	case " ":
+		steer.draft = append(steer.draft, ' ')
+		return true
+	}
+	if len(runes) > 0 {
+		steer.draft = append(steer.draft, runes...)
+		return true
+	}
+	return false
+}
+
+// steerLine 渲染流式输出窗口底部的引导输入行
+func steerLine() string {
+	steer.Lock()
+	defer steer.Unlock()
+	mutedColor := lipgloss.AdaptiveColor{Dark: "#9CA3AF", Light: "#6B7280"}
+	accentColor := lipgloss.AdaptiveColor{Dark: "#87ff00", Light: "#409C07"}
+	line := ""
+	if len(steer.draft) == 0 {
+		line = lipgloss.NewStyle().Foreground(mutedColor).Faint(true).Render(locales.Sprintf("✍ type to steer the running task, enter to send"))
+	} else {
+		line = lipgloss.NewStyle().Foreground(accentColor).Render("✍ " + string(steer.draft) + "█")
+	}
+	if len(steer.queue) > 0 {
+		line += lipgloss.NewStyle().Foreground(mutedColor).Render(locales.Sprintf("  (%d queued)", len(steer.queue)))
+	}
+	return line
+}
+
+// 工具执行期间没有流式输出窗口，由这个窗口读取按键，输出或者其他终端交互开始前暂停
+var steerCapture = struct {
+	sync.Mutex
+	program *tea.Program
+	done    chan struct{}
+	active  bool
+	paused  int
+}{}
+
+type steerQuitMsg struct{}
+
+type steerWindow struct {
+	done bool
+}
+
+func (m steerWindow) Init() tea.Cmd {
+	return nil
+}
+
+func (m steerWindow) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if msg.String() == tea.KeyCtrlC.String() {
+			if cancelFunc != nil {
+				cancelFunc()
+			}
+			return m, nil
+		}
+		steerKey(msg.String(), msg.Runes)
+	case steerQuitMsg:
+		m.done = true
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+func (m steerWindow) View() string {
+	if m.done {
+		return ""
+	}
+	return steerLine()
+}
+
+// StartSteerCapture 开始在工具执行期间读取引导输入
+func StartSteerCapture() {
+	steerCapture.Lock()
+	defer steerCapture.Unlock()
+	steerCapture.active = true
+	startSteerProgram()
+}
+
+// StopSteerCapture 停止读取引导输入，已经回车的消息保留在队列中
+func StopSteerCapture() {
+	steerCapture.Lock()
+	defer steerCapture.Unlock()
+	steerCapture.active = false
+	stopSteerProgram()
+}
+
+// PauseSteerCapture 暂停读取引导输入，把终端交给其他输出或者输入，返回恢复的函数
+func PauseSteerCapture() func() {
+	steerCapture.Lock()
+	defer steerCapture.Unlock()
+	steerCapture.paused++
+	stopSteerProgram()
+	once := sync.Once{}
+	return func() {
+		once.Do(func() {
+			steerCapture.Lock()
+			defer steerCapture.Unlock()
+			steerCapture.paused--
+			startSteerProgram()
+		})
+	}
+}
+
+func startSteerProgram() {
+	if !steerCapture.active || steerCapture.paused > 0 || steerCapture.program != nil {
+		return
+	}
+	program := tea.NewProgram(steerWindow{})
+	done := make(chan struct{})
+	steerCapture.program, steerCapture.done = program, done
+	go func() {
+		defer close(done)
+		program.Run()
+	}()
+}
+
+func stopSteerProgram() {
+	if steerCapture.program == nil {
+		return
+	}
+	steerCapture.program.Send(steerQuitMsg{})
+	<-steerCapture.done
+	steerCapture.program, steerCapture.done = nil, nil
+}
@@ -456,6 +456,7 @@ type InputOptions struct {
 	Multiline      bool
 	SessionId      string
 	TimelineBranch string
+	TodoList       *utils.TodoList
 }
 
 func (options *InputOptions) String() string {
@@ -527,5 +528,10 @@ func (options *InputOptions) String() string {
 		BorderForeground(primaryColor).
 		Padding(0, 1)
 
-	return title + "\n" + boxStyle.Render(content) + "\n"
+	// 还有未完成的todo时，在状态栏上方展示任务清单
+	todo := ""
+	if options.TodoList != nil && options.TodoList.HasUnfinished() {
+		todo = utils.TodoListStyle(options.TodoList)
+	}
+	return todo + title + "\n" + boxStyle.Render(content) + "\n"
 }
@@ -0,0 +1,420 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	CODE_INDEX_FILE = ".bergo/code_index.gob"
+	// 超过该大小的文件不建索引
+	CODE_INDEX_MAX_FILE_SIZE = 1 << 20
+	// 路径中的词在词频中的权重
+	CODE_INDEX_PATH_WEIGHT = 3
+	// 每个结果最多返回的匹配行数
+	CODE_INDEX_SNIPPET_LINE = 3
+	// 匹配行保留的字符数
+	CODE_INDEX_SNIPPET_LENGTH = 160
+
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// 不建索引的目录，和文件监听保持一致
+var codeIndexSkipDirs = []string{"node_modules", "vendor", "__pycache__", "dist", "build", "target"}
+
+var codeTokenRegex = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*|\p{Han}+`)
+
+// splitIdentifier 按下划线、驼峰和数字切分标识符，例如 parseHTTPRequest_v2 切分为 parse、HTTP、Request、v、2
+func splitIdentifier(word string) []string {
+	var parts []string
+	for _, segment := range strings.Split(word, "_") {
+		runes := []rune(segment)
+		start := 0
+		for i := 1; i < len(runes); i++ {
+			prev, cur := runes[i-1], runes[i]
+			boundary := (unicode.IsLower(prev) && unicode.IsUpper(cur)) ||
+				(unicode.IsLetter(prev) && unicode.IsDigit(cur)) ||
+				(unicode.IsDigit(prev) && unicode.IsLetter(cur)) ||
+				// HTTPServer 在 P 和 S 之间切分
+				(unicode.IsUpper(prev) && unicode.IsUpper(cur) && i+1 < len(runes) && unicode.IsLower(runes[i+1]))
+			if boundary {
+				parts = append(parts, string(runes[start:i]))
+				start = i
+			}
+		}
+		if start < len(runes) {
+			parts = append(parts, string(runes[start:]))
+		}
+	}
+	return parts
+}
+
+// CodeTokens 代码感知的分词：标识符保留完整的小写形式，同时按驼峰和下划线切分，中文按两个字切分
+func CodeTokens(text string) []string {
+	var tokens []string
+	for _, word := range codeTokenRegex.FindAllString(text, -1) {
+		runes := []rune(word)
+		if runes[0] > unicode.MaxASCII {
+			if len(runes) == 1 {
+				tokens = append(tokens, word)
+			}
+			for i := 0; i+1 < len(runes); i++ {
+				tokens = append(tokens, string(runes[i:i+2]))
+			}
+			continue
+		}
+		lower := strings.ToLower(word)
+		if len(lower) < 2 {
+			continue
+		}
+		tokens = append(tokens, lower)
+		parts := splitIdentifier(word)
+		if len(parts) < 2 {
+			continue
+		}
+		for _, part := range parts {
+			part = strings.ToLower(part)
+			if len(part) >= 2 && part != lower && !isDigits(part) {
+				tokens = append(tokens, part)
+			}
+		}
+	}
+	return tokens
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// indexedFile 一个文件的词频，通过修改时间和大小判断是否需要重新建索引
+type indexedFile struct {
+	ModTime int64
+	Size    int64
+	Length  int
+	Terms   map[string]int
+}
+
+// CodeSearchResult 检索结果，Lines为包含查询词的行
+type CodeSearchResult struct {
+	Path  string
+	Score float64
+	Lines []CodeSearchLine
+}
+
+type CodeSearchLine struct {
+	Line int
+	Text string
+}
+
+// CodeIndex 工作区的BM25倒排索引，增量更新并保存在.bergo中
+type CodeIndex struct {
+	mu        sync.Mutex
+	root      string
+	indexPath string
+	loaded    bool
+	files     map[string]*indexedFile
+	postings  map[string]map[string]int // 词 -> 文件 -> 词频
+	totalLen  int
+}
+
+func NewCodeIndex(root string, indexPath string) *CodeIndex {
+	return &CodeIndex{root: root, indexPath: indexPath, files: map[string]*indexedFile{}}
+}
+
+func (idx *CodeIndex) load() {
+	if idx.loaded {
+		return
+	}
+	idx.loaded = true
+	file, err := os.Open(idx.indexPath)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	files := map[string]*indexedFile{}
+	// 索引损坏时重新建立
+	if gob.NewDecoder(bufio.NewReader(file)).Decode(&files) == nil {
+		idx.files = files
+	}
+}
+
+func (idx *CodeIndex) save() error {
+	if err := os.MkdirAll(filepath.Dir(idx.indexPath), 0755); err != nil {
+		return err
+	}
+	tmp := idx.indexPath + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	if err := gob.NewEncoder(writer).Encode(idx.files); err != nil {
+		file.Close()
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, idx.indexPath)
+}
+
+// Update 遍历工作区，只对新增和改动过的文件重新分词，返回变化的文件数。
+// 跳过隐藏目录、.gitignore和.bergoignore忽略的文件、二进制文件和过大的文件
+func (idx *CodeIndex) Update() (int, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.load()
+	ignore := NewIgnore(idx.root, []string{".gitignore", ".bergoignore"})
+	seen := map[string]bool{}
+	changed := 0
+	err := filepath.WalkDir(idx.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(idx.root, path)
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			return nil
+		}
+		if d.IsDir() {
+			if strings.HasPrefix(d.Name(), ".") || slices.Contains(codeIndexSkipDirs, d.Name()) || ignore.MatchesPath(rel+"/") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".") || ignore.MatchesPath(rel) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() > CODE_INDEX_MAX_FILE_SIZE {
+			return nil
+		}
+		seen[rel] = true
+		if old := idx.files[rel]; old != nil && old.ModTime == info.ModTime().UnixNano() && old.Size == info.Size() {
+			return nil
+		}
+		if binary, err := IsBinaryFile(path); err != nil || binary {
+			delete(seen, rel)
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			delete(seen, rel)
+			return nil
+		}
+		idx.files[rel] = indexFile(rel, string(content), info)
+		changed++
+		return nil
+	})
+	if err != nil {
+		return changed, err
+	}
+	for path := range idx.files {
+		if !seen[path] {
+			delete(idx.files, path)
+			changed++
+		}
+	}
+	if changed > 0 || idx.postings == nil {
+		idx.buildPostings()
+	}
+	if changed > 0 {
+		return changed, idx.save()
+	}
+	return 0, nil
+}
+
+func indexFile(path string, content string, info fs.FileInfo) *indexedFile {
+	file := &indexedFile{ModTime: info.ModTime().UnixNano(), Size: info.Size(), Terms: map[string]int{}}
+	for _, token := range CodeTokens(content) {
+		file.Terms[token]++
+		file.Length++
+	}
+	// 路径中的词，例如 utils/code_index.go 中的 utils、code、index
+	for _, token := range CodeTokens(strings.NewReplacer("/", " ", ".", " ", "-", " ").Replace(path)) {
+		file.Terms[token] += CODE_INDEX_PATH_WEIGHT
+		file.Length += CODE_INDEX_PATH_WEIGHT
+	}
+	return file
+}
+
+func (idx *CodeIndex) buildPostings() {
+	idx.postings = map[string]map[string]int{}
+	idx.totalLen = 0
+	for path, file := range idx.files {
+		idx.totalLen += file.Length
+		for term, tf := range file.Terms {
+			if idx.postings[term] == nil {
+				idx.postings[term] = map[string]int{}
+			}
+			idx.postings[term][path] = tf
+		}
+	}
+}
+
+// Len 已建索引的文件数
+func (idx *CodeIndex) Len() int {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return len(idx.files)
+}
+
+// Search 按BM25排序返回最相关的文件，pathPrefix不为空时只在该路径下检索
+func (idx *CodeIndex) Search(query string, pathPrefix string, limit int) []*CodeSearchResult {
+	idx.mu.Lock()
+	terms := CodeTokens(query)
+	sort.Strings(terms)
+	terms = slices.Compact(terms)
+	pathPrefix = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(pathPrefix)), "./")
+	if pathPrefix == "." {
+		pathPrefix = ""
+	}
+	n := float64(len(idx.files))
+	avgLen := 1.0
+	if len(idx.files) > 0 && idx.totalLen > 0 {
+		avgLen = float64(idx.totalLen) / n
+	}
+	scores := map[string]float64{}
+	for _, term := range terms {
+		postings := idx.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for path, tf := range postings {
+			if pathPrefix != "" && path != pathPrefix && !strings.HasPrefix(path, pathPrefix+"/") {
+				continue
+			}
+			length := float64(idx.files[path].Length)
+			freq := float64(tf)
+			scores[path] += idf * freq * (bm25K1 + 1) / (freq + bm25K1*(1-bm25B+bm25B*length/avgLen))
+		}
+	}
+	idx.mu.Unlock()
+	var results []*CodeSearchResult
+	for path, score := range scores {
+		results = append(results, &CodeSearchResult{Path: path, Score: score})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Path < results[j].Path
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	for _, result := range results {
+		result.Lines = idx.matchLines(result.Path, terms)
+	}
+	return results
+}
+
+// matchLines 找出文件中包含查询词的行，优先返回包含查询词最多的行
+func (idx *CodeIndex) matchLines(path string, terms []string) []CodeSearchLine {
+	content, err := os.ReadFile(filepath.Join(idx.root, path))
+	if err != nil {
+		return nil
+	}
+	type match struct {
+		line  CodeSearchLine
+		count int
+	}
+	var matches []match
+	for i, line := range strings.Split(string(content), "\n") {
+		count := 0
+		lineTerms := CodeTokens(line)
+		for _, term := range terms {
+			if slices.Contains(lineTerms, term) {
+				count++
+			}
+		}
+		if count == 0 {
+			continue
+		}
+		text := strings.TrimSpace(line)
+		if runes := []rune(text); len(runes) > CODE_INDEX_SNIPPET_LENGTH {
+			text = string(runes[:CODE_INDEX_SNIPPET_LENGTH]) + "..."
+		}
+		matches = append(matches, match{line: CodeSearchLine{Line: i + 1, Text: text}, count: count})
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].count > matches[j].count
+	})
+	if len(matches) > CODE_INDEX_SNIPPET_LINE {
+		matches = matches[:CODE_INDEX_SNIPPET_LINE]
+	}
+	// 按行号输出
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].line.Line < matches[j].line.Line
+	})
+	var lines []CodeSearchLine
+	for _, m := range matches {
+		lines = append(lines, m.line)
+	}
+	return lines
+}
+
+// IndexedDir 按目录分组的已索引文件，Hash由文件路径、修改时间和大小计算，目录下有文件变化时改变
+type IndexedDir struct {
+	Path  string
+	Hash  string
+	Files []string
+}
+
+// Dirs 把已索引的文件按路径的前depth级目录分组，根目录下的文件属于"."，结果按路径排序
+func (idx *CodeIndex) Dirs(depth int) []*IndexedDir {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	groups := map[string]*IndexedDir{}
+	for path := range idx.files {
+		segments := strings.Split(path, "/")
+		dir := "."
+		if len(segments) > 1 {
+			dir = strings.Join(segments[:min(depth, len(segments)-1)], "/")
+		}
+		if groups[dir] == nil {
+			groups[dir] = &IndexedDir{Path: dir}
+		}
+		groups[dir].Files = append(groups[dir].Files, path)
+	}
+	var dirs []*IndexedDir
+	for _, dir := range groups {
+		sort.Strings(dir.Files)
+		hash := sha256.New()
+		for _, path := range dir.Files {
+			file := idx.files[path]
+			fmt.Fprintf(hash, "%s %d %d\n", path, file.ModTime, file.Size)
+		}
+		dir.Hash = hex.EncodeToString(hash.Sum(nil))[:16]
+		dirs = append(dirs, dir)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		return dirs[i].Path < dirs[j].Path
+	})
+	return dirs
+}
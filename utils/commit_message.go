@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// CommitProposal 模型给出的提交信息，Split不为空时表示建议拆分成多个提交
+type CommitProposal struct {
+	Message string         `json:"message"`
+	Split   []*CommitGroup `json:"split,omitempty"`
+}
+
+// CommitGroup 拆分后的一个提交，Files为该提交包含的已暂存文件
+type CommitGroup struct {
+	Message string   `json:"message"`
+	Files   []string `json:"files"`
+}
+
+// ParseCommitProposal 解析模型的回复，允许JSON外面包了代码块或说明文字，
+// 不是JSON时把整段回复当作提交信息
+func ParseCommitProposal(content string) (*CommitProposal, error) {
+	content = strings.TrimSpace(content)
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+	if start >= 0 && end > start {
+		proposal := &CommitProposal{}
+		if err := json.Unmarshal([]byte(content[start:end+1]), proposal); err == nil {
+			proposal.Message = strings.TrimSpace(proposal.Message)
+			if proposal.Message != "" {
+				return proposal, nil
+			}
+		}
+	}
+	content = strings.TrimSpace(strings.Trim(content, "`"))
+	if content == "" {
+		return nil, fmt.Errorf("empty commit message")
+	}
+	return &CommitProposal{Message: content}, nil
+}
+
+// ValidateCommitSplit 检查拆分是否可用：至少两个提交，每个文件都已暂存，
+// 不能同时出现在两个提交中，并且所有暂存的文件都要被提交
+func ValidateCommitSplit(groups []*CommitGroup, staged []string) error {
+	if len(groups) < 2 {
+		return fmt.Errorf("split needs at least 2 commits")
+	}
+	left := map[string]bool{}
+	for _, file := range staged {
+		left[file] = true
+	}
+	seen := map[string]bool{}
+	for _, group := range groups {
+		if strings.TrimSpace(group.Message) == "" || len(group.Files) == 0 {
+			return fmt.Errorf("every commit in split needs a message and files")
+		}
+		for _, file := range group.Files {
+			if seen[file] {
+				return fmt.Errorf("file %s appears in more than one commit", file)
+			}
+			if !left[file] {
+				return fmt.Errorf("file %s is not staged", file)
+			}
+			seen[file] = true
+			delete(left, file)
+		}
+	}
+	for file := range left {
+		return fmt.Errorf("file %s is not in any commit", file)
+	}
+	return nil
+}
+
+// Commit 提交已暂存的改动，返回新提交的短hash和标题
+func (g *Git) Commit(message string) (string, error) {
+	if _, err := g.Run("commit", "-q", "-m", message); err != nil {
+		return "", err
+	}
+	return g.Run("log", "-1", "--pretty=format:%h %s")
+}
+
+// CommitSplit 按分组依次提交已暂存的改动。每次提交前先把后面分组的文件取消暂存，
+// 提交后再从最初的暂存区恢复，这样只暂存了部分改动的文件也能保持原样
+func (g *Git) CommitSplit(groups []*CommitGroup) ([]string, error) {
+	tree, err := g.Run("write-tree")
+	if err != nil {
+		return nil, err
+	}
+	tree = strings.TrimSpace(tree)
+	var heads []string
+	for i, group := range groups {
+		var rest []string
+		for _, other := range groups[i+1:] {
+			rest = append(rest, other.Files...)
+		}
+		if len(rest) > 0 {
+			if _, err := g.Run(append([]string{"reset", "-q", "--"}, rest...)...); err != nil {
+				return heads, err
+			}
+		}
+		head, commitErr := g.Commit(strings.TrimSpace(group.Message))
+		// 提交失败时也要恢复暂存区，避免丢失用户暂存的改动
+		if len(rest) > 0 {
+			if _, err := g.Run(append([]string{"restore", "--staged", "--source=" + tree, "--"}, rest...)...); err != nil {
+				return heads, err
+			}
+		}
+		if commitErr != nil {
+			return heads, commitErr
+		}
+		heads = append(heads, head)
+	}
+	return heads, nil
+}
@@ -0,0 +1,331 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+/*
+格式感知的读取器，把pdf、notebook、压缩包等非纯文本文件转换成文本行，
+转换后的内容和普通文本文件一样按行号读取，可以配合begin和end分段阅读
+*/
+
+// 压缩包内单个文件读取的大小上限
+const ARCHIVE_ENTRY_MAX_SIZE = 10 * 1024 * 1024
+
+type docReader func(r *ReadFile) ([]string, error)
+
+func getDocReader(path string) docReader {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".pdf"):
+		return readPdf
+	case strings.HasSuffix(lower, ".ipynb"):
+		return readNotebook
+	case strings.HasSuffix(lower, ".zip"), strings.HasSuffix(lower, ".tar"),
+		strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return readArchive
+	}
+	return nil
+}
+
+// readDocLines 使用格式感知的读取器读取文件，返回的第二个值表示是否支持该格式
+func (r *ReadFile) readDocLines() ([]string, bool, error) {
+	reader := getDocReader(r.Path)
+	if reader == nil {
+		if r.Entry != "" {
+			return nil, true, fmt.Errorf("entry is only supported for zip/tar/tar.gz archives")
+		}
+		return nil, false, nil
+	}
+	if _, err := os.Stat(r.Path); err != nil {
+		return nil, true, err
+	}
+	lines, err := reader(r)
+	return lines, true, err
+}
+
+// readPdf 逐页提取pdf中的文字，每页以分隔行开头。pdf库遇到格式错误的文件会panic，这里转换为错误
+func readPdf(r *ReadFile) (result []string, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			result, err = nil, fmt.Errorf("parse pdf %s failed: %v", r.Path, rec)
+		}
+	}()
+	f, reader, err := pdf.Open(r.Path)
+	if err != nil {
+		return nil, fmt.Errorf("open pdf %s failed: %w", r.Path, err)
+	}
+	defer f.Close()
+	for i := 1; i <= reader.NumPage(); i++ {
+		result = append(result, fmt.Sprintf("--- page %d ---", i))
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		rows, err := page.GetTextByRow()
+		if err != nil {
+			result = append(result, fmt.Sprintf("(extract text of page %d failed: %v)", i, err))
+			continue
+		}
+		for _, row := range rows {
+			var line strings.Builder
+			for _, text := range row.Content {
+				line.WriteString(text.S)
+			}
+			if s := strings.TrimRight(line.String(), " "); s != "" {
+				result = append(result, s)
+			}
+		}
+	}
+	return result, nil
+}
+
+type notebook struct {
+	Cells []struct {
+		CellType string          `json:"cell_type"`
+		Source   json.RawMessage `json:"source"`
+		Outputs  []struct {
+			OutputType string                     `json:"output_type"`
+			Text       json.RawMessage            `json:"text"`
+			Data       map[string]json.RawMessage `json:"data"`
+			Ename      string                     `json:"ename"`
+			Evalue     string                     `json:"evalue"`
+			Traceback  []string                   `json:"traceback"`
+		} `json:"outputs"`
+	} `json:"cells"`
+}
+
+var notebookImageExt = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// notebookText notebook中的文本可能是字符串也可能是字符串数组
+func notebookText(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var arr []string
+	if json.Unmarshal(raw, &arr) == nil {
+		return strings.Join(arr, "")
+	}
+	return ""
+}
+
+func splitTextLines(s string) []string {
+	s = strings.TrimRight(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// readNotebook 把notebook渲染为cell列表，图片输出保存到磁盘，可以用read_img查看
+func readNotebook(r *ReadFile) ([]string, error) {
+	data, err := os.ReadFile(r.Path)
+	if err != nil {
+		return nil, err
+	}
+	nb := notebook{}
+	if err := json.Unmarshal(data, &nb); err != nil {
+		return nil, fmt.Errorf("parse notebook %s failed: %w", r.Path, err)
+	}
+	imageDir := r.ImageDir
+	if imageDir == "" {
+		imageDir = filepath.Join(GetWorkspaceStorePath(), "notebook_images")
+	}
+	pathHash := sha256.Sum256([]byte(r.Path))
+	prefix := hex.EncodeToString(pathHash[:])[:12]
+
+	var result []string
+	for i, cell := range nb.Cells {
+		result = append(result, fmt.Sprintf("--- cell %d (%s) ---", i+1, cell.CellType))
+		result = append(result, splitTextLines(notebookText(cell.Source))...)
+		if len(cell.Outputs) == 0 {
+			continue
+		}
+		result = append(result, "--- output ---")
+		for j, output := range cell.Outputs {
+			switch output.OutputType {
+			case "stream":
+				result = append(result, splitTextLines(notebookText(output.Text))...)
+			case "error":
+				result = append(result, fmt.Sprintf("%s: %s", output.Ename, output.Evalue))
+				for _, line := range output.Traceback {
+					result = append(result, splitTextLines(ansiEscape.ReplaceAllString(line, ""))...)
+				}
+			default:
+				// execute_result / display_data，优先保存图片，否则输出纯文本
+				saved := false
+				for mime, ext := range notebookImageExt {
+					raw, ok := output.Data[mime]
+					if !ok {
+						continue
+					}
+					imgPath := filepath.Join(imageDir, fmt.Sprintf("%s_cell%d_%d%s", prefix, i+1, j+1, ext))
+					if err := saveNotebookImage(notebookText(raw), imgPath); err != nil {
+						result = append(result, fmt.Sprintf("[image output: save failed: %v]", err))
+					} else {
+						result = append(result, fmt.Sprintf("[image output saved to %s, use read_img to view it]", imgPath))
+					}
+					saved = true
+					break
+				}
+				if !saved {
+					result = append(result, splitTextLines(notebookText(output.Data["text/plain"]))...)
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+func saveNotebookImage(b64 string, path string) error {
+	// notebook中的base64可能带换行
+	content, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(b64), ""))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0644)
+}
+
+type archiveEntry struct {
+	Name  string
+	Size  int64
+	IsDir bool
+	open  func() (io.ReadCloser, error)
+}
+
+// readArchive 没有指定entry时列出压缩包内容，指定entry时读取对应文件的文本内容
+func readArchive(r *ReadFile) ([]string, error) {
+	var result []string
+	entry := strings.TrimPrefix(r.Entry, "./")
+	err := walkArchive(r.Path, func(e *archiveEntry) (bool, error) {
+		if entry == "" {
+			if e.IsDir {
+				result = append(result, e.Name)
+			} else {
+				result = append(result, fmt.Sprintf("%s\t%d bytes", e.Name, e.Size))
+			}
+			return false, nil
+		}
+		if e.IsDir || strings.TrimPrefix(e.Name, "./") != entry {
+			return false, nil
+		}
+		if e.Size > ARCHIVE_ENTRY_MAX_SIZE {
+			return true, fmt.Errorf("entry %s is too large (%d bytes)", entry, e.Size)
+		}
+		rc, err := e.open()
+		if err != nil {
+			return true, err
+		}
+		defer rc.Close()
+		content, err := io.ReadAll(io.LimitReader(rc, ARCHIVE_ENTRY_MAX_SIZE))
+		if err != nil {
+			return true, err
+		}
+		if bytes.IndexByte(content[:min(len(content), 8192)], 0) >= 0 {
+			return true, fmt.Errorf("entry %s is a binary file", entry)
+		}
+		result = strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if entry != "" && result == nil {
+		return nil, fmt.Errorf("entry %s not found in %s", entry, r.Path)
+	}
+	if entry == "" {
+		sort.Strings(result)
+		result = append([]string{fmt.Sprintf("archive %s, %d entries, use entry param to read a file inside", r.Path, len(result))}, result...)
+	}
+	return result, nil
+}
+
+// walkArchive 遍历压缩包内的条目，fn返回true时停止遍历
+func walkArchive(path string, fn func(e *archiveEntry) (bool, error)) error {
+	lower := strings.ToLower(path)
+	if strings.HasSuffix(lower, ".zip") {
+		zr, err := zip.OpenReader(path)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		for _, f := range zr.File {
+			stop, err := fn(&archiveEntry{
+				Name:  f.Name,
+				Size:  int64(f.UncompressedSize64),
+				IsDir: f.FileInfo().IsDir(),
+				open:  f.Open,
+			})
+			if err != nil || stop {
+				return err
+			}
+		}
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var reader io.Reader = file
+	if strings.HasSuffix(lower, ".gz") || strings.HasSuffix(lower, ".tgz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		stop, err := fn(&archiveEntry{
+			Name:  header.Name,
+			Size:  header.Size,
+			IsDir: header.Typeflag == tar.TypeDir,
+			open: func() (io.ReadCloser, error) {
+				return io.NopCloser(tr), nil
+			},
+		})
+		if err != nil || stop {
+			return err
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// 记录bergo最后一次写入文件后的内容哈希
+// 格式化工具等外部程序可能改写文件内容，记录下来用于判断模型看到的内容是否已经过期
+var fileHashes = struct {
+	sync.Mutex
+	m map[string]string
+}{m: make(map[string]string)}
+
+// FileHash 计算文件内容的sha256哈希，文件不存在时返回空字符串
+func FileHash(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:])
+}
+
+// RecordFileHash 记录文件当前内容的哈希并返回
+func RecordFileHash(path string) string {
+	hash := FileHash(path)
+	fileHashes.Lock()
+	defer fileHashes.Unlock()
+	fileHashes.m[hashKey(path)] = hash
+	return hash
+}
+
+// GetRecordedFileHash 获取最后一次记录的文件哈希，没有记录时返回空字符串
+func GetRecordedFileHash(path string) string {
+	fileHashes.Lock()
+	defer fileHashes.Unlock()
+	return fileHashes.m[hashKey(path)]
+}
+
+func hashKey(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	return abs
+}
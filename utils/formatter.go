@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"bergo/config"
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	FORMATTER_TIMEOUT = 60 * time.Second
+	// 格式化命令中代表目标文件的占位符，没有占位符时文件路径追加在命令末尾
+	FORMATTER_FILE_PLACEHOLDER = "{file}"
+)
+
+type Formatter struct {
+	Path string
+}
+
+// Command 查找文件对应的格式化命令
+// 优先匹配扩展名(.go / go)，其次匹配 GetLangByExt 得到的语言名(python)
+func (f *Formatter) Command() string {
+	if config.GlobalConfig == nil || len(config.GlobalConfig.Formatters) == 0 {
+		return ""
+	}
+	formatters := config.GlobalConfig.Formatters
+	ext := strings.ToLower(filepath.Ext(f.Path))
+	if ext != "" {
+		if cmd, ok := formatters[ext]; ok {
+			return cmd
+		}
+		if cmd, ok := formatters[strings.TrimPrefix(ext, ".")]; ok {
+			return cmd
+		}
+	}
+	if lang := GetLangByExt(f.Path); lang != "" {
+		if cmd, ok := formatters[lang]; ok {
+			return cmd
+		}
+	}
+	return ""
+}
+
+// Format 对文件运行格式化命令，返回文件内容是否被格式化命令改变，ctx取消时终止格式化命令
+func (f *Formatter) Format(ctx context.Context) (bool, error) {
+	command := strings.TrimSpace(f.Command())
+	if command == "" {
+		return false, nil
+	}
+	quoted := ShellQuote(f.Path)
+	if strings.Contains(command, FORMATTER_FILE_PLACEHOLDER) {
+		command = strings.ReplaceAll(command, FORMATTER_FILE_PLACEHOLDER, quoted)
+	} else {
+		command = command + " " + quoted
+	}
+	before := FileHash(f.Path)
+
+	shell := &Shell{}
+	output, err := shell.RunCommand(ctx, command, FORMATTER_TIMEOUT)
+	if err != nil {
+		return false, fmt.Errorf("formatter `%s` failed: %v\n%s", command, err, output)
+	}
+	return FileHash(f.Path) != before, nil
+}
+
+// ShellQuote 用单引号包裹参数，避免路径中的空格和特殊字符被shell解释。
+// Windows上命令由PowerShell执行，单引号字符串中用两个单引号表示一个单引号
+func ShellQuote(s string) string {
+	if runtime.GOOS == "windows" {
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
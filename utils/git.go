@@ -0,0 +1,262 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Git 在用户的仓库中执行git命令，与checkpoint使用的影子仓库无关
+type Git struct {
+	Dir     string
+	Timeout time.Duration
+}
+
+func NewGit(dir string) *Git {
+	return &Git{Dir: dir, Timeout: 60 * time.Second}
+}
+
+// Run 执行git命令并返回stdout，禁用分页、颜色和交互，非零退出时错误中包含stderr
+func (g *Git) Run(args ...string) (string, error) {
+	sub := args[0]
+	args = append([]string{"--no-pager", "-c", "color.ui=never", "-c", "core.quotepath=off"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = g.Dir
+	cmd.Env = append(os.Environ(), "GIT_PAGER=cat", "PAGER=cat", "GIT_TERMINAL_PROMPT=0", "GIT_EDITOR=true", "LC_ALL=C")
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	timeout := g.Timeout
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			return stdout.String(), fmt.Errorf("git %s: %v, %s", sub, err, strings.TrimSpace(stderr.String()))
+		}
+		return stdout.String(), nil
+	case <-time.After(timeout):
+		cmd.Process.Kill()
+		<-done
+		return stdout.String(), fmt.Errorf("git %s timed out after %v", sub, timeout)
+	}
+}
+
+// IsRepo 目录是否在git仓库中
+func (g *Git) IsRepo() bool {
+	out, err := g.Run("rev-parse", "--is-inside-work-tree")
+	return err == nil && strings.TrimSpace(out) == "true"
+}
+
+// prefix Dir相对仓库根目录的路径，Dir就是根目录时为空
+func (g *Git) prefix() (string, error) {
+	out, err := g.Run("rev-parse", "--show-prefix")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// gitRelativePath 把git输出的相对仓库根目录的路径转换为相对Dir的路径，
+// 和git命令接受的路径参数保持一致，Dir之外的文件以../开头
+func gitRelativePath(prefix string, path string) string {
+	if prefix == "" || path == "" {
+		return path
+	}
+	rel, err := filepath.Rel(filepath.FromSlash(prefix), filepath.FromSlash(path))
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// GitFileStatus git status中的一个文件，Index和Worktree为porcelain格式中的XY
+type GitFileStatus struct {
+	Index    byte
+	Worktree byte
+	Path     string
+	OrigPath string // 重命名或复制前的路径
+}
+
+type GitStatus struct {
+	Branch   string
+	Upstream string
+	Ahead    int
+	Behind   int
+	Files    []*GitFileStatus
+}
+
+// Status 解析 git status --porcelain=v1 --branch -z 的输出，文件路径相对Dir
+func (g *Git) Status() (*GitStatus, error) {
+	prefix, err := g.prefix()
+	if err != nil {
+		return nil, err
+	}
+	out, err := g.Run("status", "--porcelain=v1", "--branch", "-z", "--untracked-files=all")
+	if err != nil {
+		return nil, err
+	}
+	status := ParseGitStatus(out)
+	for _, file := range status.Files {
+		file.Path = gitRelativePath(prefix, file.Path)
+		file.OrigPath = gitRelativePath(prefix, file.OrigPath)
+	}
+	return status, nil
+}
+
+func ParseGitStatus(out string) *GitStatus {
+	status := &GitStatus{}
+	entries := strings.Split(out, "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 3 {
+			continue
+		}
+		if strings.HasPrefix(entry, "## ") {
+			parseGitBranch(status, entry[3:])
+			continue
+		}
+		file := &GitFileStatus{Index: entry[0], Worktree: entry[1], Path: entry[3:]}
+		// 重命名和复制时，下一项是原路径
+		if (file.Index == 'R' || file.Index == 'C') && i+1 < len(entries) {
+			file.OrigPath = entries[i+1]
+			i++
+		}
+		status.Files = append(status.Files, file)
+	}
+	return status
+}
+
+// parseGitBranch 解析 main...origin/main [ahead 1, behind 2] 格式的分支信息
+func parseGitBranch(status *GitStatus, line string) {
+	if idx := strings.Index(line, " ["); idx >= 0 {
+		for _, part := range strings.Split(strings.Trim(line[idx+2:], "]"), ", ") {
+			fields := strings.Fields(part)
+			if len(fields) != 2 {
+				continue
+			}
+			n, _ := strconv.Atoi(fields[1])
+			switch fields[0] {
+			case "ahead":
+				status.Ahead = n
+			case "behind":
+				status.Behind = n
+			}
+		}
+		line = line[:idx]
+	}
+	if branch, upstream, ok := strings.Cut(line, "..."); ok {
+		status.Branch = branch
+		status.Upstream = upstream
+		return
+	}
+	status.Branch = strings.TrimPrefix(line, "No commits yet on ")
+}
+
+// GitBlameLine git blame中的一行
+type GitBlameLine struct {
+	Line    int
+	Hash    string
+	Author  string
+	Time    time.Time
+	Summary string
+	Content string
+}
+
+// Blame 获取文件在[start, end]行的blame信息，行号从1开始，end为0时表示到文件末尾
+func (g *Git) Blame(path string, start int, end int) ([]*GitBlameLine, error) {
+	args := []string{"blame", "--porcelain"}
+	if start > 0 || end > 0 {
+		if start <= 0 {
+			start = 1
+		}
+		lineRange := fmt.Sprintf("%d,", start)
+		if end > 0 {
+			lineRange += strconv.Itoa(end)
+		}
+		args = append(args, "-L", lineRange)
+	}
+	out, err := g.Run(append(args, "--", path)...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGitBlame(out), nil
+}
+
+// ParseGitBlame 解析 git blame --porcelain 的输出，同一个commit的信息只在第一次出现时给出
+func ParseGitBlame(out string) []*GitBlameLine {
+	type commitInfo struct {
+		author  string
+		time    time.Time
+		summary string
+	}
+	commits := map[string]*commitInfo{}
+	var lines []*GitBlameLine
+	var current *GitBlameLine
+	for _, line := range strings.Split(out, "\n") {
+		if current == nil {
+			fields := strings.Fields(line)
+			// SHA-1仓库的hash为40位，SHA-256仓库为64位
+			if len(fields) < 3 || (len(fields[0]) != 40 && len(fields[0]) != 64) {
+				continue
+			}
+			n, _ := strconv.Atoi(fields[2])
+			current = &GitBlameLine{Hash: fields[0], Line: n}
+			if commits[current.Hash] == nil {
+				commits[current.Hash] = &commitInfo{}
+			}
+			continue
+		}
+		info := commits[current.Hash]
+		switch {
+		case strings.HasPrefix(line, "\t"):
+			current.Content = line[1:]
+			current.Author = info.author
+			current.Time = info.time
+			current.Summary = info.summary
+			lines = append(lines, current)
+			current = nil
+		case strings.HasPrefix(line, "author "):
+			info.author = strings.TrimPrefix(line, "author ")
+		case strings.HasPrefix(line, "author-time "):
+			ts, _ := strconv.ParseInt(strings.TrimPrefix(line, "author-time "), 10, 64)
+			info.time = time.Unix(ts, 0)
+		case strings.HasPrefix(line, "summary "):
+			info.summary = strings.TrimPrefix(line, "summary ")
+		}
+	}
+	return lines
+}
+
+// StagedFiles 返回已暂存的文件，路径相对Dir，重命名拆成删除和新增两个路径
+func (g *Git) StagedFiles() ([]string, error) {
+	prefix, err := g.prefix()
+	if err != nil {
+		return nil, err
+	}
+	out, err := g.Run("diff", "--cached", "--name-only", "--no-renames", "-z")
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, file := range strings.Split(out, "\x00") {
+		if file != "" {
+			files = append(files, gitRelativePath(prefix, file))
+		}
+	}
+	return files, nil
+}
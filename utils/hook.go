@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"bergo/config"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	HOOK_SESSION_START      = "SessionStart"
+	HOOK_USER_PROMPT_SUBMIT = "UserPromptSubmit"
+	HOOK_PRE_TOOL_USE       = "PreToolUse"
+	HOOK_POST_TOOL_USE      = "PostToolUse"
+	HOOK_STOP               = "Stop"
+)
+
+const (
+	HOOK_DECISION_BLOCK    = "block"    // 阻止用户输入或者工具调用
+	HOOK_DECISION_CONTINUE = "continue" // Stop事件中让模型继续工作
+)
+
+// hook命令以这个退出码退出时表示阻止，stderr作为原因
+const HOOK_BLOCK_EXIT_CODE = 2
+
+// HookInput 以JSON格式写入hook命令的stdin
+type HookInput struct {
+	Event          string          `json:"event"`
+	SessionId      string          `json:"session_id"`
+	Cwd            string          `json:"cwd"`
+	Mode           string          `json:"mode,omitempty"`
+	Prompt         string          `json:"prompt,omitempty"`
+	ToolName       string          `json:"tool_name,omitempty"`
+	ToolInput      json.RawMessage `json:"tool_input,omitempty"`
+	ToolOutput     string          `json:"tool_output,omitempty"`
+	StopHookActive bool            `json:"stop_hook_active,omitempty"` // 本次任务中是否已经因为Stop hook继续过
+}
+
+// HookDecision hook命令在stdout中输出的JSON，stdout为空时表示不做干预
+type HookDecision struct {
+	Decision  string          `json:"decision,omitempty"`
+	Reason    string          `json:"reason,omitempty"`
+	ToolInput json.RawMessage `json:"tool_input,omitempty"` // PreToolUse中修改后的工具参数
+	Feedback  string          `json:"feedback,omitempty"`   // 追加给模型的信息
+}
+
+// HookResult 同一事件所有hook执行后的汇总结果
+type HookResult struct {
+	Block     bool
+	Continue  bool
+	Reason    string
+	ToolInput json.RawMessage
+	Feedback  []string
+	Errors    []error
+}
+
+// JoinedFeedback 把所有hook的反馈合并为一段文本
+func (r *HookResult) JoinedFeedback() string {
+	return strings.Join(r.Feedback, "\n")
+}
+
+// RunHooks 依次执行匹配事件的hook，遇到阻止时不再执行后面的hook
+// PreToolUse中修改过的参数会传给后面的hook；hook执行失败不会阻止流程，错误记录在Errors中
+func RunHooks(ctx context.Context, hooks []*config.HookConfig, input *HookInput) *HookResult {
+	result := &HookResult{}
+	if input.Cwd == "" {
+		input.Cwd, _ = os.Getwd()
+	}
+	shell := &Shell{}
+	for _, hook := range hooks {
+		if !matchHook(hook, input) {
+			continue
+		}
+		data, err := json.Marshal(input)
+		if err != nil {
+			result.Errors = append(result.Errors, err)
+			return result
+		}
+		timeout := time.Duration(hook.Timeout) * time.Second
+		if timeout <= 0 {
+			timeout = 60 * time.Second
+		}
+		stdout, stderr, err := shell.RunWithStdin(ctx, hook.Command, string(data), timeout)
+		if err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) && exitErr.ExitCode() == HOOK_BLOCK_EXIT_CODE {
+				result.Block = true
+				result.Reason = stderr
+				if result.Reason == "" {
+					result.Reason = fmt.Sprintf("blocked by hook `%s`", hook.Command)
+				}
+				return result
+			}
+			result.Errors = append(result.Errors, fmt.Errorf("hook `%s` failed: %v %s", hook.Command, err, stderr))
+			continue
+		}
+		if stdout == "" {
+			continue
+		}
+		decision := &HookDecision{}
+		if err := json.Unmarshal([]byte(stdout), decision); err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("hook `%s` output is not valid json: %v", hook.Command, err))
+			continue
+		}
+		if decision.Feedback != "" {
+			result.Feedback = append(result.Feedback, decision.Feedback)
+		}
+		if len(decision.ToolInput) > 0 && input.Event == HOOK_PRE_TOOL_USE {
+			result.ToolInput = decision.ToolInput
+			input.ToolInput = decision.ToolInput
+		}
+		switch decision.Decision {
+		case HOOK_DECISION_BLOCK:
+			result.Block = true
+			result.Reason = decision.Reason
+			if result.Reason == "" {
+				result.Reason = fmt.Sprintf("blocked by hook `%s`", hook.Command)
+			}
+			return result
+		case HOOK_DECISION_CONTINUE:
+			result.Continue = true
+			if decision.Reason != "" {
+				result.Reason = decision.Reason
+			}
+		}
+	}
+	return result
+}
+
+func matchHook(hook *config.HookConfig, input *HookInput) bool {
+	if !strings.EqualFold(hook.Event, input.Event) || strings.TrimSpace(hook.Command) == "" {
+		return false
+	}
+	if hook.Matcher == "" || (input.Event != HOOK_PRE_TOOL_USE && input.Event != HOOK_POST_TOOL_USE) {
+		return true
+	}
+	matched, err := regexp.MatchString("^(?:"+hook.Matcher+")$", input.ToolName)
+	return err == nil && matched
+}
@@ -65,6 +65,10 @@ func UserQueryStyle(message string) string {
 	return lipgloss.NewStyle().Border(lipgloss.ThickBorder()).BorderForeground(color).BorderLeft(true).BorderTop(false).BorderRight(false).BorderBottom(false).Padding(0, 1).Render(mainText) + "\n"
 }
 
+func SteerStyle(message string) string {
+	return UserQueryStyle("🧭 " + message)
+}
+
 func LLMInputStyle(message string) string {
 	width := pterm.GetTerminalWidth() * 7 / 10
 	color := lipgloss.AdaptiveColor{Dark: "#27F5F2", Light: "#079C99"}
@@ -72,6 +76,27 @@ func LLMInputStyle(message string) string {
 	return lipgloss.NewStyle().Border(lipgloss.ThickBorder()).BorderForeground(color).BorderLeft(true).BorderTop(false).BorderRight(false).BorderBottom(false).Padding(0, 1).Render(mainText) + "\n"
 }
 
+func TodoListStyle(list *TodoList) string {
+	width := pterm.GetTerminalWidth() * 7 / 10
+	doneColor := lipgloss.AdaptiveColor{Dark: "#34D399", Light: "#10B981"}
+	progressColor := lipgloss.AdaptiveColor{Dark: "#F5F366", Light: "#BFBC12"}
+	mutedColor := lipgloss.AdaptiveColor{Dark: "#9CA3AF", Light: "#6B7280"}
+	done, total := list.Progress()
+	lines := []string{lipgloss.NewStyle().Bold(true).Render(locales.Sprintf("📝 Todo %d/%d", done, total))}
+	for _, item := range list.Items {
+		switch item.Status {
+		case TODO_DONE:
+			lines = append(lines, lipgloss.NewStyle().Foreground(doneColor).Strikethrough(true).Render("✔ "+item.Content))
+		case TODO_IN_PROGRESS:
+			lines = append(lines, lipgloss.NewStyle().Foreground(progressColor).Bold(true).Render("▶ "+item.Content))
+		default:
+			lines = append(lines, lipgloss.NewStyle().Foreground(mutedColor).Render("○ "+item.Content))
+		}
+	}
+	mainText := lipgloss.NewStyle().Width(width).Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+	return lipgloss.NewStyle().Border(lipgloss.ThickBorder()).BorderForeground(progressColor).BorderLeft(true).BorderTop(false).BorderRight(false).BorderBottom(false).Padding(0, 1).Render(mainText) + "\n"
+}
+
 var AutoStyle glamour.TermRendererOption
 
 func init() {
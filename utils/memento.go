@@ -45,6 +45,19 @@ func HideMementoFile(sessionID string) {
 	os.Remove("./.bergo.memento")
 }
 
+// IsMementoFile 判断路径是否为项目根目录下的 memento 文件
+func IsMementoFile(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path) == ".bergo.memento"
+	}
+	root, err := filepath.Abs(".bergo.memento")
+	if err != nil {
+		return false
+	}
+	return abs == root
+}
+
 // GetMementoHash 获取当前 memento 文件的 MD5 哈希值
 func GetMementoHash() string {
 	content, err := os.ReadFile("./.bergo.memento")
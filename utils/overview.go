@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	// 按路径的前两级目录划分概览的章节
+	OVERVIEW_DIR_DEPTH = 2
+	// 项目整体的章节：架构、入口、构建和测试命令、约定，用不会和目录重名的key
+	OVERVIEW_PROJECT_SECTION = "@project"
+)
+
+var overviewSectionRegex = regexp.MustCompile(`(?s)<!-- bergo:section key="([^"]*)" hash="([^"]*)" -->\n(.*?)\n?<!-- bergo:end -->`)
+
+// OverviewSection 概览中的一个章节，Hash为生成时对应目录的指纹
+type OverviewSection struct {
+	Key     string
+	Hash    string
+	Content string
+}
+
+// Overview /init生成的项目概览，保存为带标记的markdown，每个章节可以单独刷新
+type Overview struct {
+	Sections []*OverviewSection
+}
+
+func ParseOverview(content string) *Overview {
+	o := &Overview{}
+	for _, match := range overviewSectionRegex.FindAllStringSubmatch(content, -1) {
+		o.Set(match[1], match[2], match[3])
+	}
+	return o
+}
+
+// LoadOverview 读取概览文件，文件不存在时返回空的概览
+func LoadOverview(path string) *Overview {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return &Overview{}
+	}
+	return ParseOverview(string(content))
+}
+
+func (o *Overview) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(o.String()), 0644)
+}
+
+func (o *Overview) Section(key string) *OverviewSection {
+	for _, section := range o.Sections {
+		if section.Key == key {
+			return section
+		}
+	}
+	return nil
+}
+
+// Set 添加或替换章节，项目章节排在最前面，目录章节按路径排序
+func (o *Overview) Set(key string, hash string, content string) {
+	content = strings.TrimSpace(content)
+	if section := o.Section(key); section != nil {
+		section.Hash, section.Content = hash, content
+		return
+	}
+	o.Sections = append(o.Sections, &OverviewSection{Key: key, Hash: hash, Content: content})
+	sort.SliceStable(o.Sections, func(i, j int) bool {
+		if (o.Sections[i].Key == OVERVIEW_PROJECT_SECTION) != (o.Sections[j].Key == OVERVIEW_PROJECT_SECTION) {
+			return o.Sections[i].Key == OVERVIEW_PROJECT_SECTION
+		}
+		return o.Sections[i].Key < o.Sections[j].Key
+	})
+}
+
+func (o *Overview) Remove(key string) {
+	o.Sections = slices.DeleteFunc(o.Sections, func(s *OverviewSection) bool {
+		return s.Key == key
+	})
+}
+
+func (o *Overview) String() string {
+	var b strings.Builder
+	b.WriteString("# 项目概览\n\n<!-- 由 bergo /init 生成，运行 /init 会刷新内容有变化的目录对应的章节。可以修改章节内容，但请保留 bergo 标记 -->\n")
+	for _, section := range o.Sections {
+		fmt.Fprintf(&b, "\n<!-- bergo:section key=\"%s\" hash=\"%s\" -->\n%s\n<!-- bergo:end -->\n", section.Key, section.Hash, section.Content)
+	}
+	return b.String()
+}
+
+// OverviewPlan 需要刷新的章节：Dirs为新增或者内容变化的目录，Removed为已经不存在的目录，
+// ProjectHash为所有目录指纹合并后的指纹，和项目章节记录的不同时需要重新生成项目章节
+type OverviewPlan struct {
+	Dirs        []*IndexedDir
+	Removed     []string
+	ProjectHash string
+	Project     bool
+}
+
+func (o *Overview) Plan(dirs []*IndexedDir, force bool) *OverviewPlan {
+	plan := &OverviewPlan{}
+	hash := sha256.New()
+	current := map[string]bool{}
+	for _, dir := range dirs {
+		current[dir.Path] = true
+		fmt.Fprintf(hash, "%s %s\n", dir.Path, dir.Hash)
+		if section := o.Section(dir.Path); force || section == nil || section.Hash != dir.Hash {
+			plan.Dirs = append(plan.Dirs, dir)
+		}
+	}
+	for _, section := range o.Sections {
+		if section.Key != OVERVIEW_PROJECT_SECTION && !current[section.Key] {
+			plan.Removed = append(plan.Removed, section.Key)
+		}
+	}
+	plan.ProjectHash = hex.EncodeToString(hash.Sum(nil))[:16]
+	section := o.Section(OVERVIEW_PROJECT_SECTION)
+	plan.Project = force || section == nil || section.Hash != plan.ProjectHash
+	return plan
+}
+
+// Empty 所有章节都是最新的
+func (p *OverviewPlan) Empty() bool {
+	return len(p.Dirs) == 0 && len(p.Removed) == 0 && !p.Project
+}
@@ -11,9 +11,32 @@ type ReadFile struct {
 	Path        string
 	LineBudget  int
 	WithLineNum bool
+	Entry       string // 压缩包内要读取的文件，为空时列出压缩包内容
+	ImageDir    string // notebook图片输出的保存目录，为空时保存到工作区存储目录
+}
+
+func (r *ReadFile) formatLine(lineNum int, line string) string {
+	if r.WithLineNum {
+		return fmt.Sprintf("%d|%s\n", lineNum, line)
+	}
+	return line + "\n"
 }
 
 func (r *ReadFile) ReadFile() ([]string, error) {
+	if lines, ok, err := r.readDocLines(); ok {
+		if err != nil {
+			return nil, err
+		}
+		var result []string
+		for i, line := range lines {
+			if r.LineBudget > 0 && i >= r.LineBudget {
+				result = append(result, fmt.Sprintf("...content after %d lines are truncated...\n", r.LineBudget))
+				break
+			}
+			result = append(result, r.formatLine(i+1, line))
+		}
+		return result, nil
+	}
 	isBinary, err := IsBinaryFile(r.Path)
 	if err != nil {
 		return nil, err
@@ -46,6 +69,21 @@ func (r *ReadFile) ReadFile() ([]string, error) {
 }
 
 func (r *ReadFile) ReadFileTruncated(start int, end int) ([]string, error) {
+	if lines, ok, err := r.readDocLines(); ok {
+		if err != nil {
+			return nil, err
+		}
+		if end == 0 {
+			end = math.MaxInt
+		}
+		var result []string
+		for i, line := range lines {
+			if i+1 >= start && i+1 <= end {
+				result = append(result, r.formatLine(i+1, line))
+			}
+		}
+		return result, nil
+	}
 	isBinary, err := IsBinaryFile(r.Path)
 	if err != nil {
 		return nil, err
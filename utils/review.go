@@ -0,0 +1,270 @@
+package utils
+
+import (
+	"bergo/locales"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 代码审查问题的严重程度，从高到低
+const (
+	SEVERITY_BLOCKER = "blocker"
+	SEVERITY_MAJOR   = "major"
+	SEVERITY_MINOR   = "minor"
+	SEVERITY_NIT     = "nit"
+)
+
+var ReviewSeverities = []string{SEVERITY_BLOCKER, SEVERITY_MAJOR, SEVERITY_MINOR, SEVERITY_NIT}
+
+var ReviewCategories = []string{"bug", "security", "performance", "concurrency", "error-handling", "maintainability", "style", "test", "docs"}
+
+// SeverityRank 严重程度的排序，越严重越小，未知的排在最后
+func SeverityRank(severity string) int {
+	if idx := slices.Index(ReviewSeverities, severity); idx >= 0 {
+		return idx
+	}
+	return len(ReviewSeverities)
+}
+
+// sarifLevel SARIF中result的level只有error、warning、note
+func sarifLevel(severity string) string {
+	switch severity {
+	case SEVERITY_BLOCKER:
+		return "error"
+	case SEVERITY_MAJOR:
+		return "warning"
+	}
+	return "note"
+}
+
+// ReviewFinding 代码审查发现的一个问题，行号从1开始
+type ReviewFinding struct {
+	File       string `json:"file"`
+	StartLine  int    `json:"start_line"`
+	EndLine    int    `json:"end_line"`
+	Severity   string `json:"severity"`
+	Category   string `json:"category"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion"`
+}
+
+func (f *ReviewFinding) Location() string {
+	if f.EndLine > f.StartLine {
+		return fmt.Sprintf("%s:%d-%d", f.File, f.StartLine, f.EndLine)
+	}
+	return fmt.Sprintf("%s:%d", f.File, f.StartLine)
+}
+
+// 以下方法实现cli.HistoryItem，用于在列表中浏览审查结果
+func (f *ReviewFinding) Title() string {
+	return fmt.Sprintf("[%s] %s (%s)", f.Severity, f.Location(), f.Category)
+}
+
+func (f *ReviewFinding) Simple() string {
+	line, _, _ := strings.Cut(strings.TrimSpace(f.Message), "\n")
+	return line
+}
+
+func (f *ReviewFinding) Detail() string {
+	buf := bytes.NewBufferString(fmt.Sprintf("%s\n%s: %s  %s: %s\n\n%s\n", f.Location(), locales.Sprintf("severity"), f.Severity, locales.Sprintf("category"), f.Category, strings.TrimSpace(f.Message)))
+	if f.Suggestion != "" {
+		buf.WriteString(fmt.Sprintf("\n%s:\n%s\n", locales.Sprintf("suggestion"), strings.TrimSpace(f.Suggestion)))
+	}
+	return buf.String()
+}
+
+func (f *ReviewFinding) ActionList() []string {
+	return nil
+}
+
+// ReviewReport 一次代码审查的结果，report_finding工具可能被并行调用，需要加锁
+type ReviewReport struct {
+	Target   string
+	Overview string // 模型对这次审查的总结
+	mu       sync.Mutex
+	findings []*ReviewFinding
+}
+
+func NewReviewReport(target string) *ReviewReport {
+	return &ReviewReport{Target: target}
+}
+
+// Add 记录一个问题，相同位置的相同问题只记录一次，返回问题的序号
+func (r *ReviewReport) Add(finding *ReviewFinding) (int, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, f := range r.findings {
+		if f.File == finding.File && f.StartLine == finding.StartLine && f.Message == finding.Message {
+			return i + 1, false
+		}
+	}
+	r.findings = append(r.findings, finding)
+	return len(r.findings), true
+}
+
+// Findings 按严重程度、文件和行号排序后的问题
+func (r *ReviewReport) Findings() []*ReviewFinding {
+	r.mu.Lock()
+	findings := append([]*ReviewFinding{}, r.findings...)
+	r.mu.Unlock()
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if SeverityRank(a.Severity) != SeverityRank(b.Severity) {
+			return SeverityRank(a.Severity) < SeverityRank(b.Severity)
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.StartLine < b.StartLine
+	})
+	return findings
+}
+
+// Blocking 返回严重程度不低于failOn的问题
+func (r *ReviewReport) Blocking(failOn string) []*ReviewFinding {
+	var blocking []*ReviewFinding
+	for _, f := range r.Findings() {
+		if SeverityRank(f.Severity) <= SeverityRank(failOn) {
+			blocking = append(blocking, f)
+		}
+	}
+	return blocking
+}
+
+// Summary 各严重程度的问题数，例如 1 blocker, 2 minor
+func (r *ReviewReport) Summary() string {
+	findings := r.Findings()
+	if len(findings) == 0 {
+		return locales.Sprintf("no findings")
+	}
+	var parts []string
+	for _, severity := range ReviewSeverities {
+		count := 0
+		for _, f := range findings {
+			if f.Severity == severity {
+				count++
+			}
+		}
+		if count > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", count, severity))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (r *ReviewReport) Markdown() string {
+	buf := bytes.NewBufferString(fmt.Sprintf("# %s\n\n", locales.Sprintf("Code review: %s", r.Target)))
+	buf.WriteString(fmt.Sprintf("%s\n", r.Summary()))
+	if r.Overview != "" {
+		buf.WriteString(fmt.Sprintf("\n%s\n", strings.TrimSpace(r.Overview)))
+	}
+	for i, f := range r.Findings() {
+		buf.WriteString(fmt.Sprintf("\n## %d. `%s` %s · %s\n\n%s\n", i+1, f.Location(), f.Severity, f.Category, strings.TrimSpace(f.Message)))
+		if f.Suggestion != "" {
+			buf.WriteString(fmt.Sprintf("\n**%s**: %s\n", locales.Sprintf("Suggestion"), strings.TrimSpace(f.Suggestion)))
+		}
+	}
+	return buf.String()
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name    string      `json:"name"`
+	Version string      `json:"version,omitempty"`
+	Rules   []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	Id               string       `json:"id"`
+	Name             string       `json:"name"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleId     string            `json:"ruleId"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations"`
+	Properties map[string]string `json:"properties"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	Uri       string `json:"uri"`
+	UriBaseId string `json:"uriBaseId"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine"`
+}
+
+// SARIF 导出为SARIF 2.1.0，每个分类对应一条规则，路径相对于仓库根目录
+func (r *ReviewReport) SARIF(toolVersion string) ([]byte, error) {
+	findings := r.Findings()
+	var rules []sarifRule
+	results := []sarifResult{}
+	for _, f := range findings {
+		ruleId := "bergo/" + f.Category
+		if !slices.ContainsFunc(rules, func(rule sarifRule) bool { return rule.Id == ruleId }) {
+			rules = append(rules, sarifRule{Id: ruleId, Name: f.Category, ShortDescription: sarifMessage{Text: f.Category}})
+		}
+		text := strings.TrimSpace(f.Message)
+		if f.Suggestion != "" {
+			text += "\n" + locales.Sprintf("Suggestion") + ": " + strings.TrimSpace(f.Suggestion)
+		}
+		endLine := max(f.EndLine, f.StartLine)
+		results = append(results, sarifResult{
+			RuleId:  ruleId,
+			Level:   sarifLevel(f.Severity),
+			Message: sarifMessage{Text: text},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{Uri: f.File, UriBaseId: "%SRCROOT%"},
+				Region:           sarifRegion{StartLine: f.StartLine, EndLine: endLine},
+			}}},
+			Properties: map[string]string{"severity": f.Severity, "category": f.Category},
+		})
+	}
+	if rules == nil {
+		rules = []sarifRule{}
+	}
+	return json.MarshalIndent(&sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool:    sarifTool{Driver: sarifDriver{Name: "bergo", Version: toolVersion, Rules: rules}},
+			Results: results,
+		}},
+	}, "", "  ")
+}
@@ -19,6 +19,8 @@ import (
 
 const (
 	MAX_OUTPUT_LINE = 5000
+	// 命令被终止后等待输出管道关闭的时间
+	SHELL_WAIT_DELAY = 2 * time.Second
 )
 
 type Shell struct {
@@ -45,28 +47,45 @@ func (s *Shell) Run(command string) (string, error) {
 
 // getShellCommand 根据操作系统返回合适的shell命令
 func (s *Shell) getShellCommand(command string) *exec.Cmd {
+	name, args := shellArgs(command)
+	return exec.Command(name, args...)
+}
+
+// shellArgs 根据操作系统选择执行命令的shell和参数
+func shellArgs(command string) (string, []string) {
 	switch runtime.GOOS {
 	case "windows":
 		// Windows 系统使用 PowerShell
-		return exec.Command("powershell", "-Command", command)
+		return "powershell", []string{"-Command", command}
 	case "darwin":
 		// macOS 系统，优先使用 zsh，如果不存在则使用 bash
 		if _, err := exec.LookPath("zsh"); err == nil {
-			return exec.Command("zsh", "-c", command)
+			return "zsh", []string{"-c", command}
 		}
-		return exec.Command("bash", "-c", command)
+		return "bash", []string{"-c", command}
 	case "linux":
 		// Linux 系统，优先使用 bash，如果不存在则使用 sh
 		if _, err := exec.LookPath("bash"); err == nil {
-			return exec.Command("bash", "-c", command)
+			return "bash", []string{"-c", command}
 		}
-		return exec.Command("sh", "-c", command)
+		return "sh", []string{"-c", command}
 	default:
 		// 其他系统默认使用 sh
-		return exec.Command("sh", "-c", command)
+		return "sh", []string{"-c", command}
 	}
 }
 
+// commandContext 创建在单独进程组中运行的命令，ctx结束时终止整个进程组，
+// 避免测试二进制这类子进程继续占用输出管道，使Wait一直阻塞
+func (s *Shell) commandContext(ctx context.Context, command string) *exec.Cmd {
+	name, args := shellArgs(command)
+	cmd := exec.CommandContext(ctx, name, args...)
+	SetProcessGroup(cmd)
+	// 进程组被终止后，仍然占用管道的进程最多再等待这么久
+	cmd.WaitDelay = SHELL_WAIT_DELAY
+	return cmd
+}
+
 // Run 执行shell命令，根据操作系统自动选择合适的shell
 func (s *Shell) RunWithTimeout(command string) string {
 	// 默认超时时间为3min
@@ -102,6 +121,43 @@ func (s *Shell) RunWithTimeout(command string) string {
 	return strings.TrimSpace(string(output))
 }
 
+// RunCommand 执行命令并返回合并后的输出，命令非零退出、超时或者ctx被取消都会返回错误
+func (s *Shell) RunCommand(ctx context.Context, command string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := s.commandContext(ctx, command)
+	output := bytes.NewBuffer(nil)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	err := cmd.Run()
+	return strings.TrimSpace(output.String()), commandError(ctx, err, timeout)
+}
+
+// commandError 区分超时、取消和命令本身的错误
+func commandError(ctx context.Context, err error, timeout time.Duration) error {
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return fmt.Errorf("timed out after %v", timeout)
+	case context.Canceled:
+		return fmt.Errorf("user interrupted")
+	}
+	return err
+}
+
+// RunWithStdin 执行命令并把input写入stdin，分别返回stdout和stderr，命令非零退出、超时或者ctx取消都会返回错误
+func (s *Shell) RunWithStdin(ctx context.Context, command string, input string, timeout time.Duration) (string, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := s.commandContext(ctx, command)
+	cmd.Stdin = strings.NewReader(input)
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	return strings.TrimSpace(stdout.String()), strings.TrimSpace(stderr.String()), commandError(ctx, err, timeout)
+}
+
 // 使用伪终端转发过程给用户
 func (s *Shell) RunWithPty(command string) (string, error) {
 	ptmx, err := pty.New()
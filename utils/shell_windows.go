@@ -0,0 +1,20 @@
+//go:build windows
+
+package utils
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// SetProcessGroup Windows没有进程组，CommandContext创建的命令取消时用taskkill终止整个进程树
+func SetProcessGroup(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd)
+	}
+}
+
+// killProcessGroup 终止命令和它启动的所有子进程
+func killProcessGroup(cmd *exec.Cmd) error {
+	return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+}
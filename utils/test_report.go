@@ -0,0 +1,517 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// 每个失败用例保留的输出行数
+	TEST_FAILURE_OUTPUT_LINE = 30
+	// 失败用例的消息最多保留的行数
+	TEST_FAILURE_MESSAGE_LINE = 8
+)
+
+// TestFailure 一个失败的测试用例，Suite为包名、类名或测试文件，Name为空表示整个Suite失败（例如编译错误）
+type TestFailure struct {
+	Suite   string
+	Name    string
+	File    string
+	Line    int
+	Message string
+	Output  string
+}
+
+func (f *TestFailure) Location() string {
+	if f.File == "" {
+		return ""
+	}
+	if f.Line > 0 {
+		return fmt.Sprintf("%s:%d", f.File, f.Line)
+	}
+	return f.File
+}
+
+// TestReport 一次测试运行的结果
+type TestReport struct {
+	Framework string
+	Command   string
+	Passed    int
+	Failed    int
+	Skipped   int
+	Failures  []*TestFailure
+}
+
+// Format 生成紧凑的测试摘要，最多列出maxFailures个失败用例
+func (r *TestReport) Format(maxFailures int) string {
+	buf := bytes.NewBufferString(fmt.Sprintf("framework: %s\ncommand: %s\npassed: %d, failed: %d, skipped: %d\n", r.Framework, r.Command, r.Passed, r.Failed, r.Skipped))
+	if len(r.Failures) == 0 {
+		return strings.TrimRight(buf.String(), "\n")
+	}
+	buf.WriteString("failures:\n")
+	for i, f := range r.Failures {
+		if i >= maxFailures {
+			buf.WriteString(fmt.Sprintf("...%d more failures are omitted, rerun the failures with a filter to see them\n", len(r.Failures)-maxFailures))
+			break
+		}
+		name := f.Name
+		if name == "" {
+			name = "(suite failed)"
+		}
+		if f.Suite != "" {
+			name = f.Suite + " " + name
+		}
+		if loc := f.Location(); loc != "" {
+			name += " (" + loc + ")"
+		}
+		buf.WriteString(fmt.Sprintf("%d. %s\n", i+1, name))
+		if f.Message != "" {
+			buf.WriteString("   message: " + indentLines(headLines(f.Message, TEST_FAILURE_MESSAGE_LINE), "     ") + "\n")
+		}
+		if f.Output != "" && f.Output != f.Message {
+			buf.WriteString("   output:\n     " + indentLines(TailLines(f.Output, TEST_FAILURE_OUTPUT_LINE), "     ") + "\n")
+		}
+	}
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+func indentLines(text string, indent string) string {
+	return strings.ReplaceAll(text, "\n", "\n"+indent)
+}
+
+// headLines 保留文本的前n行
+func headLines(content string, n int) string {
+	lines := strings.Split(content, "\n")
+	if len(lines) <= n {
+		return content
+	}
+	return fmt.Sprintf("%s\n...(%d lines omitted)", strings.Join(lines[:n], "\n"), len(lines)-n)
+}
+
+var ansiRegex = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+func StripAnsi(text string) string {
+	return ansiRegex.ReplaceAllString(text, "")
+}
+
+// relativePath 工作区内的绝对路径转换为相对路径
+func relativePath(path string) string {
+	if !filepath.IsAbs(path) {
+		return path
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+type goTestEvent struct {
+	Action     string
+	Package    string
+	ImportPath string
+	Test       string
+	Output     string
+}
+
+var (
+	// t.Errorf等输出的位置，例如 "    foo_test.go:12: message"
+	goTestLogRegex = regexp.MustCompile(`^\s+([\w./-]+\.go):(\d+): ?(.*)$`)
+	// panic堆栈中的位置，例如 "\t/path/to/foo.go:12 +0x1d"
+	goStackRegex = regexp.MustCompile(`^\t(\S+\.go):(\d+)`)
+)
+
+// ParseGoTestJSON 解析 go test -json 的输出，不是JSON的行（例如编译错误）作为包的输出
+func ParseGoTestJSON(out string) *TestReport {
+	report := &TestReport{Framework: "go"}
+	type testKey struct{ pkg, test string }
+	outputs := map[testKey]*strings.Builder{}
+	appendOutput := func(key testKey, text string) {
+		if outputs[key] == nil {
+			outputs[key] = &strings.Builder{}
+		}
+		outputs[key].WriteString(text)
+	}
+	var failedTests, passedTests []testKey
+	var failedPkgs []string
+	// 上报过结果的测试，用来判断一个测试是否有子测试
+	reported := map[testKey]bool{}
+	raw := &strings.Builder{}
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		event := &goTestEvent{}
+		if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), event) != nil {
+			raw.WriteString(line + "\n")
+			continue
+		}
+		switch event.Action {
+		case "build-output":
+			// go1.24之后编译输出单独上报，ImportPath形如 "pkg [pkg.test]"
+			pkg, _, _ := strings.Cut(event.ImportPath, " ")
+			appendOutput(testKey{pkg: pkg}, event.Output)
+		case "output":
+			appendOutput(testKey{event.Package, event.Test}, event.Output)
+		case "pass":
+			if event.Test != "" {
+				passedTests = append(passedTests, testKey{event.Package, event.Test})
+				reported[testKey{event.Package, event.Test}] = true
+			}
+		case "skip":
+			if event.Test != "" {
+				report.Skipped++
+				reported[testKey{event.Package, event.Test}] = true
+			}
+		case "fail":
+			if event.Test != "" {
+				failedTests = append(failedTests, testKey{event.Package, event.Test})
+				reported[testKey{event.Package, event.Test}] = true
+			} else {
+				failedPkgs = append(failedPkgs, event.Package)
+			}
+		}
+	}
+	// 父测试和每个子测试都会上报pass，只统计没有子测试的测试
+	for _, key := range passedTests {
+		isParent := false
+		for other := range reported {
+			if other.pkg == key.pkg && strings.HasPrefix(other.test, key.test+"/") {
+				isParent = true
+				break
+			}
+		}
+		if !isParent {
+			report.Passed++
+		}
+	}
+	pkgHasFailure := map[string]bool{}
+	for _, key := range failedTests {
+		// 子测试失败时父测试也会失败，只保留子测试
+		isParent := false
+		for _, other := range failedTests {
+			if other.pkg == key.pkg && strings.HasPrefix(other.test, key.test+"/") {
+				isParent = true
+				break
+			}
+		}
+		if isParent {
+			continue
+		}
+		output := ""
+		if outputs[key] != nil {
+			output = outputs[key].String()
+		}
+		failure := &TestFailure{Suite: key.pkg, Name: key.test}
+		parseGoTestOutput(failure, output)
+		report.Failures = append(report.Failures, failure)
+		pkgHasFailure[key.pkg] = true
+	}
+	for _, pkg := range failedPkgs {
+		if pkgHasFailure[pkg] {
+			continue
+		}
+		output := ""
+		if outputs[testKey{pkg: pkg}] != nil {
+			output = outputs[testKey{pkg: pkg}].String()
+		}
+		report.Failures = append(report.Failures, &TestFailure{Suite: pkg, Message: "package failed", Output: strings.TrimSpace(output)})
+	}
+	// 编译失败时go test可能不输出任何事件
+	if len(report.Failures) == 0 && strings.TrimSpace(raw.String()) != "" && report.Passed == 0 {
+		text := strings.TrimSpace(raw.String())
+		if strings.Contains(text, "FAIL") || strings.Contains(text, "error") || strings.Contains(text, "cannot") {
+			report.Failures = append(report.Failures, &TestFailure{Message: "build failed", Output: text})
+		}
+	}
+	report.Failed = len(report.Failures)
+	return report
+}
+
+// parseGoTestOutput 从测试输出中提取失败位置和消息，去掉 === RUN 这类状态行
+func parseGoTestOutput(failure *TestFailure, output string) {
+	var kept []string
+	var messages []string
+	inPanic := false
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "=== ") || strings.HasPrefix(trimmed, "--- FAIL") || strings.HasPrefix(trimmed, "--- PASS") || strings.HasPrefix(trimmed, "--- SKIP") || trimmed == "" {
+			continue
+		}
+		kept = append(kept, strings.TrimRight(line, " "))
+		if match := goTestLogRegex.FindStringSubmatch(line); match != nil && !inPanic {
+			if failure.File == "" {
+				failure.File = match[1]
+				failure.Line, _ = strconv.Atoi(match[2])
+			}
+			messages = append(messages, match[3])
+			continue
+		}
+		if strings.HasPrefix(trimmed, "panic: ") {
+			inPanic = true
+			messages = append(messages, trimmed)
+			continue
+		}
+		if inPanic && failure.File == "" {
+			if match := goStackRegex.FindStringSubmatch(line); match != nil && !strings.Contains(match[1], "/src/runtime/") && !strings.Contains(match[1], "/src/testing/") {
+				failure.File = relativePath(match[1])
+				failure.Line, _ = strconv.Atoi(match[2])
+			}
+		}
+	}
+	failure.Message = strings.Join(messages, "\n")
+	failure.Output = strings.Join(kept, "\n")
+}
+
+type junitResult struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitCase struct {
+	Name      string       `xml:"name,attr"`
+	Classname string       `xml:"classname,attr"`
+	File      string       `xml:"file,attr"`
+	Line      int          `xml:"line,attr"`
+	Failure   *junitResult `xml:"failure"`
+	Error     *junitResult `xml:"error"`
+	Skipped   *junitResult `xml:"skipped"`
+	SystemOut string       `xml:"system-out"`
+	SystemErr string       `xml:"system-err"`
+}
+
+type junitSuite struct {
+	Name   string       `xml:"name,attr"`
+	Cases  []junitCase  `xml:"testcase"`
+	Suites []junitSuite `xml:"testsuite"`
+}
+
+// pytest traceback的最后一行，例如 "tests/test_a.py:12: AssertionError"
+var pyTracebackRegex = regexp.MustCompile(`(?m)^(\S+\.py):(\d+): `)
+
+// ParseJUnitXML 解析JUnit XML，根元素可以是testsuites或testsuite
+func ParseJUnitXML(data []byte) (*TestReport, error) {
+	root := &junitSuite{}
+	if err := xml.Unmarshal(data, root); err != nil {
+		return nil, err
+	}
+	report := &TestReport{}
+	var walk func(suite *junitSuite)
+	walk = func(suite *junitSuite) {
+		for i := range suite.Cases {
+			c := &suite.Cases[i]
+			result := c.Failure
+			if result == nil {
+				result = c.Error
+			}
+			switch {
+			case result != nil:
+				failure := &TestFailure{Suite: c.Classname, Name: c.Name, File: c.File, Line: c.Line, Message: strings.TrimSpace(result.Message)}
+				text := strings.TrimSpace(result.Text)
+				if matches := pyTracebackRegex.FindAllStringSubmatch(text, -1); len(matches) > 0 {
+					last := matches[len(matches)-1]
+					failure.File = last[1]
+					failure.Line, _ = strconv.Atoi(last[2])
+				}
+				if failure.Message == "" {
+					failure.Message = result.Type
+				}
+				failure.Output = strings.TrimSpace(strings.Join([]string{text, strings.TrimSpace(c.SystemOut), strings.TrimSpace(c.SystemErr)}, "\n"))
+				report.Failures = append(report.Failures, failure)
+			case c.Skipped != nil:
+				report.Skipped++
+			default:
+				report.Passed++
+			}
+		}
+		for i := range suite.Suites {
+			walk(&suite.Suites[i])
+		}
+	}
+	walk(root)
+	report.Failed = len(report.Failures)
+	return report, nil
+}
+
+type jestAssertion struct {
+	FullName        string   `json:"fullName"`
+	Status          string   `json:"status"`
+	FailureMessages []string `json:"failureMessages"`
+	Location        *struct {
+		Line int `json:"line"`
+	} `json:"location"`
+}
+
+type jestReport struct {
+	TestResults []struct {
+		Name             string          `json:"name"`
+		Status           string          `json:"status"`
+		Message          string          `json:"message"`
+		AssertionResults []jestAssertion `json:"assertionResults"`
+	} `json:"testResults"`
+}
+
+// 堆栈中的位置，例如 "at Object.<anonymous> (/repo/src/a.test.ts:12:5)"
+var jsStackRegex = regexp.MustCompile(`\(?((?:[A-Za-z]:)?[^\s():]+):(\d+):\d+\)?`)
+
+// ParseJestJSON 解析jest --json和vitest --reporter=json的输出，两者格式兼容
+func ParseJestJSON(data []byte) (*TestReport, error) {
+	result := &jestReport{}
+	if err := json.Unmarshal(data, result); err != nil {
+		return nil, err
+	}
+	report := &TestReport{}
+	for _, file := range result.TestResults {
+		path := relativePath(file.Name)
+		fileFailed := false
+		for _, a := range file.AssertionResults {
+			switch a.Status {
+			case "passed":
+				report.Passed++
+			case "failed":
+				fileFailed = true
+				message := StripAnsi(strings.Join(a.FailureMessages, "\n"))
+				failure := &TestFailure{Suite: path, Name: a.FullName, File: path}
+				if a.Location != nil {
+					failure.Line = a.Location.Line
+				}
+				// 优先使用堆栈中测试文件的位置
+				for _, match := range jsStackRegex.FindAllStringSubmatch(message, -1) {
+					if relativePath(match[1]) == path {
+						failure.Line, _ = strconv.Atoi(match[2])
+						break
+					}
+				}
+				failure.Message, failure.Output = splitJsFailure(message)
+				report.Failures = append(report.Failures, failure)
+			default:
+				report.Skipped++
+			}
+		}
+		// 测试文件本身出错（例如语法错误）时没有用例结果
+		if file.Status == "failed" && !fileFailed {
+			message, output := splitJsFailure(StripAnsi(file.Message))
+			report.Failures = append(report.Failures, &TestFailure{Suite: path, File: path, Message: message, Output: output})
+		}
+	}
+	report.Failed = len(report.Failures)
+	return report, nil
+}
+
+// splitJsFailure 堆栈之前的部分作为消息，整体作为输出
+func splitJsFailure(text string) (string, string) {
+	text = strings.TrimSpace(text)
+	var message []string
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "at ") {
+			break
+		}
+		message = append(message, line)
+	}
+	return strings.TrimSpace(strings.Join(message, "\n")), text
+}
+
+var (
+	cargoTestRegex    = regexp.MustCompile(`^test (\S+) \.\.\. (ok|FAILED|ignored)`)
+	cargoRunningRegex = regexp.MustCompile(`^\s+(?:Running|Doc-tests) (?:unittests )?(\S+)`)
+	cargoSectionRegex = regexp.MustCompile(`^---- (\S+) stdout ----$`)
+	// 新版本 "panicked at src/lib.rs:10:5:"，旧版本 "panicked at 'msg', src/lib.rs:10:5"
+	cargoPanicRegex    = regexp.MustCompile(`panicked at (?:'(.*)', )?([^\s:]+):(\d+):\d+:?$`)
+	cargoBuildErrRegex = regexp.MustCompile(`(?m)^error(\[E\d+\])?: `)
+)
+
+// ParseCargoTest 解析cargo test的文本输出
+func ParseCargoTest(out string) *TestReport {
+	report := &TestReport{Framework: "cargo"}
+	suite := ""
+	failed := map[string]*TestFailure{}
+	var order []string
+	var current *TestFailure
+	var section []string
+	flush := func() {
+		if current != nil {
+			current.Output = strings.TrimSpace(strings.Join(section, "\n"))
+		}
+		current = nil
+		section = nil
+	}
+	lines := strings.Split(out, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r")
+		if match := cargoRunningRegex.FindStringSubmatch(line); match != nil {
+			flush()
+			suite = match[1]
+			continue
+		}
+		if match := cargoTestRegex.FindStringSubmatch(line); match != nil {
+			switch match[2] {
+			case "ok":
+				report.Passed++
+			case "ignored":
+				report.Skipped++
+			case "FAILED":
+				key := suite + "\x00" + match[1]
+				if failed[key] == nil {
+					failed[key] = &TestFailure{Suite: suite, Name: match[1]}
+					order = append(order, key)
+				}
+			}
+			continue
+		}
+		if match := cargoSectionRegex.FindStringSubmatch(line); match != nil {
+			flush()
+			current = failed[suite+"\x00"+match[1]]
+			if current == nil {
+				current = &TestFailure{Suite: suite, Name: match[1]}
+				failed[suite+"\x00"+match[1]] = current
+				order = append(order, suite+"\x00"+match[1])
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if line == "failures:" || strings.HasPrefix(line, "test result:") {
+			flush()
+			continue
+		}
+		section = append(section, line)
+		if match := cargoPanicRegex.FindStringSubmatch(line); match != nil && current.File == "" {
+			current.File = match[2]
+			current.Line, _ = strconv.Atoi(match[3])
+			if match[1] != "" {
+				current.Message = match[1]
+				continue
+			}
+			// 新版本的panic消息在下一行开始，直到note行
+			var message []string
+			for j := i + 1; j < len(lines) && !strings.HasPrefix(lines[j], "note:") && !strings.HasPrefix(lines[j], "stack backtrace:") && strings.TrimSpace(lines[j]) != ""; j++ {
+				message = append(message, strings.TrimRight(lines[j], "\r"))
+			}
+			current.Message = strings.Join(message, "\n")
+		}
+	}
+	flush()
+	for _, key := range order {
+		report.Failures = append(report.Failures, failed[key])
+	}
+	if len(report.Failures) == 0 && report.Passed == 0 {
+		if loc := cargoBuildErrRegex.FindStringIndex(out); loc != nil {
+			report.Failures = append(report.Failures, &TestFailure{Message: "build failed", Output: strings.TrimSpace(out[loc[0]:])})
+		}
+	}
+	report.Failed = len(report.Failures)
+	return report
+}
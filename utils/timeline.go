@@ -17,6 +17,8 @@ const (
 	TL_LLMResponse    = "LLMResponse"
 	TL_ToolUse        = "ToolUse"
 	TL_Compact        = "Compact"
+	TL_TodoList       = "TodoList"
+	TL_Steer          = "Steer"
 )
 
 type Timeline struct {
@@ -160,6 +162,26 @@ func (t *Timeline) AddToolCallResult(toolId string, toolName string, content str
 	t.MaxId = t.MaxId + 1
 }
 
+// SteerItem 任务执行过程中用户追加的引导消息
+type SteerItem struct {
+	Content string `json:"content"`
+}
+
+func (s *SteerItem) Build() string {
+	return "用户在你执行任务的过程中追加了下面的指示，请结合它调整接下来的行动，已经完成的工作不需要重做\n" + NewTagContent(s.Content, "user_input").WholeContent
+}
+
+func (t *Timeline) AddSteer(content string) {
+	t.Items = append(t.Items, &TimelineItem{
+		Type:    TL_Steer,
+		Data:    &SteerItem{Content: content},
+		Ts:      time.Now().Unix(),
+		Id:      t.MaxId + 1,
+		GitHash: "",
+	})
+	t.MaxId = t.MaxId + 1
+}
+
 // CheckpointData 用于存储checkpoint的数据
 type CheckpointData struct {
 	Commit     string         `json:"commit"`
@@ -274,8 +296,12 @@ func (t *Timeline) CleanTailToolCalls() {
 
 func (t *Timeline) GetChatContext(addCoT bool) []*llm.ChatItem {
 	chats := make([]*llm.ChatItem, 0, len(t.Items))
+	var todo *TodoList
 	for _, item := range t.Items {
 		switch item.Type {
+		case TL_TodoList:
+			// todo list已经包含在工具调用结果中，这里只记录最新的，用于压缩后恢复
+			todo, _ = item.Data.(*TodoList)
 		case TL_UserInput:
 			query := item.Data.(*Query)
 			chats = append(chats, &llm.ChatItem{
@@ -283,6 +309,11 @@ func (t *Timeline) GetChatContext(addCoT bool) []*llm.ChatItem {
 				Message: query.Build(),
 				Img:     query.GetImageDataURL(),
 			})
+		case TL_Steer:
+			chats = append(chats, &llm.ChatItem{
+				Role:    "user",
+				Message: item.Data.(*SteerItem).Build(),
+			})
 		case TL_ToolUse:
 			toolResult := item.Data.(*ToolCallResult)
 			chatItem := &llm.ChatItem{
@@ -310,6 +341,9 @@ func (t *Timeline) GetChatContext(addCoT bool) []*llm.ChatItem {
 					Message: "超出上下文，请读取memento file恢复任务",
 				})
 			}
+			if todo != nil && len(todo.Items) > 0 {
+				chats[0].Message += "\n<todo_list>\n压缩前你通过todo_write记录的任务列表:\n" + todo.Markdown() + "</todo_list>\n"
+			}
 		case TL_LLMResponse:
 			content := item.Data.(*LLMResponseItem).Content
 			cot := ""
@@ -410,6 +444,17 @@ func (t *Timeline) ToBriefHistory() []*HistoryItem {
 				detail:     item.Data.(*Query).Build(),
 				actionList: []string{},
 			})
+		case TL_Steer:
+			if len(tmp) > 0 {
+				history = append(history, composeTask(tmp))
+				tmp = make([]*TimelineItem, 0)
+			}
+			history = append(history, &HistoryItem{
+				title:      fmt.Sprintf("%s 🧭 Steer", time.Unix(item.Ts, 0).Format("2006-01-02 15:04:05")),
+				simple:     item.Data.(*SteerItem).Content,
+				detail:     item.Data.(*SteerItem).Build(),
+				actionList: []string{},
+			})
 		default:
 			tmp = append(tmp, item)
 		}
@@ -443,6 +488,14 @@ func (t *Timeline) PrintHistory() string {
 		case TL_Compact:
 			buff.WriteString(InfoMessageStyle(locales.Sprintf("Compacting...")))
 			buff.WriteString("\n\n")
+		case TL_TodoList:
+			if list, ok := item.Data.(*TodoList); ok {
+				buff.WriteString(TodoListStyle(list))
+				buff.WriteString("\n\n")
+			}
+		case TL_Steer:
+			buff.WriteString(SteerStyle(item.Data.(*SteerItem).Content))
+			buff.WriteString("\n\n")
 		}
 
 	}
@@ -458,6 +511,8 @@ func (i *TimelineItem) Simple() string {
 	case TL_CheckpointSave:
 		cpData := i.Data.(*CheckpointData)
 		return cpData.Commit
+	case TL_Steer:
+		return i.Data.(*SteerItem).Content
 	case TL_ToolUse:
 		return ""
 	default:
@@ -476,6 +531,10 @@ func (i *TimelineItem) Title() string {
 		return "🔧 ToolUse"
 	case TL_Compact:
 		return "📄 Compact"
+	case TL_TodoList:
+		return "📝 Todo"
+	case TL_Steer:
+		return "🧭 Steer: "
 	default:
 		return ""
 	}
@@ -496,6 +555,13 @@ func (i *TimelineItem) Detail() string {
 			return i.Data.(*Query).Build()
 		}
 		return ""
+	case TL_TodoList:
+		if list, ok := i.Data.(*TodoList); ok {
+			return list.Markdown()
+		}
+		return ""
+	case TL_Steer:
+		return i.Data.(*SteerItem).Build()
 	default:
 		return ""
 	}
@@ -532,3 +598,13 @@ func (h *HistoryItem) Simple() string {
 func (h *HistoryItem) Detail() string {
 	return h.detail
 }
+
+// FirstCheckpointHash 返回会话中第一个checkpoint的hash，即Bergo改动之前的状态，没有时返回空
+func (t *Timeline) FirstCheckpointHash() string {
+	for _, item := range t.Items {
+		if item.Type == TL_CheckpointSave && item.GitHash != "" {
+			return item.GitHash
+		}
+	}
+	return ""
+}
@@ -147,6 +147,18 @@ func (item *TimelineItem) ToSerializable() *SerializableTimelineItem {
 				serializable.Data = data
 			}
 		}
+	case TL_TodoList:
+		if list, ok := item.Data.(*TodoList); ok {
+			if data, err := json.Marshal(list); err == nil {
+				serializable.Data = data
+			}
+		}
+	case TL_Steer:
+		if steer, ok := item.Data.(*SteerItem); ok {
+			if data, err := json.Marshal(steer); err == nil {
+				serializable.Data = data
+			}
+		}
 	}
 
 	return serializable
@@ -188,6 +200,16 @@ func (serializable *SerializableTimelineItem) ToTimelineItem() *TimelineItem {
 		if err := json.Unmarshal(serializable.Data, &compact); err == nil {
 			item.Data = &compact
 		}
+	case TL_TodoList:
+		var list TodoList
+		if err := json.Unmarshal(serializable.Data, &list); err == nil {
+			item.Data = &list
+		}
+	case TL_Steer:
+		var steer SteerItem
+		if err := json.Unmarshal(serializable.Data, &steer); err == nil {
+			item.Data = &steer
+		}
 	}
 
 	return item
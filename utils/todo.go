@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	TODO_PENDING     = "pending"
+	TODO_IN_PROGRESS = "in_progress"
+	TODO_DONE        = "done"
+)
+
+type TodoItem struct {
+	Id      string `json:"id"`
+	Content string `json:"content"`
+	Status  string `json:"status"`
+}
+
+type TodoList struct {
+	Items []*TodoItem `json:"items"`
+}
+
+func IsValidTodoStatus(status string) bool {
+	return status == TODO_PENDING || status == TODO_IN_PROGRESS || status == TODO_DONE
+}
+
+// Progress 返回已完成的数量和总数
+func (l *TodoList) Progress() (int, int) {
+	done := 0
+	for _, item := range l.Items {
+		if item.Status == TODO_DONE {
+			done++
+		}
+	}
+	return done, len(l.Items)
+}
+
+// HasUnfinished 是否还有未完成的项
+func (l *TodoList) HasUnfinished() bool {
+	done, total := l.Progress()
+	return done < total
+}
+
+// Markdown 渲染为markdown任务列表，用于memento file和上下文压缩后的提示
+func (l *TodoList) Markdown() string {
+	buf := bytes.NewBuffer(nil)
+	for _, item := range l.Items {
+		mark := " "
+		if item.Status == TODO_DONE {
+			mark = "x"
+		}
+		suffix := ""
+		if item.Status == TODO_IN_PROGRESS {
+			suffix = " (in progress)"
+		}
+		buf.WriteString(fmt.Sprintf("- [%s] %s. %s%s\n", mark, item.Id, item.Content, suffix))
+	}
+	return buf.String()
+}
+
+func (t *Timeline) AddTodoList(list *TodoList) {
+	t.Items = append(t.Items, &TimelineItem{
+		Type:    TL_TodoList,
+		Data:    list,
+		Ts:      time.Now().Unix(),
+		Id:      t.MaxId + 1,
+		GitHash: "",
+	})
+	t.MaxId = t.MaxId + 1
+}
+
+// GetTodoList 获取最新的todo list，没有时返回nil
+func (t *Timeline) GetTodoList() *TodoList {
+	for i := len(t.Items) - 1; i >= 0; i-- {
+		if t.Items[i].Type == TL_TodoList {
+			if list, ok := t.Items[i].Data.(*TodoList); ok {
+				return list
+			}
+		}
+	}
+	return nil
+}
+
+// UpdateMementoTodo 用todo list替换memento file中的 # todo 部分，没有该部分时追加到末尾
+func UpdateMementoTodo(list *TodoList) error {
+	content, err := os.ReadFile("./.bergo.memento")
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	start, end := -1, len(lines)
+	for i, line := range lines {
+		if start < 0 && strings.EqualFold(strings.TrimSpace(line), "# todo") {
+			start = i
+			continue
+		}
+		if start >= 0 && strings.HasPrefix(line, "# ") {
+			end = i
+			break
+		}
+	}
+	section := append([]string{"# todo"}, strings.Split(strings.TrimRight(list.Markdown(), "\n"), "\n")...)
+	var result []string
+	if start < 0 {
+		result = append(lines, section...)
+		if len(lines) == 1 && lines[0] == "" {
+			result = section
+		}
+	} else {
+		result = append(result, lines[:start]...)
+		result = append(result, section...)
+		result = append(result, lines[end:]...)
+	}
+	return os.WriteFile("./.bergo.memento", []byte(strings.Join(result, "\n")+"\n"), 0644)
+}
@@ -12,6 +12,7 @@ type Query struct {
 	Attachment          []*Attachment `json:"attachment"`
 	IsInterrupted       bool          `json:"is_interrupted"`
 	MementoUpdateRemind bool          `json:"memento_update_remind"`
+	VerifyFailure       string        `json:"verify_failure,omitempty"`
 	IsCompact           bool
 }
 
@@ -22,6 +23,11 @@ func (q *Query) SetMementoUpdateRemind() {
 	q.MementoUpdateRemind = true
 }
 
+// SetVerifyFailure 设置校验命令失败的输出，提示模型修复
+func (q *Query) SetVerifyFailure(failure string) {
+	q.VerifyFailure = failure
+}
+
 func (q *Query) SetAttachment(attachments []*Attachment) {
 	q.Attachment = append(q.Attachment, attachments...)
 }
@@ -78,5 +84,10 @@ func (q *Query) Build() string {
 	if q.MementoUpdateRemind {
 		buf.WriteString("<memento_update_remind>检测到你在上一轮任务中没有更新memento file，请记得及时更新它以保存任务进度和关键信息</memento_update_remind>\n")
 	}
+	if q.VerifyFailure != "" {
+		buf.WriteString("<verify_failure>你修改文件后，校验命令执行失败了，请根据下面的输出修复问题\n")
+		buf.WriteString(q.VerifyFailure)
+		buf.WriteString("\n</verify_failure>\n")
+	}
 	return buf.String()
 }
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 校验失败时反馈给模型的最大输出行数，只保留末尾部分，编译器和测试的关键信息通常在最后
+const VERIFY_MAX_OUTPUT_LINE = 200
+
+type VerifyFailure struct {
+	Command string
+	Output  string
+	Err     error
+}
+
+// String 生成反馈给模型的失败描述
+func (f *VerifyFailure) String() string {
+	return fmt.Sprintf("command: %s\nerror: %v\noutput:\n%s", f.Command, f.Err, f.Output)
+}
+
+type Verifier struct {
+	Commands []string
+	Timeout  time.Duration
+}
+
+// Run 依次执行校验命令，遇到第一条失败的命令即返回，全部通过时返回nil
+// onStart 在每条命令执行前回调，用于展示进度；ctx被取消时终止正在运行的命令
+func (v *Verifier) Run(ctx context.Context, onStart func(command string)) *VerifyFailure {
+	shell := &Shell{}
+	for _, command := range v.Commands {
+		command = strings.TrimSpace(command)
+		if command == "" {
+			continue
+		}
+		if onStart != nil {
+			onStart(command)
+		}
+		output, err := shell.RunCommand(ctx, command, v.Timeout)
+		if err != nil {
+			return &VerifyFailure{
+				Command: command,
+				Output:  TailLines(output, VERIFY_MAX_OUTPUT_LINE),
+				Err:     err,
+			}
+		}
+	}
+	return nil
+}
+
+// TailLines 保留文本的最后n行，n<=0时不截断
+func TailLines(content string, n int) string {
+	lines := strings.Split(content, "\n")
+	if n <= 0 || len(lines) <= n {
+		return content
+	}
+	return fmt.Sprintf("...(%d lines omitted)\n%s", len(lines)-n, strings.Join(lines[len(lines)-n:], "\n"))
+}